@@ -2,12 +2,20 @@ package main
 
 import (
 	"context"
+	"errors"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
 	"cis-engine/internal/api"
+	"cis-engine/internal/indexer"
 	"cis-engine/internal/search"
-	"cis-engine/internal/storage/postgres"
+	"cis-engine/internal/storage"
+	"cis-engine/internal/storage/backend"
 
 	"github.com/joho/godotenv"
 )
@@ -17,26 +25,90 @@ func main() {
 		log.Println("Файл .env не найден, используются переменные окружения системы")
 	}
 
-	dbURL := os.Getenv("DATABASE_URL")
-	if dbURL == "" {
-		log.Fatal("Переменная окружения DATABASE_URL не установлена")
-	}
+	storeCfg := backend.FromEnv()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
-	ctx := context.Background()
-	db, err := postgres.New(ctx, dbURL)
+	db, err := backend.Open(ctx, storeCfg)
 	if err != nil {
-		log.Fatalf("Не удалось подключиться к базе данных: %v", err)
+		log.Fatalf("Не удалось открыть хранилище: %v", err)
 	}
 	defer db.Close()
-	log.Println("Успешное подключение к базе данных.")
+	log.Printf("Хранилище %s открыто.", storeCfg.Backend)
+
+	weights := storage.DefaultRankWeights()
+	weights.Text = envFloat("RANK_TEXT_WEIGHT", weights.Text)
+	weights.Authority = envFloat("RANK_AUTHORITY_WEIGHT", weights.Authority)
+	weights.Title = envFloat("RANK_TITLE_WEIGHT", weights.Title)
+	weights.Headings = envFloat("RANK_HEADINGS_WEIGHT", weights.Headings)
+	weights.Anchors = envFloat("RANK_ANCHORS_WEIGHT", weights.Anchors)
+	weights.Body = envFloat("RANK_BODY_WEIGHT", weights.Body)
+	weights.BM25K1 = envFloat("RANK_BM25_K1", weights.BM25K1)
+	weights.BM25B = envFloat("RANK_BM25_B", weights.BM25B)
+	if err := weights.Validate(); err != nil {
+		log.Fatalf("Некорректные веса ранжирования: %v", err)
+	}
+	if tuner, ok := db.(storage.RankTuner); ok {
+		tuner.SetRankWeights(weights)
+	}
+
+	// Встроенное хранилище доступно только этому процессу, поэтому
+	// страницы индексируются здесь же.
+	if storeCfg.Backend == backend.Memory {
+		idx := indexer.NewIndexer(db, indexer.DefaultConfig())
+		go idx.Start(ctx)
+		defer idx.Stop()
+	}
 
 	searchService := search.NewService(db)
+	go searchService.RefreshSuggestions(ctx, search.SuggestRefreshInterval)
+	retention := envDuration("QUERY_LOG_RETENTION", search.DefaultQueryLogRetention)
+	queryLogDone := make(chan struct{})
+	go func() {
+		searchService.RunQueryLog(ctx, retention)
+		close(queryLogDone)
+	}()
 	apiHandler := api.NewHandler(searchService)
 	router := api.NewRouter(apiHandler)
 
-	serverAddr := ":8080"
-	log.Printf("Запуск API сервера на http://localhost%s", serverAddr)
-	if err := router.Run(serverAddr); err != nil {
+	server := &http.Server{Addr: ":8080", Handler: router}
+	go func() {
+		<-ctx.Done()
+		log.Println("Получен сигнал завершения, остановка API сервера...")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	log.Printf("Запуск API сервера на http://localhost%s", server.Addr)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		log.Fatalf("Не удалось запустить сервер: %v", err)
 	}
+	// Оставшиеся запросы записываются в журнал до закрытия хранилища.
+	<-queryLogDone
+}
+
+func envFloat(name string, def float64) float64 {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return def
+	}
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		log.Fatalf("Некорректное значение %s: %v", name, err)
+	}
+	return v
+}
+
+func envDuration(name string, def time.Duration) time.Duration {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return def
+	}
+	v, err := time.ParseDuration(raw)
+	if err != nil {
+		log.Fatalf("Некорректное значение %s: %v", name, err)
+	}
+	return v
 }
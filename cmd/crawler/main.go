@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"flag"
 	"log"
 	"os"
 	"os/signal"
@@ -9,41 +10,94 @@ import (
 	"time"
 
 	"cis-engine/internal/crawler"
-	"cis-engine/internal/storage/postgres"
+	"cis-engine/internal/storage/backend"
 
 	"github.com/joho/godotenv"
 )
 
 func main() {
+	cfg := crawler.DefaultConfig()
+	flag.IntVar(&cfg.Workers, "workers", cfg.Workers, "Количество воркеров")
+	flag.Float64Var(&cfg.HostRate, "host-rps", cfg.HostRate, "Максимум запросов в секунду к одному хосту")
+	flag.IntVar(&cfg.HostConnections, "host-conns", cfg.HostConnections, "Максимум одновременных запросов к одному хосту")
+	flag.DurationVar(&cfg.MaxBackoff, "max-backoff", cfg.MaxBackoff, "Максимальная пауза для хоста после ответов 429/503")
+	flag.StringVar(&cfg.Scope.Mode, "scope", cfg.Scope.Mode, "Область обхода: host, domain, list или any")
+	flag.Func("allow-domain", "Разрешенный домен для -scope=list (можно указать несколько раз)", appendTo(&cfg.Scope.AllowedDomains))
+	flag.IntVar(&cfg.Scope.MaxDepth, "max-depth", cfg.Scope.MaxDepth, "Максимальная глубина ссылок от начального URL (0 - без ограничения)")
+	flag.IntVar(&cfg.Scope.MaxPagesPerHost, "max-pages-per-host", cfg.Scope.MaxPagesPerHost, "Максимум страниц с одного хоста (0 - без ограничения)")
+	flag.Func("include", "Регулярное выражение для пути и query URL, которые нужно обходить (можно указать несколько раз)", appendTo(&cfg.Scope.Include))
+	flag.Func("exclude", "Регулярное выражение для пути и query URL, которые нужно пропускать (можно указать несколько раз)", appendTo(&cfg.Scope.Exclude))
+	flag.Func("strip-param", "Дополнительный параметр query, удаляемый при нормализации URL; \"prefix*\" - по префиксу (можно указать несколько раз)", appendTo(&cfg.Normalization.StripParams))
+	flag.StringVar(&cfg.Normalization.TrailingSlash, "trailing-slash", cfg.Normalization.TrailingSlash, "Завершающий слеш в пути URL: keep или strip")
+	flag.BoolVar(&cfg.CompressRaw, "compress-raw", cfg.CompressRaw, "Сжимать исходный HTML страниц при сохранении")
+	flag.BoolVar(&cfg.Sitemaps, "sitemaps", cfg.Sitemaps, "Искать sitemap хостов через robots.txt и /sitemap.xml")
+	var sitemaps []string
+	flag.Func("sitemap", "URL файла sitemap, страницы из которого нужно обойти (можно указать несколько раз)", appendTo(&sitemaps))
+	exitWhenDone := flag.Bool("exit-when-done", false, "Завершить работу, когда в очереди не останется готовых к загрузке URL")
+	stopTimeout := flag.Duration("stop-timeout", 30*time.Second, "Сколько ждать завершения текущих загрузок при остановке")
+	flag.Parse()
+
+	if err := cfg.Scope.Validate(); err != nil {
+		log.Fatalf("Некорректная область обхода: %v", err)
+	}
+
+	seeds := flag.Args()
+	if len(seeds) == 0 && len(sitemaps) == 0 {
+		seeds = []string{"https://golang.org"}
+	}
+
 	if err := godotenv.Load(); err != nil {
 		log.Println("Файл .env не найден, используются переменные окружения системы")
 	}
 
-	dbURL := os.Getenv("DATABASE_URL")
-	if dbURL == "" {
-		log.Fatal("Переменная окружения DATABASE_URL не установлена")
-	}
+	storeCfg := backend.FromEnv()
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	db, err := postgres.New(ctx, dbURL)
+	db, err := backend.Open(ctx, storeCfg)
 	if err != nil {
-		log.Fatalf("Не удалось подключиться к базе данных: %v", err)
+		log.Fatalf("Не удалось открыть хранилище: %v", err)
 	}
 	defer db.Close()
-	log.Println("Краулер: Успешное подключение к базе данных.")
+	log.Printf("Краулер: хранилище %s открыто.", storeCfg.Backend)
 
 	fetcher := crawler.NewHTTPFetcher(10 * time.Second)
-	app := crawler.NewCrawler(5, 10, db, fetcher)
+	app := crawler.NewCrawler(cfg, db, fetcher)
 
-	go app.Start(ctx, []string{"https://golang.org"})
+	for _, u := range sitemaps {
+		if err := app.AddSitemap(ctx, u); err != nil {
+			log.Printf("Не удалось добавить sitemap %s: %v", u, err)
+		}
+	}
+	app.Start(ctx, seeds)
 	log.Println("Краулер запущен. Нажмите CTRL+C для остановки.")
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
 
-	log.Println("Получен сигнал завершения, остановка краулера...")
-	app.Stop()
+	var finished <-chan struct{}
+	if *exitWhenDone {
+		finished = app.Done()
+	}
+	select {
+	case <-quit:
+		log.Println("Получен сигнал завершения, остановка краулера...")
+	case <-finished:
+		log.Println("Обход завершен, остановка краулера...")
+	}
+
+	stopCtx, stopCancel := context.WithTimeout(context.Background(), *stopTimeout)
+	defer stopCancel()
+	if err := app.Stop(stopCtx); err != nil {
+		log.Printf("Краулер остановлен с прерыванием загрузок: %v", err)
+		return
+	}
 	log.Println("Краулер успешно остановлен.")
 }
+
+func appendTo(values *[]string) func(string) error {
+	return func(v string) error {
+		*values = append(*values, v)
+		return nil
+	}
+}
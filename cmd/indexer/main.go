@@ -2,37 +2,44 @@ package main
 
 import (
 	"context"
+	"flag"
 	"log"
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
 
 	"cis-engine/internal/indexer"
-	"cis-engine/internal/storage/postgres"
+	"cis-engine/internal/storage/backend"
 
 	"github.com/joho/godotenv"
 )
 
 func main() {
+	cfg := indexer.DefaultConfig()
+	flag.IntVar(&cfg.BatchSize, "batch-size", cfg.BatchSize, "Сколько страниц индексировать за один запрос")
+	flag.IntVar(&cfg.Workers, "workers", cfg.Workers, "Количество воркеров индексации")
+	flag.DurationVar(&cfg.PollInterval, "poll-interval", cfg.PollInterval, "Интервал проверки очереди без уведомлений о новых страницах")
+	flag.Parse()
+
+	if cfg.BatchSize <= 0 || cfg.Workers <= 0 || cfg.PollInterval <= 0 {
+		log.Fatal("Параметры -batch-size, -workers и -poll-interval должны быть положительными")
+	}
+
 	if err := godotenv.Load(); err != nil {
 		log.Println("Файл .env не найден, используются переменные окружения системы")
 	}
 
-	dbURL := os.Getenv("DATABASE_URL")
-	if dbURL == "" {
-		log.Fatal("Переменная окружения DATABASE_URL не установлена")
-	}
+	storeCfg := backend.FromEnv()
 
 	ctx := context.Background()
-	db, err := postgres.New(ctx, dbURL)
+	db, err := backend.Open(ctx, storeCfg)
 	if err != nil {
-		log.Fatalf("Не удалось подключиться к базе данных: %v", err)
+		log.Fatalf("Не удалось открыть хранилище: %v", err)
 	}
 	defer db.Close()
-	log.Println("Индексатор: Успешное подключение к базе данных.")
+	log.Printf("Индексатор: хранилище %s открыто.", storeCfg.Backend)
 
-	app := indexer.NewIndexer(db, 2*time.Second)
+	app := indexer.NewIndexer(db, cfg)
 
 	go app.Start(ctx)
 
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"cis-engine/internal/dedup"
+	"cis-engine/internal/rank"
+	"cis-engine/internal/storage"
+	"cis-engine/internal/storage/backend"
+
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	opts := rank.DefaultOptions()
+	flag.Float64Var(&opts.Damping, "damping", opts.Damping, "Коэффициент затухания PageRank")
+	flag.IntVar(&opts.Iterations, "iterations", opts.Iterations, "Максимальное число итераций")
+	flag.Float64Var(&opts.Tolerance, "tolerance", opts.Tolerance, "Порог сходимости")
+	maxDistance := flag.Int("max-distance", dedup.DefaultMaxDistance, "Наибольшее расстояние Хэмминга между SimHash почти дубликатов (от 0 до 7)")
+	interval := flag.Duration("interval", 0, "Интервал между пересчетами (0 - выполнить один раз и завершиться)")
+	flag.Parse()
+
+	if *maxDistance < 0 || *maxDistance > dedup.MaxDistance {
+		log.Fatalf("Параметр -max-distance должен быть от 0 до %d", dedup.MaxDistance)
+	}
+
+	if err := godotenv.Load(); err != nil {
+		log.Println("Файл .env не найден, используются переменные окружения системы")
+	}
+
+	storeCfg := backend.FromEnv()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	db, err := backend.Open(ctx, storeCfg)
+	if err != nil {
+		log.Fatalf("Не удалось открыть хранилище: %v", err)
+	}
+	defer db.Close()
+	log.Printf("Ранжировщик: хранилище %s открыто.", storeCfg.Backend)
+
+	for {
+		if err := run(ctx, db, opts, *maxDistance); err != nil {
+			log.Printf("Ошибка пересчета ранжирования: %v", err)
+			if *interval == 0 {
+				os.Exit(1)
+			}
+		}
+		if *interval == 0 {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Println("Ранжировщик остановлен.")
+			return
+		case <-time.After(*interval):
+		}
+	}
+}
+
+func run(ctx context.Context, db storage.Storer, opts rank.Options, maxDistance int) error {
+	start := time.Now()
+	graph, err := db.GetLinkGraph(ctx)
+	if err != nil {
+		return err
+	}
+
+	scores := rank.Normalize(rank.PageRank(graph, opts))
+	if err := db.UpdateAuthorityScores(ctx, scores); err != nil {
+		return err
+	}
+
+	log.Printf("PageRank пересчитан: %d страниц, %d ссылок за %s", len(graph.Nodes), len(graph.Edges), time.Since(start).Round(time.Millisecond))
+
+	start = time.Now()
+	if err := db.UpdateTermStats(ctx); err != nil {
+		return err
+	}
+	log.Printf("Статистика термов для BM25 пересчитана за %s", time.Since(start).Round(time.Millisecond))
+
+	// Каноническая страница группы выбирается по авторитетности, поэтому
+	// группы пересчитываются после PageRank.
+	start = time.Now()
+	fingerprints, err := db.GetFingerprints(ctx)
+	if err != nil {
+		return err
+	}
+	duplicates := dedup.Cluster(fingerprints, maxDistance)
+	if err := db.UpdateDuplicates(ctx, duplicates); err != nil {
+		return err
+	}
+	log.Printf("Почти дубликаты найдены: %d из %d страниц за %s", len(duplicates), len(fingerprints), time.Since(start).Round(time.Millisecond))
+	return nil
+}
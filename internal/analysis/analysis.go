@@ -0,0 +1,88 @@
+// Package analysis разбивает текст на термы для встроенного поискового
+// индекса: токенизация, приведение к нижнему регистру и стемминг русских и
+// английских слов.
+package analysis
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Token - слово текста. Start и End - смещения в байтах в исходной строке.
+type Token struct {
+	Text  string
+	Start int
+	End   int
+}
+
+// Tokenize разбивает текст на слова из букв и цифр.
+func Tokenize(text string) []Token {
+	var tokens []Token
+	start := -1
+	for i, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			tokens = append(tokens, Token{Text: text[start:i], Start: start, End: i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, Token{Text: text[start:], Start: start, End: len(text)})
+	}
+	return tokens
+}
+
+// Term приводит слово к форме, под которой оно хранится в индексе: нижний
+// регистр, "ё" заменяется на "е", русские и английские слова стеммируются.
+func Term(word string) string {
+	word = strings.ReplaceAll(strings.ToLower(word), "ё", "е")
+	switch script(word) {
+	case scriptCyrillic:
+		return stemRussian(word)
+	case scriptLatin:
+		return stemEnglish(word)
+	}
+	return word
+}
+
+// Terms возвращает термы всех слов текста.
+func Terms(text string) []string {
+	tokens := Tokenize(text)
+	terms := make([]string, len(tokens))
+	for i, t := range tokens {
+		terms[i] = Term(t.Text)
+	}
+	return terms
+}
+
+const (
+	scriptOther = iota
+	scriptLatin
+	scriptCyrillic
+)
+
+// script определяет алфавит слова; слова со смешанными алфавитами и
+// цифрами не стеммируются.
+func script(word string) int {
+	latin, cyrillic := true, true
+	for _, r := range word {
+		if r < 'a' || r > 'z' {
+			latin = false
+		}
+		if !(r >= 'а' && r <= 'я') {
+			cyrillic = false
+		}
+	}
+	switch {
+	case latin:
+		return scriptLatin
+	case cyrillic:
+		return scriptCyrillic
+	}
+	return scriptOther
+}
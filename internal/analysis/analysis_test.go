@@ -0,0 +1,71 @@
+package analysis
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenize(t *testing.T) {
+	tokens := Tokenize("Go 1.24: быстрый, ёмкий!")
+	require.Equal(t, []Token{
+		{Text: "Go", Start: 0, End: 2},
+		{Text: "1", Start: 3, End: 4},
+		{Text: "24", Start: 5, End: 7},
+		{Text: "быстрый", Start: 9, End: 23},
+		{Text: "ёмкий", Start: 25, End: 35},
+	}, tokens)
+}
+
+func TestStemEnglish(t *testing.T) {
+	cases := map[string]string{
+		"caresses":       "caress",
+		"ponies":         "poni",
+		"cats":           "cat",
+		"agreed":         "agre",
+		"plastered":      "plaster",
+		"motoring":       "motor",
+		"hopping":        "hop",
+		"filing":         "file",
+		"happy":          "happi",
+		"relational":     "relat",
+		"conditional":    "condit",
+		"generalization": "gener",
+		"programming":    "program",
+		"programs":       "program",
+		"running":        "run",
+		"effective":      "effect",
+		"controll":       "control",
+		"go":             "go",
+	}
+	for word, want := range cases {
+		require.Equal(t, want, stemEnglish(word), word)
+	}
+}
+
+func TestStemRussian(t *testing.T) {
+	cases := map[string]string{
+		"книга":            "книг",
+		"книги":            "книг",
+		"книгами":          "книг",
+		"поисковый":        "поисков",
+		"поисковые":        "поисков",
+		"программирование": "программирован",
+		"сохраняет":        "сохраня",
+		"сохранять":        "сохраня",
+		"прочитавшись":     "прочита",
+		"красивейший":      "красив",
+		"молодость":        "молод",
+		"я":                "я",
+	}
+	for word, want := range cases {
+		require.Equal(t, want, stemRussian(word), word)
+	}
+}
+
+func TestTerm(t *testing.T) {
+	require.Equal(t, "program", Term("Programming"))
+	require.Equal(t, "елк", Term("Ёлки"))
+	require.Equal(t, "http2", Term("HTTP2"))
+	require.Equal(t, []string{"поисков", "engin"}, Terms("Поисковые engines"))
+}
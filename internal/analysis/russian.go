@@ -0,0 +1,154 @@
+package analysis
+
+import "slices"
+
+// stemRussian реализует русский стеммер Snowball для слов из строчных
+// русских букв (буква "ё" заменяется на "е" заранее).
+func stemRussian(word string) string {
+	w := []rune(word)
+	rv, r2 := russianRegions(w)
+	if rv >= len(w) {
+		return word
+	}
+
+	// Шаг 1: деепричастие совершенного вида, иначе возвратная частица и
+	// окончания прилагательного, глагола или существительного.
+	if n, ok := russianSuffix(w, rv, perfectiveGerund1, perfectiveGerund2); ok {
+		w = w[:len(w)-n]
+	} else {
+		if n, ok := russianSuffix(w, rv, nil, reflexive); ok {
+			w = w[:len(w)-n]
+		}
+		if n, ok := russianSuffix(w, rv, nil, adjective); ok {
+			w = w[:len(w)-n]
+			if n, ok := russianSuffix(w, rv, participle1, participle2); ok {
+				w = w[:len(w)-n]
+			}
+		} else if n, ok := russianSuffix(w, rv, verb1, verb2); ok {
+			w = w[:len(w)-n]
+		} else if n, ok := russianSuffix(w, rv, nil, noun); ok {
+			w = w[:len(w)-n]
+		}
+	}
+
+	// Шаг 2.
+	if len(w) > rv && w[len(w)-1] == 'и' {
+		w = w[:len(w)-1]
+	}
+
+	// Шаг 3: словообразовательное окончание в R2.
+	if n, ok := russianSuffix(w, r2, nil, derivational); ok {
+		w = w[:len(w)-n]
+	}
+
+	// Шаг 4.
+	switch {
+	case hasRuneSuffix(w, rv, "нн"):
+		w = w[:len(w)-1]
+	case hasRuneSuffix(w, rv, "ь"):
+		w = w[:len(w)-1]
+	default:
+		if n, ok := russianSuffix(w, rv, nil, superlative); ok {
+			w = w[:len(w)-n]
+			if hasRuneSuffix(w, rv, "нн") {
+				w = w[:len(w)-1]
+			}
+		}
+	}
+	return string(w)
+}
+
+// Окончания из групп с суффиксом 1 удаляются, только если перед ними стоит
+// "а" или "я".
+var (
+	perfectiveGerund1 = []string{"в", "вши", "вшись"}
+	perfectiveGerund2 = []string{"ив", "ивши", "ившись", "ыв", "ывши", "ывшись"}
+	adjective         = []string{
+		"ее", "ие", "ые", "ое", "ими", "ыми", "ей", "ий", "ый", "ой", "ем", "им", "ым", "ом",
+		"его", "ого", "ему", "ому", "их", "ых", "ую", "юю", "ая", "яя", "ою", "ею",
+	}
+	participle1 = []string{"ем", "нн", "вш", "ющ", "щ"}
+	participle2 = []string{"ивш", "ывш", "ующ"}
+	reflexive   = []string{"ся", "сь"}
+	verb1       = []string{"ла", "на", "ете", "йте", "ли", "й", "л", "ем", "н", "ло", "но", "ет", "ют", "ны", "ть", "ешь", "нно"}
+	verb2       = []string{
+		"ила", "ыла", "ена", "ейте", "уйте", "ите", "или", "ыли", "ей", "уй", "ил", "ыл", "им", "ым", "ен",
+		"ило", "ыло", "ено", "ят", "ует", "уют", "ит", "ыт", "ены", "ить", "ыть", "ишь", "ую", "ю",
+	}
+	noun = []string{
+		"а", "ев", "ов", "ие", "ье", "е", "иями", "ями", "ами", "еи", "ии", "и", "ией", "ей", "ой", "ий",
+		"й", "иям", "ям", "ием", "ем", "ам", "ом", "о", "у", "ах", "иях", "ях", "ы", "ь", "ию", "ью",
+		"ю", "ия", "ья", "я",
+	}
+	derivational = []string{"ост", "ость"}
+	superlative  = []string{"ейш", "ейше"}
+)
+
+// russianSuffix ищет самое длинное окончание из групп afterA (требует
+// "а" или "я" перед окончанием) и plain, целиком лежащее в области,
+// начинающейся с индекса from. Возвращает длину окончания в рунах.
+func russianSuffix(w []rune, from int, afterA, plain []string) (int, bool) {
+	best, bestA := "", false
+	for _, group := range []struct {
+		endings []string
+		afterA  bool
+	}{{afterA, true}, {plain, false}} {
+		for _, e := range group.endings {
+			if len([]rune(e)) > len([]rune(best)) && hasRuneSuffix(w, from, e) {
+				best, bestA = e, group.afterA
+			}
+		}
+	}
+	if best == "" {
+		return 0, false
+	}
+	n := len([]rune(best))
+	if bestA {
+		i := len(w) - n - 1
+		if i < from || (w[i] != 'а' && w[i] != 'я') {
+			return 0, false
+		}
+	}
+	return n, true
+}
+
+// hasRuneSuffix сообщает, оканчивается ли w на suffix, начинающийся не
+// раньше индекса from.
+func hasRuneSuffix(w []rune, from int, suffix string) bool {
+	s := []rune(suffix)
+	start := len(w) - len(s)
+	return start >= from && slices.Equal(w[start:], s)
+}
+
+func isRussianVowel(r rune) bool {
+	switch r {
+	case 'а', 'е', 'и', 'о', 'у', 'ы', 'э', 'ю', 'я':
+		return true
+	}
+	return false
+}
+
+// russianRegions возвращает начало RV - части слова после первой гласной -
+// и R2 в терминах Snowball.
+func russianRegions(w []rune) (rv, r2 int) {
+	rv = len(w)
+	for i, r := range w {
+		if isRussianVowel(r) {
+			rv = i + 1
+			break
+		}
+	}
+	r1 := nextRegion(w, 0)
+	return rv, nextRegion(w, r1)
+}
+
+// nextRegion возвращает индекс после первой согласной, следующей за
+// гласной, начиная с from.
+func nextRegion(w []rune, from int) int {
+	for i := from + 1; i < len(w); i++ {
+		if !isRussianVowel(w[i]) && isRussianVowel(w[i-1]) {
+			return i + 1
+		}
+	}
+	return len(w)
+}
@@ -2,21 +2,39 @@ package api
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"strconv"
+	"time"
+	"unicode/utf8"
 
 	"cis-engine/internal/search"
 	"cis-engine/internal/storage"
+	"cis-engine/internal/suggest"
 
 	"github.com/gin-gonic/gin"
 )
 
 type Searcher interface {
-	Search(ctx context.Context, query string) ([]search.Result, error)
-	ScheduleCrawl(ctx context.Context, url string) error
+	Search(ctx context.Context, req search.Request) (*search.Response, error)
+	ScheduleCrawl(ctx context.Context, url string, scope *storage.CrawlScope) error
+	ScheduleSitemap(ctx context.Context, url string, scope *storage.CrawlScope) error
 	GetStats(ctx context.Context) (*storage.Metrics, error)
+	GetPageLinks(ctx context.Context, pageID int64, limit int) (*search.PageLinks, error)
+	Suggest(ctx context.Context, prefix string, limit int) ([]suggest.Suggestion, error)
+	TopQueries(ctx context.Context, req search.AnalyticsRequest, zeroResults bool) ([]*storage.QueryCount, error)
+	QueryLatency(ctx context.Context, req search.AnalyticsRequest) (*storage.LatencyStats, error)
 }
 
+const (
+	defaultLinksLimit = 100
+	maxLinksLimit     = 1000
+	// maxQueryRunes - наибольшая длина поискового запроса в символах.
+	maxQueryRunes = 1000
+)
+
 type Handler struct {
 	searchService Searcher
 }
@@ -32,8 +50,15 @@ func NewRouter(h *Handler) *gin.Engine {
 	apiV1 := router.Group("/api/v1")
 	{
 		apiV1.GET("/search", h.searchHandler)
+		apiV1.GET("/suggest", h.suggestHandler)
 		apiV1.POST("/crawl", h.crawlHandler)
 		apiV1.GET("/status", h.statusHandler)
+		apiV1.GET("/pages/:id/links", h.pageLinksHandler)
+
+		analytics := apiV1.Group("/analytics/queries")
+		analytics.GET("/top", h.topQueriesHandler(false))
+		analytics.GET("/zero-results", h.topQueriesHandler(true))
+		analytics.GET("/latency", h.queryLatencyHandler)
 	}
 
 	return router
@@ -45,20 +70,287 @@ func (h *Handler) searchHandler(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Параметр 'q' не может быть пустым"})
 		return
 	}
+	if utf8.RuneCountInString(query) > maxQueryRunes {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Параметр 'q' не может быть длиннее %d символов", maxQueryRunes)})
+		return
+	}
 
-	results, err := h.searchService.Search(c.Request.Context(), query)
+	snippet, err := snippetOptions(c)
 	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	filter, err := searchFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	req := search.Request{
+		Query:   query,
+		Lang:    c.Query("lang"),
+		Ranker:  c.Query("ranker"),
+		Filter:  filter,
+		Snippet: snippet,
+		Cursor:  c.Query("cursor"),
+	}
+	if req.ClientID = c.GetHeader("X-Client-ID"); req.ClientID == "" {
+		req.ClientID = c.ClientIP()
+	}
+	if req.Limit, err = intQuery(c, "limit"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Offset, err = intQuery(c, "offset"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if raw := c.Query("duplicates"); raw != "" {
+		if req.ShowDuplicates, err = strconv.ParseBool(raw); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Параметр 'duplicates' должен быть true или false"})
+			return
+		}
+	}
+
+	resp, err := h.searchService.Search(c.Request.Context(), req)
+	if err != nil {
+		if errors.Is(err, search.ErrInvalidRequest) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		log.Printf("ERROR: search service failed for query '%s': %v", query, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Внутренняя ошибка сервера"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"query": query, "results": results})
+	response := gin.H{"query": query, "results": resp.Results, "total": resp.Total}
+	if resp.NextCursor != "" {
+		response["next_cursor"] = resp.NextCursor
+	}
+	if resp.Suggestion != "" {
+		response["suggestion"] = resp.Suggestion
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *Handler) suggestHandler(c *gin.Context) {
+	prefix := c.Query("prefix")
+	if prefix == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Параметр 'prefix' не может быть пустым"})
+		return
+	}
+	limit, err := intQuery(c, "limit")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	suggestions, err := h.searchService.Suggest(c.Request.Context(), prefix, limit)
+	if err != nil {
+		if errors.Is(err, search.ErrInvalidRequest) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("ERROR: suggest failed for prefix '%s': %v", prefix, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Внутренняя ошибка сервера"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"prefix": prefix, "suggestions": suggestions})
+}
+
+// topQueriesHandler возвращает самые частые запросы за период, при
+// zeroResults - только запросы без результатов.
+func (h *Handler) topQueriesHandler(zeroResults bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		req, err := analyticsRequest(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		queries, err := h.searchService.TopQueries(c.Request.Context(), req, zeroResults)
+		if err != nil {
+			if errors.Is(err, search.ErrInvalidRequest) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			log.Printf("ERROR: failed to get top queries: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось получить статистику запросов"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"from": req.From, "to": req.To, "queries": queries})
+	}
+}
+
+func (h *Handler) queryLatencyHandler(c *gin.Context) {
+	req, err := analyticsRequest(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	stats, err := h.searchService.QueryLatency(c.Request.Context(), req)
+	if err != nil {
+		if errors.Is(err, search.ErrInvalidRequest) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("ERROR: failed to get query latency: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось получить статистику запросов"})
+		return
+	}
+
+	// Время отдается в миллисекундах; у последней корзины нет верхней
+	// границы.
+	buckets := make([]gin.H, 0, len(stats.Buckets))
+	for _, b := range stats.Buckets {
+		bucket := gin.H{"count": b.Count}
+		if b.UpperBound > 0 {
+			bucket["lt_ms"] = milliseconds(b.UpperBound)
+		}
+		buckets = append(buckets, bucket)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"from":    req.From,
+		"to":      req.To,
+		"count":   stats.Count,
+		"p50_ms":  milliseconds(stats.P50),
+		"p90_ms":  milliseconds(stats.P90),
+		"p99_ms":  milliseconds(stats.P99),
+		"buckets": buckets,
+	})
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// analyticsRequest читает период отчета: from и to или window -
+// длительность периода до to (например, "168h"), и limit. Период
+// заполняется явно, чтобы вернуть его в ответе.
+func analyticsRequest(c *gin.Context) (search.AnalyticsRequest, error) {
+	var req search.AnalyticsRequest
+	var err error
+	if req.From, err = timeQuery(c, "from"); err != nil {
+		return req, err
+	}
+	if req.To, err = timeQuery(c, "to"); err != nil {
+		return req, err
+	}
+	if req.Limit, err = intQuery(c, "limit"); err != nil {
+		return req, err
+	}
+	if req.To.IsZero() {
+		req.To = time.Now()
+	}
+
+	window := search.DefaultAnalyticsWindow
+	if raw := c.Query("window"); raw != "" {
+		if !req.From.IsZero() {
+			return req, errors.New("Параметры 'from' и 'window' нельзя указывать вместе")
+		}
+		window, err = time.ParseDuration(raw)
+		if err != nil || window <= 0 {
+			return req, errors.New("Параметр 'window' должен быть положительной длительностью, например 24h")
+		}
+	}
+	if req.From.IsZero() {
+		req.From = req.To.Add(-window)
+	}
+	return req, nil
+}
+
+// intQuery читает необязательный целочисленный параметр; 0, если он не задан.
+func intQuery(c *gin.Context, name string) (int, error) {
+	raw := c.Query(name)
+	if raw == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, fmt.Errorf("Параметр '%s' должен быть целым числом", name)
+	}
+	return n, nil
+}
+
+// searchFilter читает параметры фильтров выдачи: site (или host),
+// path_prefix, crawled_after, crawled_before и content_type.
+func searchFilter(c *gin.Context) (storage.SearchFilter, error) {
+	f := storage.SearchFilter{
+		Site:        c.Query("site"),
+		PathPrefix:  c.Query("path_prefix"),
+		ContentType: c.Query("content_type"),
+	}
+	if f.Site == "" {
+		f.Site = c.Query("host")
+	}
+	var err error
+	if f.CrawledAfter, err = timeQuery(c, "crawled_after"); err != nil {
+		return f, err
+	}
+	if f.CrawledBefore, err = timeQuery(c, "crawled_before"); err != nil {
+		return f, err
+	}
+	return f, nil
+}
+
+// timeQuery читает необязательный параметр времени; нулевое время, если он
+// не задан.
+func timeQuery(c *gin.Context, name string) (time.Time, error) {
+	raw := c.Query(name)
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	t, err := search.ParseTime(raw)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Параметр '%s': %v", name, err)
+	}
+	return t, nil
+}
+
+// snippetOptions читает параметры сниппета: fragments - число фрагментов,
+// fragment_words - максимальная длина фрагмента в словах, hl_start и
+// hl_end - маркеры выделения. Если параметры не заданы, возвращает nil.
+func snippetOptions(c *gin.Context) (*storage.SnippetOptions, error) {
+	opts := storage.DefaultSnippetOptions()
+	changed := false
+
+	if _, ok := c.GetQuery("fragments"); ok {
+		n, err := intQuery(c, "fragments")
+		if err != nil {
+			return nil, err
+		}
+		opts.MaxFragments, changed = n, true
+	}
+	if _, ok := c.GetQuery("fragment_words"); ok {
+		n, err := intQuery(c, "fragment_words")
+		if err != nil {
+			return nil, err
+		}
+		opts.MaxWords, opts.MinWords, changed = n, min(opts.MinWords, max(n/2, 1)), true
+	}
+	if v, ok := c.GetQuery("hl_start"); ok {
+		opts.StartSel, changed = v, true
+	}
+	if v, ok := c.GetQuery("hl_end"); ok {
+		opts.StopSel, changed = v, true
+	}
+
+	if !changed {
+		return nil, nil
+	}
+	return &opts, nil
 }
 
 func (h *Handler) crawlHandler(c *gin.Context) {
 	var request struct {
-		URL string `json:"url"`
+		URL   string              `json:"url"`
+		Scope *storage.CrawlScope `json:"scope"`
+		// Sitemap - URL указывает на файл sitemap.
+		Sitemap bool `json:"sitemap"`
 	}
 
 	if err := c.ShouldBindJSON(&request); err != nil {
@@ -71,7 +363,15 @@ func (h *Handler) crawlHandler(c *gin.Context) {
 		return
 	}
 
-	if err := h.searchService.ScheduleCrawl(c.Request.Context(), request.URL); err != nil {
+	schedule := h.searchService.ScheduleCrawl
+	if request.Sitemap {
+		schedule = h.searchService.ScheduleSitemap
+	}
+	if err := schedule(c.Request.Context(), request.URL, request.Scope); err != nil {
+		if errors.Is(err, search.ErrInvalidRequest) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		log.Printf("ERROR: failed to schedule crawl for url '%s': %v", request.URL, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось добавить URL в очередь"})
 		return
@@ -90,3 +390,33 @@ func (h *Handler) statusHandler(c *gin.Context) {
 
 	c.JSON(http.StatusOK, stats)
 }
+
+func (h *Handler) pageLinksHandler(c *gin.Context) {
+	pageID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || pageID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный идентификатор страницы"})
+		return
+	}
+
+	limit := defaultLinksLimit
+	if raw := c.Query("limit"); raw != "" {
+		limit, err = strconv.Atoi(raw)
+		if err != nil || limit <= 0 || limit > maxLinksLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Параметр 'limit' должен быть от 1 до %d", maxLinksLimit)})
+			return
+		}
+	}
+
+	links, err := h.searchService.GetPageLinks(c.Request.Context(), pageID, limit)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Страница не найдена"})
+			return
+		}
+		log.Printf("ERROR: failed to get links for page %d: %v", pageID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось получить ссылки страницы"})
+		return
+	}
+
+	c.JSON(http.StatusOK, links)
+}
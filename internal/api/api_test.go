@@ -4,37 +4,80 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"cis-engine/internal/search"
 	"cis-engine/internal/storage"
+	"cis-engine/internal/suggest"
 
 	"github.com/stretchr/testify/require"
 )
 
 type mockSearchService struct {
-	searchFunc        func(ctx context.Context, query string) ([]search.Result, error)
-	scheduleCrawlFunc func(ctx context.Context, url string) error
-	getStatsFunc      func(ctx context.Context) (*storage.Metrics, error)
+	searchFunc          func(ctx context.Context, req search.Request) (*search.Response, error)
+	scheduleCrawlFunc   func(ctx context.Context, url string, scope *storage.CrawlScope) error
+	scheduleSitemapFunc func(ctx context.Context, url string, scope *storage.CrawlScope) error
+	getStatsFunc        func(ctx context.Context) (*storage.Metrics, error)
+	getPageLinksFunc    func(ctx context.Context, pageID int64, limit int) (*search.PageLinks, error)
+	suggestFunc         func(ctx context.Context, prefix string, limit int) ([]suggest.Suggestion, error)
+	topQueriesFunc      func(ctx context.Context, req search.AnalyticsRequest, zeroResults bool) ([]*storage.QueryCount, error)
+	queryLatencyFunc    func(ctx context.Context, req search.AnalyticsRequest) (*storage.LatencyStats, error)
 }
 
-func (m *mockSearchService) Search(ctx context.Context, query string) ([]search.Result, error) {
+func (m *mockSearchService) TopQueries(ctx context.Context, req search.AnalyticsRequest, zeroResults bool) ([]*storage.QueryCount, error) {
+	if m.topQueriesFunc != nil {
+		return m.topQueriesFunc(ctx, req, zeroResults)
+	}
+	return nil, errors.New("topQueriesFunc не был определен")
+}
+
+func (m *mockSearchService) QueryLatency(ctx context.Context, req search.AnalyticsRequest) (*storage.LatencyStats, error) {
+	if m.queryLatencyFunc != nil {
+		return m.queryLatencyFunc(ctx, req)
+	}
+	return nil, errors.New("queryLatencyFunc не был определен")
+}
+
+func (m *mockSearchService) Suggest(ctx context.Context, prefix string, limit int) ([]suggest.Suggestion, error) {
+	if m.suggestFunc != nil {
+		return m.suggestFunc(ctx, prefix, limit)
+	}
+	return nil, errors.New("suggestFunc не был определен")
+}
+
+func (m *mockSearchService) GetPageLinks(ctx context.Context, pageID int64, limit int) (*search.PageLinks, error) {
+	if m.getPageLinksFunc != nil {
+		return m.getPageLinksFunc(ctx, pageID, limit)
+	}
+	return nil, errors.New("getPageLinksFunc не был определен")
+}
+
+func (m *mockSearchService) Search(ctx context.Context, req search.Request) (*search.Response, error) {
 	if m.searchFunc != nil {
-		return m.searchFunc(ctx, query)
+		return m.searchFunc(ctx, req)
 	}
 	return nil, errors.New("searchFunc не был определен")
 }
 
-func (m *mockSearchService) ScheduleCrawl(ctx context.Context, url string) error {
+func (m *mockSearchService) ScheduleCrawl(ctx context.Context, url string, scope *storage.CrawlScope) error {
 	if m.scheduleCrawlFunc != nil {
-		return m.scheduleCrawlFunc(ctx, url)
+		return m.scheduleCrawlFunc(ctx, url, scope)
 	}
 	return errors.New("scheduleCrawlFunc не был определен")
 }
 
+func (m *mockSearchService) ScheduleSitemap(ctx context.Context, url string, scope *storage.CrawlScope) error {
+	if m.scheduleSitemapFunc != nil {
+		return m.scheduleSitemapFunc(ctx, url, scope)
+	}
+	return errors.New("scheduleSitemapFunc не был определен")
+}
+
 func (m *mockSearchService) GetStats(ctx context.Context) (*storage.Metrics, error) {
 	if m.getStatsFunc != nil {
 		return m.getStatsFunc(ctx)
@@ -45,15 +88,18 @@ func (m *mockSearchService) GetStats(ctx context.Context) (*storage.Metrics, err
 func TestSearchHandler(t *testing.T) {
 	t.Run("Успешный запрос", func(t *testing.T) {
 		mockService := &mockSearchService{
-			searchFunc: func(ctx context.Context, query string) ([]search.Result, error) {
-				require.Equal(t, "test", query)
-				return []search.Result{{URL: "test.com", Title: "Test"}}, nil
+			searchFunc: func(ctx context.Context, req search.Request) (*search.Response, error) {
+				require.Equal(t, "test", req.Query)
+				require.Nil(t, req.Snippet)
+				require.Equal(t, "client-1", req.ClientID)
+				return &search.Response{Results: []search.Result{{URL: "test.com", Title: "Test"}}, Total: 1}, nil
 			},
 		}
 		handler := NewHandler(mockService)
 		router := NewRouter(handler)
 
 		req := httptest.NewRequest(http.MethodGet, "/api/v1/search?q=test", nil)
+		req.Header.Set("X-Client-ID", "client-1")
 		rec := httptest.NewRecorder()
 
 		router.ServeHTTP(rec, req)
@@ -63,6 +109,49 @@ func TestSearchHandler(t *testing.T) {
 		err := json.Unmarshal(rec.Body.Bytes(), &responseBody)
 		require.NoError(t, err)
 		require.Equal(t, "test", responseBody["query"])
+		require.Equal(t, float64(1), responseBody["total"])
+		require.NotContains(t, responseBody, "next_cursor")
+	})
+
+	t.Run("Постраничная выдача", func(t *testing.T) {
+		mockService := &mockSearchService{
+			searchFunc: func(ctx context.Context, req search.Request) (*search.Response, error) {
+				require.Equal(t, 5, req.Limit)
+				require.Equal(t, 10, req.Offset)
+				require.Equal(t, "abc", req.Cursor)
+				require.Equal(t, "en", req.Lang)
+				require.Equal(t, "bm25", req.Ranker)
+				require.Equal(t, "go.dev", req.Filter.Site)
+				require.Equal(t, "/blog/", req.Filter.PathPrefix)
+				require.Equal(t, 2024, req.Filter.CrawledAfter.Year())
+				require.True(t, req.ShowDuplicates)
+				return &search.Response{Results: []search.Result{}, Total: 42, NextCursor: "def", Suggestion: "tests"}, nil
+			},
+		}
+		router := NewRouter(NewHandler(mockService))
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/search?q=test&limit=5&offset=10&cursor=abc&lang=en&ranker=bm25&host=go.dev&path_prefix=/blog/&crawled_after=2024-01-01&duplicates=true", nil)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Contains(t, rec.Body.String(), `"next_cursor":"def"`)
+		require.Contains(t, rec.Body.String(), `"total":42`)
+		require.Contains(t, rec.Body.String(), `"suggestion":"tests"`)
+
+		req = httptest.NewRequest(http.MethodGet, "/api/v1/search?q=test&limit=many", nil)
+		rec = httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+
+		req = httptest.NewRequest(http.MethodGet, "/api/v1/search?q=test&crawled_before=May", nil)
+		rec = httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+
+		req = httptest.NewRequest(http.MethodGet, "/api/v1/search?q=test&duplicates=maybe", nil)
+		rec = httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusBadRequest, rec.Code)
 	})
 
 	t.Run("Запрос без параметра q", func(t *testing.T) {
@@ -78,9 +167,47 @@ func TestSearchHandler(t *testing.T) {
 		require.Equal(t, http.StatusBadRequest, rec.Code)
 	})
 
+	t.Run("Слишком длинный запрос", func(t *testing.T) {
+		mockService := &mockSearchService{}
+		handler := NewHandler(mockService)
+		router := NewRouter(handler)
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/search?q="+strings.Repeat("a", maxQueryRunes+1), nil)
+		rec := httptest.NewRecorder()
+
+		router.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("Параметры сниппета", func(t *testing.T) {
+		mockService := &mockSearchService{
+			searchFunc: func(ctx context.Context, req search.Request) (*search.Response, error) {
+				require.NotNil(t, req.Snippet)
+				require.Equal(t, 1, req.Snippet.MaxFragments)
+				require.Equal(t, 40, req.Snippet.MaxWords)
+				require.Equal(t, "**", req.Snippet.StartSel)
+				require.Equal(t, "</mark>", req.Snippet.StopSel)
+				return &search.Response{Results: []search.Result{{URL: "test.com", Title: "Test", Snippet: "a **test**"}}}, nil
+			},
+		}
+		router := NewRouter(NewHandler(mockService))
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/search?q=test&fragments=1&fragment_words=40&hl_start=**", nil)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Contains(t, rec.Body.String(), `"snippet":"a **test**"`)
+
+		req = httptest.NewRequest(http.MethodGet, "/api/v1/search?q=test&fragments=x", nil)
+		rec = httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
 	t.Run("Сервис возвращает ошибку", func(t *testing.T) {
 		mockService := &mockSearchService{
-			searchFunc: func(ctx context.Context, query string) ([]search.Result, error) {
+			searchFunc: func(ctx context.Context, req search.Request) (*search.Response, error) {
 				return nil, errors.New("internal error")
 			},
 		}
@@ -101,8 +228,9 @@ func TestCrawlHandler(t *testing.T) {
 		crawlURL := "https://example.com/to-crawl"
 		scheduleCrawlCalled := false
 		mockService := &mockSearchService{
-			scheduleCrawlFunc: func(ctx context.Context, url string) error {
+			scheduleCrawlFunc: func(ctx context.Context, url string, scope *storage.CrawlScope) error {
 				require.Equal(t, crawlURL, url)
+				require.Nil(t, scope)
 				scheduleCrawlCalled = true
 				return nil
 			},
@@ -121,6 +249,66 @@ func TestCrawlHandler(t *testing.T) {
 		require.True(t, scheduleCrawlCalled, "Метод ScheduleCrawl должен был быть вызван")
 	})
 
+	t.Run("Запрос с областью обхода", func(t *testing.T) {
+		mockService := &mockSearchService{
+			scheduleCrawlFunc: func(ctx context.Context, url string, scope *storage.CrawlScope) error {
+				require.NotNil(t, scope)
+				require.Equal(t, storage.ScopeHost, scope.Mode)
+				require.Equal(t, 2, scope.MaxDepth)
+				require.Equal(t, []string{"^/blog/"}, scope.Include)
+				return nil
+			},
+		}
+		handler := NewHandler(mockService)
+		router := NewRouter(handler)
+
+		requestBody := `{"url": "https://example.com", "scope": {"mode": "host", "max_depth": 2, "include": ["^/blog/"]}}`
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/crawl", strings.NewReader(requestBody))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+
+		router.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusAccepted, rec.Code)
+	})
+
+	t.Run("Обход sitemap", func(t *testing.T) {
+		var scheduled string
+		mockService := &mockSearchService{
+			scheduleSitemapFunc: func(ctx context.Context, url string, scope *storage.CrawlScope) error {
+				scheduled = url
+				return nil
+			},
+		}
+		router := NewRouter(NewHandler(mockService))
+
+		requestBody := `{"url": "https://example.com/sitemap.xml", "sitemap": true}`
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/crawl", strings.NewReader(requestBody))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+
+		router.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusAccepted, rec.Code)
+		require.Equal(t, "https://example.com/sitemap.xml", scheduled)
+	})
+
+	t.Run("Некорректная область обхода", func(t *testing.T) {
+		mockService := &mockSearchService{
+			scheduleCrawlFunc: func(ctx context.Context, url string, scope *storage.CrawlScope) error {
+				return fmt.Errorf("%w: bad scope", search.ErrInvalidRequest)
+			},
+		}
+		handler := NewHandler(mockService)
+		router := NewRouter(handler)
+
+		requestBody := `{"url": "https://example.com", "scope": {"mode": "galaxy"}}`
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/crawl", strings.NewReader(requestBody))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+
+		router.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
 	t.Run("Запрос с неверным JSON", func(t *testing.T) {
 		mockService := &mockSearchService{}
 		handler := NewHandler(mockService)
@@ -174,3 +362,177 @@ func TestStatusHandler(t *testing.T) {
 		require.Equal(t, http.StatusInternalServerError, rec.Code)
 	})
 }
+
+func TestPageLinksHandler(t *testing.T) {
+	t.Run("Успешное получение ссылок", func(t *testing.T) {
+		mockService := &mockSearchService{
+			getPageLinksFunc: func(ctx context.Context, pageID int64, limit int) (*search.PageLinks, error) {
+				require.Equal(t, int64(7), pageID)
+				require.Equal(t, 10, limit)
+				return &search.PageLinks{
+					PageID:   7,
+					Outbound: []*storage.Link{{FromPageID: 7, ToURL: "https://go.dev/doc/", AnchorText: "Docs"}},
+					Inbound:  []*storage.Link{},
+				}, nil
+			},
+		}
+		router := NewRouter(NewHandler(mockService))
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/pages/7/links?limit=10", nil)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		var links search.PageLinks
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &links))
+		require.Len(t, links.Outbound, 1)
+		require.Equal(t, "Docs", links.Outbound[0].AnchorText)
+	})
+
+	t.Run("Страница не найдена", func(t *testing.T) {
+		mockService := &mockSearchService{
+			getPageLinksFunc: func(ctx context.Context, pageID int64, limit int) (*search.PageLinks, error) {
+				return nil, fmt.Errorf("страница #%d: %w", pageID, storage.ErrNotFound)
+			},
+		}
+		router := NewRouter(NewHandler(mockService))
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/pages/42/links", nil)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("Некорректный идентификатор", func(t *testing.T) {
+		router := NewRouter(NewHandler(&mockSearchService{}))
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/pages/abc/links", nil)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
+
+func TestSuggestHandler(t *testing.T) {
+	t.Run("Успешное автодополнение", func(t *testing.T) {
+		mockService := &mockSearchService{
+			suggestFunc: func(ctx context.Context, prefix string, limit int) ([]suggest.Suggestion, error) {
+				require.Equal(t, "gor", prefix)
+				require.Equal(t, 5, limit)
+				return []suggest.Suggestion{{Text: "goroutine", Score: 2.5}}, nil
+			},
+		}
+		router := NewRouter(NewHandler(mockService))
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/suggest?prefix=gor&limit=5", nil)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.JSONEq(t, `{"prefix":"gor","suggestions":[{"text":"goroutine","score":2.5}]}`, rec.Body.String())
+	})
+
+	t.Run("Некорректные параметры", func(t *testing.T) {
+		mockService := &mockSearchService{
+			suggestFunc: func(ctx context.Context, prefix string, limit int) ([]suggest.Suggestion, error) {
+				return nil, search.ErrInvalidRequest
+			},
+		}
+		router := NewRouter(NewHandler(mockService))
+
+		for _, target := range []string{"/api/v1/suggest", "/api/v1/suggest?prefix=go&limit=x", "/api/v1/suggest?prefix=go&limit=500"} {
+			req := httptest.NewRequest(http.MethodGet, target, nil)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			require.Equal(t, http.StatusBadRequest, rec.Code, target)
+		}
+	})
+}
+
+func TestAnalyticsHandlers(t *testing.T) {
+	t.Run("Частые запросы", func(t *testing.T) {
+		var zero []bool
+		mockService := &mockSearchService{
+			topQueriesFunc: func(ctx context.Context, req search.AnalyticsRequest, zeroResults bool) ([]*storage.QueryCount, error) {
+				require.Equal(t, 5, req.Limit)
+				require.Equal(t, 7*24*time.Hour, req.To.Sub(req.From))
+				zero = append(zero, zeroResults)
+				return []*storage.QueryCount{{Query: "go", Count: 3, Clients: 2}}, nil
+			},
+		}
+		router := NewRouter(NewHandler(mockService))
+
+		for _, target := range []string{"/api/v1/analytics/queries/top", "/api/v1/analytics/queries/zero-results"} {
+			req := httptest.NewRequest(http.MethodGet, target+"?window=168h&limit=5", nil)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			require.Equal(t, http.StatusOK, rec.Code, target)
+			var body struct {
+				Queries []storage.QueryCount `json:"queries"`
+			}
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+			require.Equal(t, "go", body.Queries[0].Query)
+			require.Equal(t, int64(3), body.Queries[0].Count)
+		}
+		require.Equal(t, []bool{false, true}, zero)
+	})
+
+	t.Run("Время ответа", func(t *testing.T) {
+		mockService := &mockSearchService{
+			queryLatencyFunc: func(ctx context.Context, req search.AnalyticsRequest) (*storage.LatencyStats, error) {
+				require.Equal(t, 2024, req.From.Year())
+				require.Equal(t, time.March, req.To.Month())
+				return &storage.LatencyStats{
+					Count: 3,
+					P50:   20 * time.Millisecond,
+					P90:   1500 * time.Microsecond,
+					P99:   time.Second,
+					Buckets: []storage.LatencyBucket{
+						{UpperBound: 50 * time.Millisecond, Count: 2},
+						{Count: 1},
+					},
+				}, nil
+			},
+		}
+		router := NewRouter(NewHandler(mockService))
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/analytics/queries/latency?from=2024-01-01&to=2024-03-01", nil)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		var body map[string]any
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		require.Equal(t, float64(3), body["count"])
+		require.Equal(t, float64(20), body["p50_ms"])
+		require.Equal(t, 1.5, body["p90_ms"])
+		require.Equal(t, []any{
+			map[string]any{"lt_ms": float64(50), "count": float64(2)},
+			map[string]any{"count": float64(1)},
+		}, body["buckets"])
+	})
+
+	t.Run("Некорректные параметры", func(t *testing.T) {
+		mockService := &mockSearchService{
+			topQueriesFunc: func(ctx context.Context, req search.AnalyticsRequest, zeroResults bool) ([]*storage.QueryCount, error) {
+				return nil, search.ErrInvalidRequest
+			},
+		}
+		router := NewRouter(NewHandler(mockService))
+
+		for _, target := range []string{
+			"/api/v1/analytics/queries/top?window=week",
+			"/api/v1/analytics/queries/top?from=2024-01-01&window=24h",
+			"/api/v1/analytics/queries/top?to=yesterday",
+			"/api/v1/analytics/queries/top?limit=5000",
+		} {
+			req := httptest.NewRequest(http.MethodGet, target, nil)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			require.Equal(t, http.StatusBadRequest, rec.Code, target)
+		}
+	})
+}
@@ -0,0 +1,158 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	analyticsWindow string
+	analyticsFrom   string
+	analyticsTo     string
+	analyticsLimit  int
+)
+
+var analyticsCmd = &cobra.Command{
+	Use:   "analytics",
+	Short: "Статистика поисковых запросов",
+	Long: `Выводит статистику журнала поисковых запросов за период: самые частые запросы (top), запросы без результатов (zero) и распределение времени ответа (latency).
+
+Период задается флагом --window (по умолчанию 24h) или флагами --from и --to в формате 2006-01-02 или RFC 3339.`,
+}
+
+var analyticsTopCmd = &cobra.Command{
+	Use:   "top",
+	Short: "Самые частые запросы",
+	Run: func(cmd *cobra.Command, args []string) {
+		printTopQueries("/api/v1/analytics/queries/top")
+	},
+}
+
+var analyticsZeroCmd = &cobra.Command{
+	Use:   "zero",
+	Short: "Частые запросы без результатов",
+	Run: func(cmd *cobra.Command, args []string) {
+		printTopQueries("/api/v1/analytics/queries/zero-results")
+	},
+}
+
+var analyticsLatencyCmd = &cobra.Command{
+	Use:   "latency",
+	Short: "Распределение времени ответа",
+	Run: func(cmd *cobra.Command, args []string) {
+		var result struct {
+			Count   int64   `json:"count"`
+			P50     float64 `json:"p50_ms"`
+			P90     float64 `json:"p90_ms"`
+			P99     float64 `json:"p99_ms"`
+			Buckets []struct {
+				LessThan *float64 `json:"lt_ms"`
+				Count    int64    `json:"count"`
+			} `json:"buckets"`
+		}
+		if !getAnalytics("/api/v1/analytics/queries/latency", &result) {
+			return
+		}
+
+		fmt.Printf("Запросов: %d\n", result.Count)
+		if result.Count == 0 {
+			return
+		}
+		fmt.Printf("p50: %.1f мс, p90: %.1f мс, p99: %.1f мс\n\n", result.P50, result.P90, result.P99)
+		prev := 0.0
+		for _, b := range result.Buckets {
+			if b.LessThan == nil {
+				fmt.Printf("  >= %7.0f мс: %d\n", prev, b.Count)
+				continue
+			}
+			fmt.Printf("  <  %7.0f мс: %d\n", *b.LessThan, b.Count)
+			prev = *b.LessThan
+		}
+	},
+}
+
+func printTopQueries(path string) {
+	var result struct {
+		Queries []struct {
+			Query      string  `json:"query"`
+			Count      int64   `json:"count"`
+			Clients    int64   `json:"clients"`
+			AvgResults float64 `json:"avg_results"`
+		} `json:"queries"`
+	}
+	if !getAnalytics(path, &result) {
+		return
+	}
+
+	if len(result.Queries) == 0 {
+		fmt.Println("Запросов за период нет.")
+		return
+	}
+	fmt.Printf("%8s %8s %10s  %s\n", "Запросов", "Клиентов", "Найдено", "Запрос")
+	for _, q := range result.Queries {
+		fmt.Printf("%8d %8d %10.1f  %s\n", q.Count, q.Clients, q.AvgResults, q.Query)
+	}
+}
+
+// getAnalytics запрашивает отчет аналитики с параметрами периода из флагов
+// и разбирает ответ в out; при ошибке выводит ее и возвращает false.
+func getAnalytics(path string, out any) bool {
+	fullURL, err := url.Parse(apiBaseURL)
+	if err != nil {
+		fmt.Printf("Ошибка: неверный формат базового URL API: %v\n", err)
+		return false
+	}
+	fullURL.Path = path
+	q := fullURL.Query()
+	if analyticsFrom != "" {
+		q.Set("from", analyticsFrom)
+	} else if analyticsWindow != "" {
+		q.Set("window", analyticsWindow)
+	}
+	if analyticsTo != "" {
+		q.Set("to", analyticsTo)
+	}
+	if analyticsLimit > 0 {
+		q.Set("limit", strconv.Itoa(analyticsLimit))
+	}
+	fullURL.RawQuery = q.Encode()
+
+	resp, err := http.Get(fullURL.String())
+	if err != nil {
+		fmt.Printf("Ошибка при выполнении запроса к API: %v\n", err)
+		return false
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		fmt.Printf("Ошибка при чтении ответа от API: %v\n", err)
+		return false
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		fmt.Printf("API вернуло ошибку (статус %d): %s\n", resp.StatusCode, string(body))
+		return false
+	}
+
+	if err := json.Unmarshal(body, out); err != nil {
+		fmt.Printf("Ошибка при парсинге JSON ответа от API: %v\n", err)
+		return false
+	}
+	return true
+}
+
+func init() {
+	analyticsCmd.PersistentFlags().StringVar(&analyticsWindow, "window", "24h", "Длительность периода до --to")
+	analyticsCmd.PersistentFlags().StringVar(&analyticsFrom, "from", "", "Начало периода (заменяет --window)")
+	analyticsCmd.PersistentFlags().StringVar(&analyticsTo, "to", "", "Конец периода (по умолчанию - текущий момент)")
+	analyticsCmd.PersistentFlags().IntVar(&analyticsLimit, "limit", 20, "Число запросов в отчетах top и zero")
+	analyticsCmd.AddCommand(analyticsTopCmd, analyticsZeroCmd, analyticsLatencyCmd)
+	rootCmd.AddCommand(analyticsCmd)
+}
@@ -14,8 +14,10 @@ import (
 var crawlCmd = &cobra.Command{
 	Use:   "crawl [url]",
 	Short: "Добавить новый URL для сканирования",
-	Long:  `Отправляет запрос на API, чтобы добавить новый URL в очередь на сканирование краулером.`,
-	Args:  cobra.ExactArgs(1),
+	Long: `Отправляет запрос на API, чтобы добавить новый URL в очередь на сканирование краулером.
+
+С флагом --sitemap URL считается адресом файла sitemap (в том числе индекса sitemap или сжатого gzip): краулер добавит в очередь перечисленные в нем страницы с учетом lastmod, priority и changefreq.`,
+	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		targetURL := args[0]
 
@@ -26,7 +28,17 @@ var crawlCmd = &cobra.Command{
 		}
 		fullURL.Path = "/api/v1/crawl"
 
-		requestBody, err := json.Marshal(map[string]string{"url": targetURL})
+		request := map[string]any{"url": targetURL}
+		scopeFlags := cmd.LocalFlags().NFlag()
+		if crawlSitemap {
+			request["sitemap"] = true
+			scopeFlags--
+		}
+		if scopeFlags > 0 {
+			request["scope"] = crawlScope
+		}
+
+		requestBody, err := json.Marshal(request)
 		if err != nil {
 			fmt.Printf("Ошибка при создании JSON-запроса: %v\n", err)
 			return
@@ -51,6 +63,24 @@ var crawlCmd = &cobra.Command{
 	},
 }
 
+var crawlSitemap bool
+
+var crawlScope struct {
+	Mode            string   `json:"mode,omitempty"`
+	AllowedDomains  []string `json:"allowed_domains,omitempty"`
+	MaxDepth        int      `json:"max_depth,omitempty"`
+	MaxPagesPerHost int      `json:"max_pages_per_host,omitempty"`
+	Include         []string `json:"include,omitempty"`
+	Exclude         []string `json:"exclude,omitempty"`
+}
+
 func init() {
+	crawlCmd.Flags().StringVar(&crawlScope.Mode, "scope", "", "Область обхода: host, domain (по умолчанию), list или any")
+	crawlCmd.Flags().StringSliceVar(&crawlScope.AllowedDomains, "allow-domain", nil, "Разрешенные домены для --scope=list")
+	crawlCmd.Flags().IntVar(&crawlScope.MaxDepth, "max-depth", 0, "Максимальная глубина ссылок от начального URL")
+	crawlCmd.Flags().IntVar(&crawlScope.MaxPagesPerHost, "max-pages-per-host", 0, "Максимум страниц с одного хоста")
+	crawlCmd.Flags().StringArrayVar(&crawlScope.Include, "include", nil, "Регулярное выражение для пути URL, которые нужно обходить")
+	crawlCmd.Flags().StringArrayVar(&crawlScope.Exclude, "exclude", nil, "Регулярное выражение для пути URL, которые нужно пропускать")
+	crawlCmd.Flags().BoolVar(&crawlSitemap, "sitemap", false, "URL - адрес файла sitemap, страницы из которого нужно обойти")
 	rootCmd.AddCommand(crawlCmd)
 }
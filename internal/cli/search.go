@@ -6,6 +6,9 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"os"
+	"strconv"
+	"strings"
 
 	"github.com/spf13/cobra"
 )
@@ -13,10 +16,19 @@ import (
 var searchCmd = &cobra.Command{
 	Use:   "search [поисковый запрос]",
 	Short: "Выполнить поиск документов",
-	Long:  `Отправляет поисковый запрос к API и выводит найденные результаты.`,
-	Args:  cobra.MinimumNArgs(1),
+	Long: `Отправляет поисковый запрос к API и выводит найденные результаты.
+
+Фильтры можно указать флагами или операторами в тексте запроса:
+site:, path:, lang:, type:, after: и before:, например
+"goroutines site:go.dev after:2024-01-01".`,
+	Args: cobra.MinimumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		query := args[0]
+		if searchLimit <= 0 || searchPage <= 0 {
+			fmt.Println("Ошибка: --limit и --page должны быть положительными")
+			return
+		}
+		offset := (searchPage - 1) * searchLimit
 
 		fullURL, err := url.Parse(apiBaseURL)
 		if err != nil {
@@ -26,6 +38,29 @@ var searchCmd = &cobra.Command{
 		fullURL.Path = "/api/v1/search"
 		q := fullURL.Query()
 		q.Set("q", query)
+		q.Set("fragments", strconv.Itoa(searchFragments))
+		q.Set("hl_start", highlightStart)
+		q.Set("hl_end", highlightEnd)
+		q.Set("limit", strconv.Itoa(searchLimit))
+		q.Set("offset", strconv.Itoa(offset))
+		if searchLang != "" {
+			q.Set("lang", searchLang)
+		}
+		if searchDuplicates {
+			q.Set("duplicates", "true")
+		}
+		for name, value := range map[string]string{
+			"ranker":         searchRanker,
+			"site":           searchSite,
+			"path_prefix":    searchPathPrefix,
+			"crawled_after":  searchAfter,
+			"crawled_before": searchBefore,
+			"content_type":   searchContentType,
+		} {
+			if value != "" {
+				q.Set(name, value)
+			}
+		}
 		fullURL.RawQuery = q.Encode()
 
 		fmt.Printf("Отправка запроса на: %s\n", fullURL.String())
@@ -51,9 +86,14 @@ var searchCmd = &cobra.Command{
 		var result struct {
 			Query   string `json:"query"`
 			Results []struct {
-				URL   string `json:"url"`
-				Title string `json:"title"`
+				URL        string  `json:"url"`
+				Title      string  `json:"title"`
+				Snippet    string  `json:"snippet"`
+				Score      float64 `json:"score"`
+				Duplicates int     `json:"duplicates"`
 			} `json:"results"`
+			Total      int64  `json:"total"`
+			Suggestion string `json:"suggestion"`
 		}
 
 		if err := json.Unmarshal(body, &result); err != nil {
@@ -62,16 +102,86 @@ var searchCmd = &cobra.Command{
 		}
 
 		fmt.Printf("\nРезультаты поиска по запросу \"%s\":\n", result.Query)
+		if result.Suggestion != "" {
+			fmt.Printf("Возможно, вы имели в виду: %s\n", result.Suggestion)
+		}
 		if len(result.Results) == 0 {
-			fmt.Println("Ничего не найдено.")
+			if result.Total > 0 {
+				fmt.Printf("На странице %d результатов нет (всего найдено: %d).\n", searchPage, result.Total)
+			} else {
+				fmt.Println("Ничего не найдено.")
+			}
 			return
 		}
+		fmt.Printf("Показаны результаты %d–%d из %d (страница %d).\n\n",
+			offset+1, offset+len(result.Results), result.Total, searchPage)
+		color := useColor()
 		for i, r := range result.Results {
-			fmt.Printf("%d. %s\n   %s\n", i+1, r.Title, r.URL)
+			fmt.Printf("%d. %s [%.4f]\n   %s\n", offset+i+1, r.Title, r.Score, r.URL)
+			if r.Snippet != "" {
+				fmt.Printf("   %s\n", highlight(r.Snippet, color))
+			}
+			if r.Duplicates > 0 {
+				fmt.Printf("   Похожих страниц скрыто: %d\n", r.Duplicates)
+			}
 		}
 	},
 }
 
+// Маркеры выделения, которые CLI запрашивает у API. Управляющие символы не
+// встречаются в тексте страниц, поэтому их можно безопасно заменить.
+const (
+	highlightStart = "\x02"
+	highlightEnd   = "\x03"
+)
+
+var (
+	searchFragments int
+	searchLimit     int
+	searchPage      int
+	searchLang      string
+	searchRanker    string
+	// searchDuplicates отключает схлопывание почти дубликатов.
+	searchDuplicates bool
+
+	searchSite        string
+	searchPathPrefix  string
+	searchAfter       string
+	searchBefore      string
+	searchContentType string
+)
+
+// highlight заменяет маркеры выделения на ANSI-последовательности или
+// удаляет их, если цвет отключен.
+func highlight(snippet string, color bool) string {
+	start, end := "", ""
+	if color {
+		start, end = "\x1b[1;33m", "\x1b[0m"
+	}
+	return strings.NewReplacer(highlightStart, start, highlightEnd, end).Replace(snippet)
+}
+
+// useColor сообщает, выводится ли результат в терминал. Переменная
+// окружения NO_COLOR отключает цвет.
+func useColor() bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
 func init() {
+	searchCmd.Flags().IntVar(&searchFragments, "fragments", 2, "Число фрагментов текста в сниппете (0 - один отрывок без деления на фрагменты)")
+	searchCmd.Flags().IntVar(&searchLimit, "limit", 10, "Число результатов на странице")
+	searchCmd.Flags().IntVar(&searchPage, "page", 1, "Номер страницы выдачи, начиная с 1")
+	searchCmd.Flags().StringVar(&searchLang, "lang", "", "Язык запроса, например en или ru (по умолчанию - все языки)")
+	searchCmd.Flags().StringVar(&searchRanker, "ranker", "", "Ранжирование: ts_rank или bm25 (по умолчанию - ts_rank)")
+	searchCmd.Flags().StringVar(&searchSite, "site", "", "Искать только на этом сайте и его поддоменах")
+	searchCmd.Flags().StringVar(&searchPathPrefix, "path-prefix", "", "Искать только страницы, путь которых начинается с префикса, например /blog/")
+	searchCmd.Flags().StringVar(&searchAfter, "after", "", "Только страницы, загруженные начиная с этой даты (YYYY-MM-DD или RFC 3339)")
+	searchCmd.Flags().StringVar(&searchBefore, "before", "", "Только страницы, загруженные до этой даты (YYYY-MM-DD или RFC 3339)")
+	searchCmd.Flags().BoolVar(&searchDuplicates, "duplicates", false, "Показывать почти дубликаты страниц (зеркала, версии для печати)")
+	searchCmd.Flags().StringVar(&searchContentType, "type", "", "Тип содержимого, например text/html")
 	rootCmd.AddCommand(searchCmd)
 }
@@ -43,7 +43,9 @@ var statusCmd = &cobra.Command{
 		}
 
 		var result struct {
-			PagesCount int64 `json:"pages_count"`
+			PagesCount    int64 `json:"pages_count"`
+			FrontierCount int64 `json:"frontier_count"`
+			FrontierDue   int64 `json:"frontier_due"`
 		}
 
 		if err := json.Unmarshal(body, &result); err != nil {
@@ -53,6 +55,7 @@ var statusCmd = &cobra.Command{
 
 		fmt.Println("\n--- Статус Системы ---")
 		fmt.Printf("Всего страниц в индексе: %d\n", result.PagesCount)
+		fmt.Printf("URL в очереди краулера: %d (готовы к загрузке: %d)\n", result.FrontierCount, result.FrontierDue)
 		fmt.Println("----------------------")
 	},
 }
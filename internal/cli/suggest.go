@@ -0,0 +1,75 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var suggestLimit int
+
+var suggestCmd = &cobra.Command{
+	Use:   "suggest [начало запроса]",
+	Short: "Показать варианты дополнения запроса",
+	Long:  `Отправляет начало запроса к API и выводит варианты его продолжения, построенные по заголовкам страниц, частым словам и прошлым запросам.`,
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		fullURL, err := url.Parse(apiBaseURL)
+		if err != nil {
+			fmt.Printf("Ошибка: неверный формат базового URL API: %v\n", err)
+			return
+		}
+		fullURL.Path = "/api/v1/suggest"
+		q := fullURL.Query()
+		q.Set("prefix", strings.Join(args, " "))
+		q.Set("limit", strconv.Itoa(suggestLimit))
+		fullURL.RawQuery = q.Encode()
+
+		resp, err := http.Get(fullURL.String())
+		if err != nil {
+			fmt.Printf("Ошибка при выполнении запроса к API: %v\n", err)
+			return
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			fmt.Printf("Ошибка при чтении ответа от API: %v\n", err)
+			return
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			fmt.Printf("API вернуло ошибку (статус %d): %s\n", resp.StatusCode, string(body))
+			return
+		}
+
+		var result struct {
+			Suggestions []struct {
+				Text string `json:"text"`
+			} `json:"suggestions"`
+		}
+		if err := json.Unmarshal(body, &result); err != nil {
+			fmt.Printf("Ошибка при парсинге JSON ответа от API: %v\n", err)
+			return
+		}
+
+		if len(result.Suggestions) == 0 {
+			fmt.Println("Подсказок нет.")
+			return
+		}
+		for _, s := range result.Suggestions {
+			fmt.Println(s.Text)
+		}
+	},
+}
+
+func init() {
+	suggestCmd.Flags().IntVar(&suggestLimit, "limit", 10, "Максимальное число подсказок")
+	rootCmd.AddCommand(suggestCmd)
+}
@@ -25,3 +25,25 @@ func (vc *VisitedCache) AddIfNotExists(url string) bool {
 	vc.items[url] = true
 	return true
 }
+
+// hostCounter считает загруженные страницы по хостам.
+type hostCounter struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func newHostCounter() *hostCounter {
+	return &hostCounter{counts: make(map[string]int)}
+}
+
+func (hc *hostCounter) inc(host string) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	hc.counts[host]++
+}
+
+func (hc *hostCounter) get(host string) int {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	return hc.counts[host]
+}
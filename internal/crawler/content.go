@@ -0,0 +1,236 @@
+package crawler
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+// Выделение основного текста страницы по образцу Readability: текстовые
+// блоки начисляют очки родителю и, вдвое меньше, его родителю; очки
+// кандидата уменьшаются пропорционально доле текста ссылок. Навигация,
+// колонтитулы, боковые панели и скрытые элементы не рассматриваются.
+
+const (
+	// minBlockText - блоки короче этого числа символов не учитываются.
+	minBlockText = 25
+	// minSiblingScore и siblingScoreRatio - соседние блоки попадают в
+	// основной текст, если их очки не меньше обеих границ.
+	minSiblingScore   = 10
+	siblingScoreRatio = 0.2
+)
+
+// boilerplateTags - элементы, которые не входят в основной текст.
+var boilerplateTags = map[string]bool{
+	"nav": true, "header": true, "footer": true, "aside": true,
+	"form": true, "button": true, "select": true, "iframe": true, "svg": true,
+}
+
+// boilerplateRoles - значения атрибута role у элементов тех же видов.
+var boilerplateRoles = map[string]bool{
+	"navigation": true, "banner": true, "contentinfo": true, "complementary": true,
+	"dialog": true, "alertdialog": true, "menu": true, "menubar": true,
+}
+
+var (
+	// boilerplateHints - class и id баннеров о cookie и подобных блоков,
+	// которые исключаются целиком.
+	boilerplateHints = regexp.MustCompile(`(?i)cookie|consent|gdpr|popup|modal`)
+	negativeHints    = regexp.MustCompile(`(?i)comment|sidebar|footer|menu|nav|banner|share|social|related|promo|sponsor|advert|widget|breadcrumb|pagination|pager`)
+	positiveHints    = regexp.MustCompile(`(?i)article|content|main|post|entry|text|body|story`)
+)
+
+// hidden сообщает, скрыт ли элемент атрибутами hidden, aria-hidden или
+// стилем display: none и visibility: hidden.
+func hidden(n *html.Node) bool {
+	if _, ok := attr(n, "hidden"); ok {
+		return true
+	}
+	if v, _ := attr(n, "aria-hidden"); strings.EqualFold(v, "true") {
+		return true
+	}
+	style, _ := attr(n, "style")
+	style = strings.ToLower(strings.Join(strings.Fields(style), ""))
+	return strings.Contains(style, "display:none") || strings.Contains(style, "visibility:hidden")
+}
+
+// invisible сообщает, что текст элемента не отображается: скрипты, стили,
+// шаблоны, noscript и скрытые элементы.
+func invisible(n *html.Node) bool {
+	switch n.Data {
+	case "script", "style", "template", "noscript":
+		return true
+	}
+	return hidden(n)
+}
+
+// boilerplate сообщает, что элемент не входит в основной текст.
+func boilerplate(n *html.Node) bool {
+	if n.Type != html.ElementNode {
+		return false
+	}
+	if invisible(n) || boilerplateTags[n.Data] {
+		return true
+	}
+	if role, _ := attr(n, "role"); boilerplateRoles[strings.ToLower(role)] {
+		return true
+	}
+	class, _ := attr(n, "class")
+	id, _ := attr(n, "id")
+	return boilerplateHints.MatchString(class + " " + id)
+}
+
+// textStats считает видимый текст элемента вне служебных блоков и долю
+// текста ссылок в нем.
+func textStats(n *html.Node) (text string, linkDensity float64) {
+	var b strings.Builder
+	var linkChars int
+	var f func(*html.Node, bool)
+	f = func(n *html.Node, inLink bool) {
+		if boilerplate(n) {
+			return
+		}
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+			b.WriteString(" ")
+			if inLink {
+				linkChars += utf8.RuneCountInString(strings.TrimSpace(n.Data))
+			}
+			return
+		}
+		inLink = inLink || (n.Type == html.ElementNode && n.Data == "a")
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			f(child, inLink)
+		}
+	}
+	f(n, false)
+
+	text = strings.Join(strings.Fields(b.String()), " ")
+	if chars := utf8.RuneCountInString(text); chars > 0 {
+		linkDensity = min(float64(linkChars)/float64(chars), 1)
+	}
+	return text, linkDensity
+}
+
+// blockTags - элементы, текст которых оценивается как отдельный блок.
+var blockTags = map[string]bool{
+	"p": true, "pre": true, "blockquote": true, "td": true, "dd": true, "li": true,
+	"div": true, "section": true, "article": true, "main": true,
+}
+
+// hasBlockChildren сообщает, есть ли среди потомков элемента блоки.
+func hasBlockChildren(n *html.Node) bool {
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.ElementNode && (blockTags[child.Data] || hasBlockChildren(child)) {
+			return true
+		}
+	}
+	return false
+}
+
+// initialScore - очки кандидата по его тегу, class и id.
+func initialScore(n *html.Node) float64 {
+	var score float64
+	switch n.Data {
+	case "article", "main":
+		score = 10
+	case "div":
+		score = 5
+	case "pre", "td", "blockquote":
+		score = 3
+	case "ol", "ul", "dl", "dd", "dt", "li":
+		score = -3
+	case "h1", "h2", "h3", "h4", "h5", "h6", "th":
+		score = -5
+	}
+	class, _ := attr(n, "class")
+	id, _ := attr(n, "id")
+	hints := class + " " + id
+	if negativeHints.MatchString(hints) {
+		score -= 25
+	}
+	if positiveHints.MatchString(hints) {
+		score += 25
+	}
+	return score
+}
+
+// extractContent выделяет основной текст документа; пустая строка, если
+// текстовых блоков в нем нет.
+func extractContent(doc *html.Node) string {
+	scores := make(map[*html.Node]float64)
+	var order []*html.Node
+	addScore := func(n *html.Node, score float64) {
+		if n == nil || n.Type != html.ElementNode {
+			return
+		}
+		if _, ok := scores[n]; !ok {
+			scores[n] = initialScore(n)
+			order = append(order, n)
+		}
+		scores[n] += score
+	}
+
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if boilerplate(n) {
+			return
+		}
+		if n.Type == html.ElementNode && blockTags[n.Data] &&
+			(n.Data != "div" && n.Data != "section" && n.Data != "article" && n.Data != "main" || !hasBlockChildren(n)) {
+			text, _ := textStats(n)
+			if chars := utf8.RuneCountInString(text); chars >= minBlockText {
+				score := 1 + float64(strings.Count(text, ",")) + min(float64(chars)/100, 3)
+				addScore(n.Parent, score)
+				if n.Parent != nil {
+					addScore(n.Parent.Parent, score/2)
+				}
+			}
+			return
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(doc)
+
+	var best *html.Node
+	var bestScore float64
+	for _, n := range order {
+		_, density := textStats(n)
+		scores[n] *= 1 - density
+		if best == nil || scores[n] > bestScore {
+			best, bestScore = n, scores[n]
+		}
+	}
+	if best == nil {
+		return ""
+	}
+	if best.Parent == nil {
+		text, _ := textStats(best)
+		return text
+	}
+
+	// Основной текст нередко разбит между соседними элементами, например
+	// абзацами статьи и блоком с ее продолжением.
+	threshold := max(minSiblingScore, bestScore*siblingScoreRatio)
+	var parts []string
+	for sibling := best.Parent.FirstChild; sibling != nil; sibling = sibling.NextSibling {
+		if sibling.Type != html.ElementNode || boilerplate(sibling) {
+			continue
+		}
+		text, density := textStats(sibling)
+		score, scored := scores[sibling]
+		include := sibling == best || (scored && score >= threshold)
+		if !include && sibling.Data == "p" {
+			chars := utf8.RuneCountInString(text)
+			include = (chars > 80 && density < 0.25) || (chars > 0 && density == 0 && strings.Contains(text, ". "))
+		}
+		if include && text != "" {
+			parts = append(parts, text)
+		}
+	}
+	return strings.Join(parts, " ")
+}
@@ -1,124 +1,594 @@
 package crawler
 
 import (
+	"cis-engine/internal/dedup"
+	"cis-engine/internal/lang"
+	"cis-engine/internal/robots"
 	"cis-engine/internal/storage"
+	"cis-engine/internal/urlnorm"
+	"cmp"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"io"
 	"log"
+	"net/http"
 	"net/url"
+	"os"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"golang.org/x/net/html"
-	"golang.org/x/time/rate"
 )
 
 type Page struct {
-	URL   string
-	Title string
-	Body  string
+	storage.Page
+	// Canonical - нормализованный адрес из <link rel="canonical">.
+	Canonical string
+}
+
+const (
+	seedPriority    = 10
+	leaseDuration   = 15 * time.Minute
+	revisitInterval = 24 * time.Hour
+	// Границы интервала повторного обхода, который сокращается вдвое после
+	// изменения страницы и растет в полтора раза, пока она не меняется.
+	minRevisitInterval = time.Hour
+	maxRevisitInterval = 30 * 24 * time.Hour
+	retryBackoff       = time.Minute
+	maxAttempts        = 3
+	robotsTTL          = 24 * time.Hour
+	robotsTimeout      = 10 * time.Second
+	maxCrawlDelay      = 30 * time.Second
+	// maxBodySize - тело ответа обрезается до этого размера, остаток не читается.
+	maxBodySize = 10 << 20
+	// maxRequeueDelay - дольше этой паузы задание не держится в памяти,
+	// а возвращается в очередь в базе данных.
+	maxRequeueDelay = time.Minute
+)
+
+// Config задает параметры обхода.
+type Config struct {
+	Workers int
+	// HostRate - максимальное число запросов в секунду к одному хосту.
+	HostRate float64
+	// HostConnections - максимальное число одновременных запросов к одному хосту.
+	HostConnections int
+	// MaxBackoff ограничивает паузу для хоста после ответов 429/503.
+	MaxBackoff time.Duration
+	// Scope - область обхода для URL, у которых в очереди она не задана.
+	Scope storage.CrawlScope
+	// Normalization - правила нормализации найденных URL.
+	Normalization urlnorm.Options
+	// PollInterval - как часто проверять очередь, когда в ней нет готовых URL.
+	PollInterval time.Duration
+	// CompressRaw включает сжатие gzip исходного тела страниц при сохранении.
+	CompressRaw bool
+	// Sitemaps включает поиск sitemap хостов через robots.txt и /sitemap.xml.
+	Sitemaps bool
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Workers:         5,
+		HostRate:        1,
+		HostConnections: 2,
+		MaxBackoff:      10 * time.Minute,
+		Scope:           storage.CrawlScope{Mode: storage.ScopeDomain},
+		Normalization:   urlnorm.Default(),
+		PollInterval:    2 * time.Second,
+		CompressRaw:     true,
+		Sitemaps:        true,
+	}
 }
 
 type Crawler struct {
-	jobs    chan string
+	cfg     Config
+	sched   *hostScheduler
 	results chan *Page
-	wg      sync.WaitGroup
-	limiter *rate.Limiter
 	storage storage.Storer
 	fetcher Fetcher
 	visited *VisitedCache
-
-	workers int
+	robots  *robots.Cache
+	scopes  *scopeCache
+	pages   *hostCounter
+	owner   string
+	// sitemapOrigins - хосты, для которых уже искались sitemap.
+	sitemapOrigins *VisitedCache
+	parsers        *Parsers
+
+	// runCtx отменяется, если Stop не дождался завершения загрузок.
+	runCtx    context.Context
+	cancelRun context.CancelFunc
+	workersWG sync.WaitGroup
+	resultsWG sync.WaitGroup
+	done      chan struct{}
+	stopOnce  sync.Once
+	stopErr   error
+	// inflight - задания, выданные планировщику и еще не обработанные.
+	inflight   atomic.Int64
+	finished   chan struct{}
+	finishOnce sync.Once
 }
 
-func NewCrawler(workers int, requestsPerSec int, s storage.Storer, f Fetcher) *Crawler {
-	limiter := rate.NewLimiter(rate.Every(time.Second/time.Duration(requestsPerSec)), 1)
+func NewCrawler(cfg Config, s storage.Storer, f Fetcher) *Crawler {
+	hostname, _ := os.Hostname()
 
 	return &Crawler{
-		jobs:    make(chan string, workers*2),
-		results: make(chan *Page, workers*2),
-		limiter: limiter,
-		storage: s,
-		fetcher: f,
-		workers: workers,
-		visited: NewVisitedCache(),
+		cfg:      cfg,
+		sched:    newHostScheduler(cfg.HostRate, cfg.HostConnections, cfg.MaxBackoff),
+		results:  make(chan *Page, cfg.Workers*2),
+		storage:  s,
+		fetcher:  f,
+		visited:  NewVisitedCache(),
+		robots:   robots.NewCache(&http.Client{Timeout: robotsTimeout}, userAgent, robotsTTL),
+		scopes:   newScopeCache(),
+		pages:    newHostCounter(),
+		owner:    fmt.Sprintf("%s-%d", hostname, os.Getpid()),
+		done:     make(chan struct{}),
+		finished: make(chan struct{}),
+
+		sitemapOrigins: NewVisitedCache(),
+		parsers:        NewParsers(),
 	}
 }
 
+// Start добавляет начальные URL в очередь и запускает воркеров. Метод не
+// блокируется; для остановки используется Stop.
 func (c *Crawler) Start(ctx context.Context, seedURLs []string) {
-	c.wg.Add(1)
-	go c.processResults(ctx)
+	c.runCtx, c.cancelRun = context.WithCancel(ctx)
 
-	for i := 1; i <= c.workers; i++ {
-		c.wg.Add(1)
-		go c.worker(ctx, i)
+	for _, u := range seedURLs {
+		if err := c.AddJob(c.runCtx, u); err != nil {
+			log.Printf("Не удалось добавить начальный URL %s: %v", u, err)
+		}
 	}
 
-	for _, u := range seedURLs {
-		c.AddJob(u)
+	c.resultsWG.Add(1)
+	go c.processResults(c.runCtx)
+
+	for i := 1; i <= c.cfg.Workers; i++ {
+		c.workersWG.Add(1)
+		go c.worker(c.runCtx, i)
 	}
+
+	c.workersWG.Add(1)
+	go c.feed(c.runCtx)
 }
 
-func (c *Crawler) Stop() {
-	close(c.jobs)
-	c.wg.Wait()
-	close(c.results)
+// Done закрывается, когда в очереди не осталось готовых к загрузке URL и
+// все выданные задания обработаны. URL, отложенные на будущее (повторные
+// попытки и повторный обход), не учитываются.
+func (c *Crawler) Done() <-chan struct{} {
+	return c.finished
 }
 
-func (c *Crawler) AddJob(url string) {
-	c.jobs <- url
+// Stop прекращает выдачу новых заданий и ждет завершения текущих загрузок
+// и сохранения результатов. Если ctx истекает раньше, загрузки прерываются.
+// Аренда невыданных заданий снимается, чтобы их мог взять другой экземпляр.
+// Повторные вызовы ждут завершения первого и возвращают его результат.
+func (c *Crawler) Stop(ctx context.Context) error {
+	c.stopOnce.Do(func() {
+		c.stopErr = c.stop(ctx)
+	})
+	return c.stopErr
 }
 
-func (c *Crawler) worker(ctx context.Context, id int) {
-	defer c.wg.Done()
-	log.Printf("Воркер %d запущен", id)
+func (c *Crawler) stop(ctx context.Context) error {
+	close(c.done)
+	c.sched.close()
+	// Краулер мог не запускаться.
+	cancelRun := func() {
+		if c.cancelRun != nil {
+			c.cancelRun()
+		}
+	}
 
-	for jobURL := range c.jobs {
-		if !c.visited.AddIfNotExists(jobURL) {
-			continue
+	stopped := make(chan struct{})
+	go func() {
+		c.workersWG.Wait()
+		close(c.results)
+		c.resultsWG.Wait()
+		close(stopped)
+	}()
+
+	var err error
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		err = ctx.Err()
+		log.Printf("Краулер не успел завершить загрузки, текущие запросы прерываются")
+		cancelRun()
+		<-stopped
+	}
+	cancelRun()
+
+	if ids := c.sched.drain(); len(ids) > 0 {
+		releaseCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+		defer cancel()
+		if releaseErr := c.storage.ReleaseURLs(releaseCtx, ids); releaseErr != nil {
+			log.Printf("Не удалось вернуть %d URL в очередь: %v", len(ids), releaseErr)
 		}
+	}
+	return err
+}
+
+// RegisterParser задает парсер для типа содержимого "type/subtype" или
+// всех его подтипов "type/*" вместо встроенного; nil отключает разбор
+// документов этого типа.
+func (c *Crawler) RegisterParser(mediaType string, p Parser) {
+	c.parsers.Register(mediaType, p)
+}
+
+// AddJob добавляет URL в очередь краулера в базе данных с областью обхода
+// из конфигурации. Если URL уже известен, его следующая загрузка не переносится.
+func (c *Crawler) AddJob(ctx context.Context, rawURL string) error {
+	normalized, err := c.cfg.Normalization.Normalize(rawURL)
+	if err != nil {
+		return err
+	}
+	scope := c.cfg.Scope
+	scope.Seed = hostOf(normalized)
+	return c.storage.EnqueueURLs(ctx, []*storage.FrontierURL{{URL: normalized, Priority: seedPriority, Scope: &scope}})
+}
+
+// scopeFor возвращает область обхода задания; для URL без нее используется
+// область из конфигурации с хостом самого URL в качестве начального.
+func (c *Crawler) scopeFor(job *storage.FrontierURL) storage.CrawlScope {
+	if job.Scope != nil {
+		return *job.Scope
+	}
+	scope := c.cfg.Scope
+	scope.Seed = hostOf(job.URL)
+	return scope
+}
+
+// feed арендует готовые к загрузке URL из очереди и передает их
+// планировщику, пока у него не наберется запас работы для воркеров.
+func (c *Crawler) feed(ctx context.Context) {
+	defer c.workersWG.Done()
+
+	capacity := c.cfg.Workers * 4
+	for {
+		// Состояние проверяется до аренды: воркер добавляет найденные ссылки
+		// в очередь раньше, чем уменьшает inflight, поэтому пустой ответ
+		// после простоя означает, что работы действительно нет.
+		idle := c.inflight.Load() == 0
+
+		if free := capacity - c.sched.len(); free > 0 {
+			urls, err := c.storage.LeaseURLs(ctx, c.owner, free, c.cfg.HostConnections*2, leaseDuration)
+			if err != nil {
+				log.Printf("Ошибка получения URL из очереди: %v", err)
+			}
+			for _, u := range urls {
+				c.schedule(u)
+			}
+			if err == nil && len(urls) == 0 && idle {
+				c.finishOnce.Do(func() {
+					log.Println("Очередь краулера пуста, обход завершен")
+					close(c.finished)
+				})
+			}
+			// Получено столько, сколько просили, - в очереди может быть еще работа.
+			if len(urls) == free {
+				continue
+			}
+		}
+
+		select {
+		case <-time.After(c.cfg.PollInterval):
+		case <-c.done:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
 
-		log.Printf("Воркер %d: обрабатывает %s", id, jobURL)
+func (c *Crawler) schedule(job *storage.FrontierURL) {
+	c.inflight.Add(1)
+	c.sched.push(job)
+}
+
+func (c *Crawler) worker(ctx context.Context, id int) {
+	defer c.workersWG.Done()
+	log.Printf("Воркер %d запущен", id)
 
-		if err := c.limiter.Wait(ctx); err != nil {
-			log.Printf("Воркер %d остановлен из-за ошибки ограничителя: %v", id, err)
+	for {
+		job, ok := c.sched.next(ctx)
+		if !ok {
 			break
 		}
+		c.process(ctx, id, job)
+		c.inflight.Add(-1)
+	}
+	log.Printf("Воркер %d завершает работу", id)
+}
 
-		body, err := c.fetcher.Fetch(ctx, jobURL)
-		if err != nil {
-			log.Printf("Ошибка загрузки URL %s: %v", jobURL, err)
-			continue
+func (c *Crawler) process(ctx context.Context, id int, job *storage.FrontierURL) {
+	host := hostOf(job.URL)
+	log.Printf("Воркер %d: обрабатывает %s", id, job.URL)
+
+	scope := c.scopeFor(job)
+	if scope.MaxPagesPerHost > 0 && c.pages.get(host) >= scope.MaxPagesPerHost {
+		log.Printf("URL %s пропущен: достигнут лимит страниц для хоста %s", job.URL, host)
+		c.sched.done(host, false, 0)
+		c.complete(ctx, job)
+		return
+	}
+
+	allowed, err := c.checkRobots(ctx, job)
+	if err != nil {
+		c.sched.done(host, false, 0)
+		log.Printf("Ошибка проверки robots.txt для %s: %v", job.URL, err)
+		if ctx.Err() != nil {
+			// Обход прерван: URL возвращается в очередь без попытки.
+			c.release(ctx, job)
+			return
 		}
+		c.retry(ctx, job)
+		return
+	}
+	if !allowed {
+		c.sched.done(host, false, 0)
+		return
+	}
+	if c.cfg.Sitemaps {
+		c.discoverSitemaps(ctx, job, scope)
+	}
 
-		title, text, links := c.parseHTML(jobURL, body)
-		body.Close()
+	start := time.Now()
+	var validators Validators
+	if !job.Sitemap {
+		validators = Validators{ETag: job.ETag, LastModified: job.HTTPLastModified}
+	}
+	resp, err := c.fetcher.Fetch(ctx, job.URL, validators)
+	if err != nil {
+		var statusErr *StatusError
+		if errors.As(err, &statusErr) && statusErr.Throttled() {
+			pause := c.sched.done(host, true, statusErr.RetryAfter)
+			log.Printf("Хост %s просит снизить нагрузку (%s), пауза %s", host, statusErr.Status, pause)
+			if pause <= maxRequeueDelay {
+				c.schedule(job)
+				return
+			}
+			if err := c.storage.FailURL(ctx, job.ID, time.Now().Add(pause)); err != nil {
+				log.Printf("Ошибка обновления очереди для %s: %v", job.URL, err)
+			}
+			return
+		}
 
-		c.results <- &Page{
-			URL:   jobURL,
-			Title: title,
-			Body:  text,
+		c.sched.done(host, false, 0)
+		log.Printf("Ошибка загрузки URL %s: %v", job.URL, err)
+		// Отсутствующий sitemap (например, угаданный /sitemap.xml) не
+		// запрашивается повторно до следующего обхода.
+		if job.Sitemap && errors.As(err, &statusErr) {
+			c.complete(ctx, job)
+			return
 		}
+		c.retry(ctx, job)
+		return
+	}
+	if job.Sitemap {
+		c.sched.done(host, false, 0)
+		c.ingestSitemap(ctx, job, resp, scope)
+		return
+	}
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+	resp.Body.Close()
+	duration := time.Since(start)
+	c.sched.done(host, false, 0)
+	if err != nil {
+		log.Printf("Ошибка чтения ответа %s: %v", job.URL, err)
+		c.retry(ctx, job)
+		return
+	}
+	c.pages.inc(host)
+
+	fetch := &storage.FetchState{
+		ETag:             cmp.Or(resp.Header.Get("ETag"), job.ETag),
+		HTTPLastModified: cmp.Or(resp.Header.Get("Last-Modified"), job.HTTPLastModified),
+		ContentHash:      job.ContentHash,
+	}
+	if resp.StatusCode == http.StatusNotModified {
+		log.Printf("Страница %s не изменилась", job.URL)
+		fetch.RevisitInterval = adaptRevisit(job, false)
+		c.completeFetch(ctx, job, fetch)
+		return
+	}
+	sum := sha256.Sum256(raw)
+	fetch.ContentHash = hex.EncodeToString(sum[:])
+	if fetch.ContentHash == job.ContentHash {
+		log.Printf("Содержимое страницы %s не изменилось", job.URL)
+		fetch.RevisitInterval = adaptRevisit(job, false)
+		c.completeFetch(ctx, job, fetch)
+		return
+	}
+
+	finalURL, err := c.cfg.Normalization.Normalize(resp.URL)
+	if err != nil {
+		finalURL = job.URL
+	}
+	mediaType := detectMediaType(resp.Header.Get("Content-Type"), finalURL, raw)
+	parser := c.parsers.Lookup(mediaType)
+	if parser == nil {
+		log.Printf("Тип содержимого %s страницы %s не поддерживается", mediaType, job.URL)
+		fetch.RevisitInterval = adaptRevisit(job, false)
+		c.completeFetch(ctx, job, fetch)
+		return
+	}
+	page, err := parser.Parse(&Document{
+		URL:           finalURL,
+		MediaType:     mediaType,
+		Header:        resp.Header,
+		Body:          raw,
+		Normalization: c.cfg.Normalization,
+	})
+	if err != nil {
+		log.Printf("Ошибка разбора %s (%s): %v", job.URL, mediaType, err)
+		fetch.RevisitInterval = adaptRevisit(job, false)
+		c.completeFetch(ctx, job, fetch)
+		return
+	}
+
+	page.URL = finalURL
+	page.Lang = lang.Resolve(page.Lang, resp.Header.Get("Content-Language"), page.Body)
+	page.FinalURL = finalURL
+	page.StatusCode = resp.StatusCode
+	page.ContentType = cmp.Or(resp.Header.Get("Content-Type"), mediaType)
+	page.Header = resp.Header
+	page.FetchDuration = duration
+	page.ContentHash = fetch.ContentHash
+	page.SimHash = dedup.Fingerprint(page.MainText())
+	if err := page.SetRawBody(raw, c.cfg.CompressRaw); err != nil {
+		log.Printf("Ошибка сжатия тела страницы %s: %v", job.URL, err)
+	}
+	if finalURL != job.URL {
+		page.Aliases = append(page.Aliases, storage.URLAlias{URL: job.URL, Kind: storage.AliasRedirect})
+	}
+	if page.Canonical != "" && page.Canonical != page.URL && sameSite(page.Canonical, page.URL) {
+		page.Aliases = append(page.Aliases, storage.URLAlias{URL: page.URL, Kind: storage.AliasCanonical})
+		page.URL = page.Canonical
+	}
+	c.visited.AddIfNotExists(page.URL)
+
+	select {
+	case c.results <- page:
+	case <-ctx.Done():
+		return
+	}
 
-		for _, link := range links {
-			c.AddJob(link)
+	c.enqueueLinks(ctx, page.Links, job.Depth+1, scope)
+	fetch.RevisitInterval = adaptRevisit(job, job.ContentHash != "")
+	c.completeFetch(ctx, job, fetch)
+}
+
+// sameSite сообщает, принадлежат ли URL одному регистрируемому домену.
+// Канонический адрес с чужого сайта игнорируется.
+func sameSite(a, b string) bool {
+	ua, errA := url.Parse(a)
+	ub, errB := url.Parse(b)
+	if errA != nil || errB != nil {
+		return false
+	}
+	return registrableDomain(ua.Hostname()) == registrableDomain(ub.Hostname())
+}
+
+// checkRobots проверяет URL по robots.txt и передает Crawl-delay хоста
+// планировщику. Запрещенные URL откладываются до следующего обхода, а если
+// robots.txt недоступен, возвращается ошибка и URL проверяется повторно.
+func (c *Crawler) checkRobots(ctx context.Context, job *storage.FrontierURL) (bool, error) {
+	u, err := url.Parse(job.URL)
+	if err != nil {
+		log.Printf("Некорректный URL %s: %v", job.URL, err)
+		c.complete(ctx, job)
+		return false, nil
+	}
+
+	allowed, rules, err := c.robots.Allowed(ctx, u)
+	if err != nil {
+		return false, err
+	}
+	if !allowed {
+		log.Printf("URL %s запрещен robots.txt", job.URL)
+		c.complete(ctx, job)
+		return false, nil
+	}
+
+	c.sched.setCrawlDelay(u.Host, min(rules.CrawlDelay(userAgent), maxCrawlDelay))
+	return true, nil
+}
+
+// complete планирует повторный обход URL через его интервал или
+// revisitInterval.
+func (c *Crawler) complete(ctx context.Context, job *storage.FrontierURL) {
+	revisit := cmp.Or(job.RevisitInterval, revisitInterval)
+	if err := c.storage.CompleteURL(ctx, job.ID, time.Now().Add(revisit), nil); err != nil {
+		log.Printf("Ошибка обновления очереди для %s: %v", job.URL, err)
+	}
+}
+
+// completeFetch планирует повторный обход загруженной страницы через
+// подобранный интервал и сохраняет сведения о загрузке.
+func (c *Crawler) completeFetch(ctx context.Context, job *storage.FrontierURL, fetch *storage.FetchState) {
+	revisit := cmp.Or(fetch.RevisitInterval, revisitInterval)
+	if err := c.storage.CompleteURL(ctx, job.ID, time.Now().Add(revisit), fetch); err != nil {
+		log.Printf("Ошибка обновления очереди для %s: %v", job.URL, err)
+	}
+}
+
+// adaptRevisit подбирает интервал повторного обхода: после изменения
+// страницы он сокращается вдвое, без изменений - растет в полтора раза.
+// При первой загрузке интервал не меняется.
+func adaptRevisit(job *storage.FrontierURL, changed bool) time.Duration {
+	if job.ContentHash == "" && job.ETag == "" && job.HTTPLastModified == "" {
+		return job.RevisitInterval
+	}
+	interval := cmp.Or(job.RevisitInterval, revisitInterval)
+	if changed {
+		return max(interval/2, minRevisitInterval)
+	}
+	return min(interval*3/2, maxRevisitInterval)
+}
+
+// enqueueLinks добавляет в очередь ссылки, входящие в область обхода.
+func (c *Crawler) enqueueLinks(ctx context.Context, links []storage.Link, depth int, scope storage.CrawlScope) {
+	policy, err := c.scopes.get(scope)
+	if err != nil {
+		log.Printf("Некорректная область обхода: %v", err)
+		return
+	}
+
+	urls := make([]*storage.FrontierURL, 0, len(links))
+	for _, link := range links {
+		if link.NoFollow {
+			continue
+		}
+		u, err := url.Parse(link.ToURL)
+		if err != nil || !policy.allows(u, depth) {
+			continue
+		}
+		if scope.MaxPagesPerHost > 0 && c.pages.get(u.Host) >= scope.MaxPagesPerHost {
+			continue
+		}
+		if c.visited.AddIfNotExists(link.ToURL) {
+			urls = append(urls, &storage.FrontierURL{URL: link.ToURL, Depth: depth, Scope: &scope})
 		}
 	}
-	log.Printf("Воркер %d завершает работу", id)
+	if err := c.storage.EnqueueURLs(ctx, urls); err != nil {
+		log.Printf("Ошибка добавления ссылок в очередь: %v", err)
+	}
+}
+
+// release снимает аренду с URL, не меняя его расписание.
+func (c *Crawler) release(ctx context.Context, job *storage.FrontierURL) {
+	releaseCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	if err := c.storage.ReleaseURLs(releaseCtx, []int64{job.ID}); err != nil {
+		log.Printf("Не удалось вернуть URL %s в очередь: %v", job.URL, err)
+	}
+}
+
+func (c *Crawler) retry(ctx context.Context, job *storage.FrontierURL) {
+	if job.Attempts+1 >= maxAttempts {
+		log.Printf("URL %s отложен до следующего обхода после %d попыток", job.URL, job.Attempts+1)
+		c.complete(ctx, job)
+		return
+	}
+	if err := c.storage.FailURL(ctx, job.ID, time.Now().Add(retryBackoff<<job.Attempts)); err != nil {
+		log.Printf("Ошибка обновления очереди для %s: %v", job.URL, err)
+	}
 }
 
 func (c *Crawler) processResults(ctx context.Context) {
-	defer c.wg.Done()
+	defer c.resultsWG.Done()
 
 	for page := range c.results {
-		pageToStore := &storage.Page{
-			URL:   page.URL,
-			Title: page.Title,
-			Body:  page.Body,
-		}
-		if _, err := c.storage.StorePage(ctx, pageToStore); err != nil {
+		if _, err := c.storage.StorePage(ctx, &page.Page); err != nil {
 			log.Printf("Ошибка сохранения страницы %s: %v", page.URL, err)
 		} else {
 			log.Printf("Страница %s успешно сохранена", page.URL)
@@ -126,58 +596,166 @@ func (c *Crawler) processResults(ctx context.Context) {
 	}
 }
 
-func (c *Crawler) parseHTML(baseURL string, body io.Reader) (string, string, []string) {
+// parseHTML извлекает из HTML-документа текст, метаданные и ссылки.
+func parseHTML(d *Document, body io.Reader) *Page {
+	page := &Page{}
 	doc, err := html.Parse(body)
 	if err != nil {
-		log.Printf("Ошибка парсинга HTML для %s: %v", baseURL, err)
-		return "", "", nil
+		log.Printf("Ошибка парсинга HTML для %s: %v", d.URL, err)
+		return page
 	}
 
 	var title string
 	var text strings.Builder
-	var links []string
+	// pageNoFollow - <meta name="robots" content="nofollow">.
+	var pageNoFollow bool
 
-	var f func(*html.Node)
-	f = func(n *html.Node) {
+	// Текст скрытых элементов не входит в текст страницы, но ссылки из
+	// них (например, из выпадающих меню) учитываются.
+	var f func(*html.Node, bool)
+	f = func(n *html.Node, visible bool) {
 		if n.Type == html.ElementNode {
+			switch n.Data {
+			case "script", "style", "template", "noscript":
+				return
+			}
+			visible = visible && !hidden(n)
 			if n.Data == "title" && n.FirstChild != nil {
 				title = n.FirstChild.Data
 			}
-			if n.Data == "script" || n.Data == "style" {
-				return
+			if n.Data == "html" && page.Lang == "" {
+				page.Lang, _ = attr(n, "lang")
+			}
+			if level := headingLevel(n.Data); level > 0 && visible {
+				if text := nodeText(n, maxHeadingText); text != "" {
+					page.Headings = append(page.Headings, storage.Heading{Level: level, Text: text})
+				}
 			}
 			if n.Data == "a" {
-				for _, a := range n.Attr {
-					if a.Key == "href" {
-						if resolvedURL, err := resolveURL(baseURL, a.Val); err == nil {
-							links = append(links, resolvedURL)
+				if href, ok := attr(n, "href"); ok {
+					if resolvedURL, err := d.ResolveURL(href); err == nil {
+						rel, _ := attr(n, "rel")
+						page.Links = append(page.Links, storage.Link{
+							ToURL:      resolvedURL,
+							AnchorText: nodeText(n, maxAnchorText),
+							Rel:        strings.Join(strings.Fields(strings.ToLower(rel)), " "),
+							NoFollow:   hasToken(rel, "nofollow"),
+						})
+					}
+				}
+			}
+			if n.Data == "meta" {
+				name, _ := attr(n, "name")
+				if strings.EqualFold(name, "description") && page.Description == "" {
+					content, _ := attr(n, "content")
+					page.Description = strings.Join(strings.Fields(content), " ")
+				}
+				if strings.EqualFold(name, "robots") {
+					content, _ := attr(n, "content")
+					pageNoFollow = pageNoFollow || hasToken(strings.ReplaceAll(content, ",", " "), "nofollow") ||
+						hasToken(strings.ReplaceAll(content, ",", " "), "none")
+				}
+			}
+			if n.Data == "link" && page.Canonical == "" {
+				if rel, _ := attr(n, "rel"); hasToken(rel, "canonical") {
+					if href, ok := attr(n, "href"); ok {
+						if resolvedURL, err := d.ResolveURL(href); err == nil {
+							page.Canonical = resolvedURL
 						}
-						break
 					}
 				}
 			}
-		} else if n.Type == html.TextNode {
+		} else if n.Type == html.TextNode && visible {
 			text.WriteString(n.Data)
 			text.WriteString(" ")
 		}
 
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			f(child, visible)
+		}
+	}
+
+	f(doc, true)
+	if pageNoFollow {
+		for i := range page.Links {
+			page.Links[i].NoFollow = true
+		}
+	}
+	page.Title = strings.TrimSpace(title)
+	page.Body = strings.Join(strings.Fields(text.String()), " ")
+	page.Content = extractContent(doc)
+	return page
+}
+
+// Ограничения длины сохраняемого текста ссылки и заголовка в символах.
+const (
+	maxAnchorText  = 200
+	maxHeadingText = 300
+)
+
+// headingLevel возвращает уровень заголовка h1–h3 или 0.
+func headingLevel(tag string) int {
+	switch tag {
+	case "h1":
+		return 1
+	case "h2":
+		return 2
+	case "h3":
+		return 3
+	}
+	return 0
+}
+
+// nodeText собирает текст узла и его потомков, обрезая его до limit символов.
+func nodeText(n *html.Node, limit int) string {
+	var b strings.Builder
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+			b.WriteString(" ")
+		}
 		for child := n.FirstChild; child != nil; child = child.NextSibling {
 			f(child)
 		}
 	}
+	f(n)
 
-	f(doc)
-	return strings.TrimSpace(title), strings.Join(strings.Fields(text.String()), " "), links
+	text := []rune(strings.Join(strings.Fields(b.String()), " "))
+	if len(text) > limit {
+		text = text[:limit]
+	}
+	return string(text)
+}
+
+func attr(n *html.Node, key string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+// hasToken ищет значение в списке через пробел, например в атрибуте rel.
+func hasToken(list, token string) bool {
+	for _, t := range strings.Fields(list) {
+		if strings.EqualFold(t, token) {
+			return true
+		}
+	}
+	return false
 }
 
 var errNotHttp = errors.New("non-http scheme")
 
-func resolveURL(base, relative string) (string, error) {
+// resolveURL разрешает ссылку относительно base и нормализует результат.
+func resolveURL(base, relative string, norm urlnorm.Options) (string, error) {
 	baseURL, err := url.Parse(base)
 	if err != nil {
 		return "", err
 	}
-	relativeURL, err := url.Parse(relative)
+	relativeURL, err := url.Parse(strings.TrimSpace(relative))
 	if err != nil {
 		return "", err
 	}
@@ -186,7 +764,5 @@ func resolveURL(base, relative string) (string, error) {
 		return "", errNotHttp
 	}
 
-	relativeURL.Fragment = ""
-
-	return baseURL.ResolveReference(relativeURL).String(), nil
+	return norm.Normalize(baseURL.ResolveReference(relativeURL).String())
 }
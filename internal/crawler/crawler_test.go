@@ -0,0 +1,548 @@
+package crawler
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cis-engine/internal/storage"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseHTML(t *testing.T) {
+	norm := DefaultConfig().Normalization
+
+	page := parseHTML(&Document{URL: "https://Example.com/docs/index.html", Normalization: norm}, strings.NewReader(`
+<html>
+<head>
+	<title> Docs </title>
+	<link rel="canonical" href="/docs/?utm_source=feed">
+	<script>var x = 1;</script>
+</head>
+<body>
+	<p>Hello,   world</p>
+	<a href="../about#team">About <b>us</b></a>
+	<a href="https://example.com:443/b?z=1&a=2" rel="NoFollow sponsored">B</a>
+	<a href="mailto:me@example.com">Mail</a>
+</body>
+</html>`))
+
+	require.Equal(t, "Docs", page.Title)
+	require.Equal(t, "Docs Hello, world About us B Mail", page.Body)
+	require.Equal(t, "https://example.com/docs/", page.Canonical)
+	require.Equal(t, []storage.Link{
+		{ToURL: "https://example.com/about", AnchorText: "About us"},
+		{ToURL: "https://example.com/b?a=2&z=1", AnchorText: "B", Rel: "nofollow sponsored", NoFollow: true},
+	}, page.Links)
+
+	t.Run("метаданные страницы", func(t *testing.T) {
+		page := parseHTML(&Document{URL: "https://example.com/", Normalization: norm}, strings.NewReader(`
+<html lang="ru">
+<head><meta name="Description" content=" Описание
+	страницы "></head>
+<body>
+	<h1>Главная</h1>
+	<h2>Раздел <i>первый</i></h2>
+	<h4>Не сохраняется</h4>
+	<h3>Подраздел</h3>
+</body>
+</html>`))
+		require.Equal(t, "ru", page.Lang)
+		require.Equal(t, "Описание страницы", page.Description)
+		require.Equal(t, []storage.Heading{
+			{Level: 1, Text: "Главная"},
+			{Level: 2, Text: "Раздел первый"},
+			{Level: 3, Text: "Подраздел"},
+		}, page.Headings)
+	})
+
+	t.Run("Основной текст", func(t *testing.T) {
+		page := parseHTML(&Document{URL: "https://example.com/post", Normalization: norm}, strings.NewReader(`
+<html>
+<body>
+	<header><a href="/">Logo</a> <nav><a href="/news">News</a> <a href="/about">About</a></nav></header>
+	<div class="cookie-banner">We use cookies to improve your experience, please accept them.</div>
+	<div id="content">
+		<article>
+			<h1>Garbage collection</h1>
+			<p>Go uses a concurrent, tri-color, mark and sweep garbage collector, which runs alongside the program.</p>
+			<p>The collector is tuned with GOGC, a percentage of the heap that may be allocated, before the next cycle.</p>
+			<template><p>Template text</p></template>
+		</article>
+	</div>
+	<section class="comments"><p>Comments are closed, but you can reply by email, to the author, of this post.</p></section>
+	<aside class="sidebar"><p>Related posts: scheduler internals, memory model, escape analysis.</p></aside>
+	<div style="display: none">Hidden text <a href="/hidden">Hidden link</a></div>
+	<footer>Copyright 2024, Example Inc., all rights reserved.</footer>
+</body>
+</html>`))
+		require.Equal(t, "Garbage collection Go uses a concurrent, tri-color, mark and sweep garbage collector, which runs alongside the program. "+
+			"The collector is tuned with GOGC, a percentage of the heap that may be allocated, before the next cycle.", page.Content)
+		require.Contains(t, page.Body, "News About")
+		require.Contains(t, page.Body, "Copyright")
+		require.NotContains(t, page.Body, "Hidden text")
+		require.NotContains(t, page.Body, "Template text")
+		require.Contains(t, page.Links, storage.Link{ToURL: "https://example.com/hidden", AnchorText: "Hidden link"})
+		require.Equal(t, page.Content, page.MainText())
+
+		page = parseHTML(&Document{URL: "https://example.com/", Normalization: norm}, strings.NewReader(`<html><body><a href="/a">A</a></body></html>`))
+		require.Empty(t, page.Content)
+		require.Equal(t, "A", page.MainText())
+	})
+
+	t.Run("meta robots nofollow", func(t *testing.T) {
+		page := parseHTML(&Document{URL: "https://example.com/", Normalization: norm}, strings.NewReader(
+			`<html><head><meta name="robots" content="index, nofollow"></head><body><a href="/x">X</a></body></html>`))
+		require.Len(t, page.Links, 1)
+		require.True(t, page.Links[0].NoFollow)
+	})
+}
+
+// memoryFrontier - минимальное хранилище для проверки жизненного цикла краулера.
+type memoryFrontier struct {
+	mu     sync.Mutex
+	nextID int64
+	urls   map[string]*storage.FrontierURL
+	leased map[int64]bool
+	pages  map[string]*storage.Page
+}
+
+func newMemoryFrontier() *memoryFrontier {
+	return &memoryFrontier{
+		urls:   make(map[string]*storage.FrontierURL),
+		leased: make(map[int64]bool),
+		pages:  make(map[string]*storage.Page),
+	}
+}
+
+func (m *memoryFrontier) StorePage(ctx context.Context, page *storage.Page) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pages[page.URL] = page
+	return int64(len(m.pages)), nil
+}
+
+func (m *memoryFrontier) EnqueueURLs(ctx context.Context, urls []*storage.FrontierURL) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, u := range urls {
+		if _, ok := m.urls[u.URL]; ok {
+			continue
+		}
+		m.nextID++
+		copied := *u
+		copied.ID = m.nextID
+		copied.NextFetchAt = time.Now()
+		m.urls[u.URL] = &copied
+	}
+	return nil
+}
+
+func (m *memoryFrontier) LeaseURLs(ctx context.Context, owner string, limit, perHost int, lease time.Duration) ([]*storage.FrontierURL, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []*storage.FrontierURL
+	for _, u := range m.urls {
+		if len(out) == limit {
+			break
+		}
+		if !m.leased[u.ID] && !u.NextFetchAt.After(time.Now()) {
+			m.leased[u.ID] = true
+			copied := *u
+			out = append(out, &copied)
+		}
+	}
+	return out, nil
+}
+
+func (m *memoryFrontier) reschedule(id int64, at time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, u := range m.urls {
+		if u.ID == id {
+			u.NextFetchAt = at
+		}
+	}
+	delete(m.leased, id)
+}
+
+func (m *memoryFrontier) CompleteURL(ctx context.Context, id int64, nextFetchAt time.Time, fetch *storage.FetchState) error {
+	m.reschedule(id, nextFetchAt)
+	if fetch == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, u := range m.urls {
+		if u.ID == id {
+			u.ETag, u.HTTPLastModified, u.ContentHash = fetch.ETag, fetch.HTTPLastModified, fetch.ContentHash
+			u.RevisitInterval = fetch.RevisitInterval
+		}
+	}
+	return nil
+}
+
+func (m *memoryFrontier) FailURL(ctx context.Context, id int64, retryAt time.Time) error {
+	m.reschedule(id, retryAt)
+	return nil
+}
+
+func (m *memoryFrontier) ReleaseURLs(ctx context.Context, ids []int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, id := range ids {
+		delete(m.leased, id)
+	}
+	return nil
+}
+
+func (m *memoryFrontier) IndexPages(ctx context.Context, limit int) (int, error) { return 0, nil }
+func (m *memoryFrontier) WatchPages(ctx context.Context) (<-chan struct{}, error) {
+	return nil, errors.New("не поддерживается")
+}
+func (m *memoryFrontier) SearchPages(ctx context.Context, query *storage.SearchQuery) (*storage.SearchResult, error) {
+	return &storage.SearchResult{}, nil
+}
+func (m *memoryFrontier) GetPage(ctx context.Context, id int64) (*storage.Page, error) {
+	return nil, storage.ErrNotFound
+}
+func (m *memoryFrontier) GetMetrics(ctx context.Context) (*storage.Metrics, error) { return nil, nil }
+func (m *memoryFrontier) GetOutboundLinks(ctx context.Context, pageID int64, limit int) ([]*storage.Link, error) {
+	return nil, nil
+}
+func (m *memoryFrontier) GetInboundLinks(ctx context.Context, pageID int64, limit int) ([]*storage.Link, error) {
+	return nil, nil
+}
+func (m *memoryFrontier) GetLinkGraph(ctx context.Context) (*storage.LinkGraph, error) {
+	return &storage.LinkGraph{}, nil
+}
+func (m *memoryFrontier) UpdateAuthorityScores(ctx context.Context, scores map[int64]float64) error {
+	return nil
+}
+func (m *memoryFrontier) GetFingerprints(ctx context.Context) ([]*storage.PageFingerprint, error) {
+	return nil, nil
+}
+func (m *memoryFrontier) UpdateDuplicates(ctx context.Context, duplicates map[int64]int64) error {
+	return nil
+}
+func (m *memoryFrontier) UpdateTermStats(ctx context.Context) error { return nil }
+func (m *memoryFrontier) GetSuggestionSources(ctx context.Context, limit int) (*storage.SuggestionSources, error) {
+	return &storage.SuggestionSources{}, nil
+}
+func (m *memoryFrontier) LogQueries(ctx context.Context, entries []*storage.QueryLogEntry) error {
+	return nil
+}
+func (m *memoryFrontier) DeleteQueryLog(ctx context.Context, before time.Time) (int64, error) {
+	return 0, nil
+}
+func (m *memoryFrontier) TopQueries(ctx context.Context, from, to time.Time, limit int, zeroResults bool) ([]*storage.QueryCount, error) {
+	return nil, nil
+}
+func (m *memoryFrontier) QueryLatency(ctx context.Context, from, to time.Time, bounds []time.Duration) (*storage.LatencyStats, error) {
+	return &storage.LatencyStats{}, nil
+}
+func (m *memoryFrontier) Close() {}
+
+func TestCrawlerLifecycle(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><title>Home</title><a href="/a">A</a><a href="/b">B</a></html>`)
+	})
+	mux.HandleFunc("/a", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><title>A</title><a href="/">Home</a><a href="/b">B</a></html>`)
+	})
+	mux.HandleFunc("/b", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><title>B</title></html>`)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	cfg := DefaultConfig()
+	cfg.Workers = 3
+	cfg.HostRate = 0
+	cfg.HostConnections = 3
+	cfg.PollInterval = 20 * time.Millisecond
+
+	store := newMemoryFrontier()
+	c := NewCrawler(cfg, store, NewHTTPFetcher(time.Second))
+	c.Start(context.Background(), []string{server.URL})
+
+	select {
+	case <-c.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("краулер не сообщил о завершении обхода")
+	}
+
+	stopCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, c.Stop(stopCtx))
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	require.Len(t, store.pages, 3)
+	require.Contains(t, store.pages, server.URL+"/b")
+
+	home := store.pages[server.URL+"/"]
+	require.Equal(t, http.StatusOK, home.StatusCode)
+	require.Equal(t, storage.EncodingGzip, home.RawEncoding)
+	raw, err := home.Raw()
+	require.NoError(t, err)
+	require.Contains(t, string(raw), "<title>Home</title>")
+	require.Equal(t, int64(len(raw)), home.ContentLength)
+}
+
+func TestCrawlerSitemaps(t *testing.T) {
+	mux := http.NewServeMux()
+	var serverURL string
+	mux.HandleFunc("/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "User-agent: *\nDisallow: /private\nSitemap: %s/sitemap_index.xml\n", serverURL)
+	})
+	mux.HandleFunc("/sitemap_index.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<sitemapindex>
+			<sitemap><loc>/pages.xml.gz</loc></sitemap>
+			<sitemap><loc>https://elsewhere.example/sitemap.xml</loc></sitemap>
+		</sitemapindex>`)
+	})
+	mux.HandleFunc("/pages.xml.gz", func(w http.ResponseWriter, r *http.Request) {
+		zw := gzip.NewWriter(w)
+		fmt.Fprintf(zw, `<urlset>
+			<url><loc>%s/hidden</loc><changefreq>weekly</changefreq><priority>0.8</priority></url>
+			<url><loc>%s/archive</loc><changefreq>yearly</changefreq></url>
+			<url><loc>https://elsewhere.example/</loc></url>
+		</urlset>`, serverURL, serverURL)
+		zw.Close()
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><title>Home</title></html>`)
+	})
+	mux.HandleFunc("/hidden", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><title>Hidden</title></html>`)
+	})
+	mux.HandleFunc("/archive", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><title>Archive</title></html>`)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	serverURL = server.URL
+
+	cfg := DefaultConfig()
+	cfg.HostRate = 0
+	cfg.PollInterval = 20 * time.Millisecond
+
+	store := newMemoryFrontier()
+	c := NewCrawler(cfg, store, NewHTTPFetcher(time.Second))
+	c.Start(context.Background(), []string{server.URL})
+
+	select {
+	case <-c.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("краулер не сообщил о завершении обхода")
+	}
+	stopCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, c.Stop(stopCtx))
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	require.Len(t, store.pages, 3, "sitemap не сохраняются как страницы")
+	require.Contains(t, store.pages, server.URL+"/hidden")
+	require.NotContains(t, store.urls, "https://elsewhere.example/", "страницы вне области обхода пропускаются")
+	require.NotContains(t, store.urls, "https://elsewhere.example/sitemap.xml", "sitemap вне области обхода пропускаются")
+
+	require.True(t, store.urls[server.URL+"/pages.xml.gz"].Sitemap)
+	hidden := store.urls[server.URL+"/hidden"]
+	require.Equal(t, 8, hidden.Priority)
+	require.Equal(t, 7*24*time.Hour, hidden.RevisitInterval)
+	require.WithinDuration(t, time.Now().Add(7*24*time.Hour), hidden.NextFetchAt, time.Minute)
+	require.Equal(t, maxRevisitInterval, store.urls[server.URL+"/archive"].RevisitInterval, "интервал из changefreq ограничен")
+}
+
+func TestCrawlerRevisit(t *testing.T) {
+	var version atomic.Int64
+	var notModified atomic.Int64
+	mux := http.NewServeMux()
+	mux.HandleFunc("/robots.txt", http.NotFound)
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		fmt.Fprint(w, `<html><title>Home</title><a href="/static">S</a><a href="/news">N</a></html>`)
+	})
+	mux.HandleFunc("/static", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><title>Static</title></html>`)
+	})
+	mux.HandleFunc("/news", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `<html><title>News %d</title></html>`, version.Add(1))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	cfg := DefaultConfig()
+	cfg.HostRate = 0
+	cfg.PollInterval = 20 * time.Millisecond
+	cfg.Sitemaps = false
+
+	store := newMemoryFrontier()
+	crawl := func() {
+		c := NewCrawler(cfg, store, NewHTTPFetcher(time.Second))
+		c.Start(context.Background(), []string{server.URL})
+		select {
+		case <-c.Done():
+		case <-time.After(5 * time.Second):
+			t.Fatal("краулер не сообщил о завершении обхода")
+		}
+		stopCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		require.NoError(t, c.Stop(stopCtx))
+	}
+
+	crawl()
+	store.mu.Lock()
+	require.Len(t, store.pages, 3)
+	require.NotEmpty(t, store.pages[server.URL+"/"].ContentHash)
+	home := store.urls[server.URL+"/"]
+	require.Equal(t, `"v1"`, home.ETag)
+	require.Zero(t, home.RevisitInterval, "после первой загрузки интервал не меняется")
+	// Повторный обход всех страниц.
+	for _, u := range store.urls {
+		u.NextFetchAt = time.Now()
+	}
+	clear(store.pages)
+	store.mu.Unlock()
+
+	crawl()
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	require.Equal(t, int64(1), notModified.Load(), "запрос главной страницы условный")
+	require.Len(t, store.pages, 1, "сохраняется только изменившаяся страница")
+	require.Contains(t, store.pages, server.URL+"/news")
+
+	require.Equal(t, 36*time.Hour, store.urls[server.URL+"/"].RevisitInterval)
+	require.Equal(t, 36*time.Hour, store.urls[server.URL+"/static"].RevisitInterval)
+	news := store.urls[server.URL+"/news"]
+	require.Equal(t, 12*time.Hour, news.RevisitInterval)
+	require.WithinDuration(t, time.Now().Add(12*time.Hour), news.NextFetchAt, time.Minute)
+}
+
+func TestCrawlerRobotsError(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	store := newMemoryFrontier()
+	c := NewCrawler(DefaultConfig(), store, NewHTTPFetcher(time.Second))
+	ctx := context.Background()
+	require.NoError(t, store.EnqueueURLs(ctx, []*storage.FrontierURL{{URL: server.URL + "/page"}}))
+	jobs, err := store.LeaseURLs(ctx, "test", 1, 1, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+
+	// robots.txt не загружается, пока обход не прерван.
+	runCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	c.process(runCtx, 1, jobs[0])
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	require.False(t, store.leased[jobs[0].ID], "аренда снята")
+	require.Zero(t, store.urls[server.URL+"/page"].Attempts, "прерванная проверка не считается попыткой")
+}
+
+func TestCrawlerRobotsUnavailable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	store := newMemoryFrontier()
+	c := NewCrawler(DefaultConfig(), store, NewHTTPFetcher(time.Second))
+	ctx := context.Background()
+	require.NoError(t, store.EnqueueURLs(ctx, []*storage.FrontierURL{{URL: server.URL + "/page"}}))
+	jobs, err := store.LeaseURLs(ctx, "test", 1, 1, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+
+	c.process(ctx, 1, jobs[0])
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	require.False(t, store.leased[jobs[0].ID], "аренда снята")
+	require.WithinDuration(t, time.Now().Add(retryBackoff), store.urls[server.URL+"/page"].NextFetchAt, time.Second,
+		"недоступный robots.txt не откладывает URL до следующего обхода")
+}
+
+func TestCrawlerStopDeadline(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	cfg := DefaultConfig()
+	cfg.Workers = 1
+	cfg.HostRate = 0
+	cfg.PollInterval = 10 * time.Millisecond
+
+	store := newMemoryFrontier()
+	c := NewCrawler(cfg, store, NewHTTPFetcher(time.Minute))
+	c.Start(context.Background(), []string{server.URL + "/slow"})
+	time.Sleep(100 * time.Millisecond)
+
+	stopCtx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := c.Stop(stopCtx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.ErrorIs(t, c.Stop(context.Background()), context.DeadlineExceeded, "повторный Stop возвращает результат первого")
+}
+
+func TestCrawlerStopTwice(t *testing.T) {
+	t.Run("Без запуска", func(t *testing.T) {
+		c := NewCrawler(DefaultConfig(), newMemoryFrontier(), NewHTTPFetcher(time.Second))
+		require.NoError(t, c.Stop(context.Background()))
+		require.NoError(t, c.Stop(context.Background()))
+	})
+
+	t.Run("После запуска", func(t *testing.T) {
+		cfg := DefaultConfig()
+		cfg.PollInterval = 10 * time.Millisecond
+		c := NewCrawler(cfg, newMemoryFrontier(), NewHTTPFetcher(time.Second))
+		c.Start(context.Background(), nil)
+
+		var wg sync.WaitGroup
+		for range 3 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				require.NoError(t, c.Stop(context.Background()))
+			}()
+		}
+		wg.Wait()
+		require.NoError(t, c.Stop(context.Background()))
+	})
+}
@@ -0,0 +1,163 @@
+package crawler
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/xml"
+	"errors"
+	"strings"
+
+	"cis-engine/internal/storage"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"golang.org/x/net/html/charset"
+)
+
+var errNotFeed = errors.New("документ не является лентой RSS или Atom")
+
+// feedXML покрывает RSS 2.0, RSS 1.0 (RDF) и Atom: у RSS 2.0 записи лежат
+// в channel, у RDF - рядом с ним, у Atom заголовок и записи - в корне.
+type feedXML struct {
+	XMLName  xml.Name
+	Lang     string      `xml:"lang,attr"`
+	Title    feedText    `xml:"title"`
+	Subtitle feedText    `xml:"subtitle"`
+	Entries  []feedEntry `xml:"entry"`
+	Items    []feedEntry `xml:"item"`
+	Channel  struct {
+		Title       string      `xml:"title"`
+		Description string      `xml:"description"`
+		Language    string      `xml:"language"`
+		Items       []feedEntry `xml:"item"`
+	} `xml:"channel"`
+}
+
+type feedEntry struct {
+	Title       feedText   `xml:"title"`
+	Links       []feedLink `xml:"link"`
+	Description string     `xml:"description"`
+	Summary     feedText   `xml:"summary"`
+	Content     feedText   `xml:"content"`
+	// Encoded - полный текст записи RSS из content:encoded.
+	Encoded string `xml:"encoded"`
+}
+
+// feedLink - ссылка RSS (<link>адрес</link>) или Atom (<link href="адрес">).
+type feedLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	URL  string `xml:",chardata"`
+}
+
+// feedText - текстовое поле Atom: text, html или xhtml.
+type feedText struct {
+	Type  string `xml:"type,attr"`
+	Text  string `xml:",chardata"`
+	Inner string `xml:",innerxml"`
+}
+
+// String возвращает текст поля без HTML-разметки.
+func (t feedText) String() string {
+	if t.Type == "xhtml" {
+		return htmlText(t.Inner)
+	}
+	return htmlText(t.Text)
+}
+
+// FeedParser разбирает ленты RSS и Atom: записи становятся заголовками
+// второго уровня и ссылками с их названием в качестве текста ссылки.
+type FeedParser struct{}
+
+func (FeedParser) Parse(doc *Document) (*Page, error) {
+	dec := xml.NewDecoder(bytes.NewReader(doc.Body))
+	dec.CharsetReader = charset.NewReaderLabel
+	dec.Strict = false
+	dec.Entity = xml.HTMLEntity
+
+	var feed feedXML
+	if err := dec.Decode(&feed); err != nil {
+		return nil, errors.Join(errNotFeed, err)
+	}
+	entries := feed.Entries
+	switch feed.XMLName.Local {
+	case "rss":
+		entries = feed.Channel.Items
+	case "RDF":
+		entries = feed.Items
+	case "feed":
+	default:
+		return nil, errNotFeed
+	}
+
+	page := &Page{}
+	page.Title = cmp.Or(feed.Title.String(), htmlText(feed.Channel.Title))
+	page.Description = cmp.Or(feed.Subtitle.String(), htmlText(feed.Channel.Description))
+	page.Lang = cmp.Or(feed.Lang, strings.TrimSpace(feed.Channel.Language))
+
+	var body strings.Builder
+	body.WriteString(page.Title + " " + page.Description + " ")
+	for _, entry := range entries {
+		title := entry.Title.String()
+		if title != "" {
+			page.Headings = append(page.Headings, storage.Heading{Level: 2, Text: truncate(title, maxHeadingText)})
+		}
+		if href := entry.link(); href != "" {
+			if resolved, err := doc.ResolveURL(href); err == nil {
+				page.Links = append(page.Links, storage.Link{ToURL: resolved, AnchorText: truncate(title, maxAnchorText)})
+			}
+		}
+		text := cmp.Or(htmlText(entry.Encoded), entry.Content.String(), htmlText(entry.Description), entry.Summary.String())
+		body.WriteString(title + " " + text + " ")
+	}
+	page.Body = strings.Join(strings.Fields(body.String()), " ")
+	if page.Body == "" {
+		return nil, errNoText
+	}
+	return page, nil
+}
+
+// link возвращает адрес записи: ссылку RSS или ссылку Atom с rel="alternate".
+func (e *feedEntry) link() string {
+	for _, l := range e.Links {
+		if l.Href == "" {
+			if u := strings.TrimSpace(l.URL); u != "" {
+				return u
+			}
+			continue
+		}
+		if l.Rel == "" || l.Rel == "alternate" {
+			return l.Href
+		}
+	}
+	return ""
+}
+
+// htmlText возвращает текст HTML-фрагмента без разметки.
+func htmlText(s string) string {
+	if !strings.ContainsAny(s, "<&") {
+		return strings.Join(strings.Fields(s), " ")
+	}
+	nodes, err := html.ParseFragment(strings.NewReader(s), &html.Node{Type: html.ElementNode, Data: "div", DataAtom: atom.Div})
+	if err != nil {
+		return strings.Join(strings.Fields(s), " ")
+	}
+	var b strings.Builder
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.ElementNode && invisible(n) {
+			return
+		}
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+			b.WriteString(" ")
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			f(child)
+		}
+	}
+	for _, n := range nodes {
+		f(n)
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
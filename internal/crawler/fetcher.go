@@ -5,11 +5,32 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"time"
 )
 
+const userAgent = "CIS-Engine-Crawler/1.0"
+
 type Fetcher interface {
-	Fetch(ctx context.Context, url string) (io.ReadCloser, error)
+	// Fetch загружает url; если заданы валидаторы, запрос условный и ответ
+	// 304 Not Modified также считается успешным.
+	Fetch(ctx context.Context, url string, v Validators) (*Response, error)
+}
+
+// Validators - значения ETag и Last-Modified прошлого ответа для условного
+// запроса.
+type Validators struct {
+	ETag         string
+	LastModified string
+}
+
+// Response - успешный ответ сервера.
+type Response struct {
+	// URL - итоговый адрес после перенаправлений.
+	URL        string
+	StatusCode int
+	Header     http.Header
+	Body       io.ReadCloser
 }
 
 type HTTPFetcher struct {
@@ -24,23 +45,70 @@ func NewHTTPFetcher(timeout time.Duration) *HTTPFetcher {
 	}
 }
 
-func (f *HTTPFetcher) Fetch(ctx context.Context, url string) (io.ReadCloser, error) {
+func (f *HTTPFetcher) Fetch(ctx context.Context, url string, v Validators) (*Response, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request for %s: %w", url, err)
 	}
 
-	req.Header.Set("User-Agent", "CIS-Engine-Crawler/1.0")
+	req.Header.Set("User-Agent", userAgent)
+	if v.ETag != "" {
+		req.Header.Set("If-None-Match", v.ETag)
+	}
+	if v.LastModified != "" {
+		req.Header.Set("If-Modified-Since", v.LastModified)
+	}
 
 	resp, err := f.client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch %s: %w", url, err)
 	}
 
-	if resp.StatusCode != http.StatusOK {
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotModified {
 		resp.Body.Close()
-		return nil, fmt.Errorf("bad status code for %s: %s", url, resp.Status)
+		return nil, &StatusError{
+			URL:        url,
+			Status:     resp.Status,
+			StatusCode: resp.StatusCode,
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
 	}
 
-	return resp.Body, nil
+	return &Response{
+		URL:        resp.Request.URL.String(),
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Body:       resp.Body,
+	}, nil
+}
+
+// StatusError - ответ сервера с кодом, отличным от 200 и 304.
+type StatusError struct {
+	URL        string
+	Status     string
+	StatusCode int
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("bad status code for %s: %s", e.URL, e.Status)
+}
+
+// Throttled сообщает, что сервер просит снизить нагрузку.
+func (e *StatusError) Throttled() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode == http.StatusServiceUnavailable
+}
+
+// parseRetryAfter разбирает заголовок Retry-After в секундах или в виде HTTP-даты.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(value); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0)
+	}
+	return 0
 }
@@ -0,0 +1,198 @@
+package crawler
+
+import (
+	"cmp"
+	"regexp"
+	"strings"
+
+	"cis-engine/internal/storage"
+)
+
+var (
+	mdATXHeading = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	mdSetext     = regexp.MustCompile(`^ {0,3}(=+|-+)[ \t]*$`)
+	mdFence      = regexp.MustCompile("^ {0,3}(```+|~~~+)")
+	mdRefDef     = regexp.MustCompile(`^ {0,3}\[([^\]]+)\]:[ \t]*<?([^\s>]+)>?`)
+	mdRule       = regexp.MustCompile(`^ {0,3}([-*_])[ \t]*(?:[-*_][ \t]*){2,}$`)
+	mdTableRule  = regexp.MustCompile(`^[ \t]*\|?[ \t]*:?-+:?[ \t]*(\|[ \t]*:?-+:?[ \t]*)*\|?[ \t]*$`)
+	mdBlockStart = regexp.MustCompile(`^[ \t]*(?:>[ \t]?)*(?:[-*+][ \t]+|\d{1,9}[.)][ \t]+)?`)
+
+	mdImage    = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	mdLink     = regexp.MustCompile(`\[([^\]]*)\]\(<?([^\s)>]+)>?(?:[ \t]+(?:"[^"]*"|'[^']*'))?\)`)
+	mdRefLink  = regexp.MustCompile(`\[([^\]]+)\]\[([^\]]*)\]`)
+	mdAutolink = regexp.MustCompile(`<(https?://[^>\s]+)>`)
+	mdCode     = regexp.MustCompile("`+([^`]*)`+")
+	mdTag      = regexp.MustCompile(`</?[a-zA-Z][^>]*>`)
+	mdEmphasis = []*regexp.Regexp{
+		regexp.MustCompile(`\*\*(\S(?:.*?\S)?)\*\*`),
+		regexp.MustCompile(`__(\S(?:.*?\S)?)__`),
+		regexp.MustCompile(`~~(\S(?:.*?\S)?)~~`),
+		regexp.MustCompile(`\*(\S(?:[^*]*?\S)?)\*`),
+		regexp.MustCompile(`(^|[\s(])_(\S(?:[^_]*?\S)?)_`),
+	}
+)
+
+// MarkdownParser разбирает документы Markdown: заголовки, текст без
+// разметки и ссылки, включая ссылки-сноски. Заголовок документа берется
+// из поля title в начале файла (front matter) или из первого заголовка.
+type MarkdownParser struct{}
+
+func (MarkdownParser) Parse(doc *Document) (*Page, error) {
+	text := strings.ReplaceAll(doc.Text(), "\r\n", "\n")
+	page := &Page{}
+	lines := strings.Split(text, "\n")
+	lines = markdownFrontMatter(lines, page)
+
+	refs := make(map[string]string)
+	for _, line := range lines {
+		if m := mdRefDef.FindStringSubmatch(line); m != nil {
+			refs[strings.ToLower(m[1])] = m[2]
+		}
+	}
+
+	var body strings.Builder
+	var firstH1, firstHeading string
+	addHeading := func(level int, line string) {
+		heading := markdownInline(doc, line, refs, page)
+		if heading == "" {
+			return
+		}
+		if firstHeading == "" {
+			firstHeading = heading
+		}
+		if level == 1 && firstH1 == "" {
+			firstH1 = heading
+		}
+		if level <= 3 {
+			page.Headings = append(page.Headings, storage.Heading{Level: level, Text: truncate(heading, maxHeadingText)})
+		}
+		body.WriteString(heading)
+		body.WriteString(" ")
+	}
+
+	var fence string
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if fence != "" {
+			if strings.HasPrefix(strings.TrimSpace(line), fence) {
+				fence = ""
+				continue
+			}
+			body.WriteString(line)
+			body.WriteString(" ")
+			continue
+		}
+		if m := mdFence.FindStringSubmatch(line); m != nil {
+			fence = m[1]
+			continue
+		}
+		if strings.TrimSpace(line) == "" || mdRefDef.MatchString(line) || mdTableRule.MatchString(line) {
+			continue
+		}
+		if m := mdATXHeading.FindStringSubmatch(line); m != nil {
+			addHeading(len(m[1]), m[2])
+			continue
+		}
+		if i+1 < len(lines) && !mdRule.MatchString(line) && mdBlockStart.FindString(line) == "" {
+			if m := mdSetext.FindStringSubmatch(lines[i+1]); m != nil {
+				level := 1
+				if m[1][0] == '-' {
+					level = 2
+				}
+				addHeading(level, line)
+				i++
+				continue
+			}
+		}
+		if mdRule.MatchString(line) {
+			continue
+		}
+		line = line[len(mdBlockStart.FindString(line)):]
+		body.WriteString(markdownInline(doc, strings.ReplaceAll(line, "|", " "), refs, page))
+		body.WriteString(" ")
+	}
+
+	page.Body = strings.Join(strings.Fields(body.String()), " ")
+	if page.Body == "" {
+		return nil, errNoText
+	}
+	page.Title = cmp.Or(page.Title, firstH1, firstHeading)
+	return page, nil
+}
+
+// markdownFrontMatter отделяет блок метаданных YAML в начале документа,
+// переносит из него title, description и lang в страницу и возвращает
+// остальные строки.
+func markdownFrontMatter(lines []string, page *Page) []string {
+	if len(lines) == 0 || strings.TrimSpace(lines[0]) != "---" {
+		return lines
+	}
+	for i := 1; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if line == "---" || line == "..." {
+			return lines[i+1:]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		value = strings.Trim(strings.TrimSpace(value), `"'`)
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "title":
+			page.Title = value
+		case "description":
+			page.Description = value
+		case "lang", "language":
+			page.Lang = value
+		}
+	}
+	// Закрывающей строки нет - это не метаданные, а обычный текст.
+	page.Title, page.Description, page.Lang = "", "", ""
+	return lines
+}
+
+// markdownInline убирает из строки встроенную разметку, оставляя текст,
+// и добавляет найденные ссылки в страницу.
+func markdownInline(doc *Document, line string, refs map[string]string, page *Page) string {
+	addLink := func(href, anchor string) {
+		if resolved, err := doc.ResolveURL(href); err == nil {
+			page.Links = append(page.Links, storage.Link{
+				ToURL:      resolved,
+				AnchorText: truncate(strings.Join(strings.Fields(anchor), " "), maxAnchorText),
+			})
+		}
+	}
+
+	line = mdCode.ReplaceAllString(line, "$1")
+	line = mdImage.ReplaceAllString(line, "$1")
+	line = mdLink.ReplaceAllStringFunc(line, func(s string) string {
+		m := mdLink.FindStringSubmatch(s)
+		addLink(m[2], m[1])
+		return m[1]
+	})
+	line = mdRefLink.ReplaceAllStringFunc(line, func(s string) string {
+		m := mdRefLink.FindStringSubmatch(s)
+		label := m[2]
+		if label == "" {
+			label = m[1]
+		}
+		if href, ok := refs[strings.ToLower(label)]; ok {
+			addLink(href, m[1])
+		}
+		return m[1]
+	})
+	line = mdAutolink.ReplaceAllStringFunc(line, func(s string) string {
+		href := strings.Trim(s, "<>")
+		addLink(href, href)
+		return href
+	})
+	line = mdTag.ReplaceAllString(line, " ")
+	for i, re := range mdEmphasis {
+		if i == len(mdEmphasis)-1 {
+			line = re.ReplaceAllString(line, "$1$2")
+		} else {
+			line = re.ReplaceAllString(line, "$1")
+		}
+	}
+	return strings.TrimSpace(line)
+}
@@ -0,0 +1,237 @@
+package crawler
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"cis-engine/internal/storage"
+	"cis-engine/internal/urlnorm"
+
+	"golang.org/x/net/html/charset"
+)
+
+// Document - загруженный документ, передаваемый парсеру.
+type Document struct {
+	// URL - итоговый адрес документа; относительные ссылки разрешаются
+	// относительно него.
+	URL string
+	// MediaType - тип содержимого без параметров из Content-Type или
+	// определенный по содержимому, например "text/html".
+	MediaType string
+	Header    http.Header
+	Body      []byte
+	// Normalization - правила нормализации найденных ссылок.
+	Normalization urlnorm.Options
+}
+
+// ResolveURL разрешает ссылку из документа и нормализует ее.
+func (d *Document) ResolveURL(href string) (string, error) {
+	return resolveURL(d.URL, href, d.Normalization)
+}
+
+// Text возвращает тело документа в UTF-8 с учетом кодировки из
+// Content-Type или объявленной в самом документе.
+func (d *Document) Text() string {
+	r, err := charset.NewReader(bytes.NewReader(d.Body), d.Header.Get("Content-Type"))
+	if err != nil {
+		return strings.ToValidUTF8(string(d.Body), "�")
+	}
+	text, err := io.ReadAll(r)
+	if err != nil {
+		return strings.ToValidUTF8(string(d.Body), "�")
+	}
+	return string(text)
+}
+
+// Parser извлекает из документа текст, метаданные и ссылки страницы.
+type Parser interface {
+	Parse(doc *Document) (*Page, error)
+}
+
+// ParserFunc позволяет использовать функцию как Parser.
+type ParserFunc func(doc *Document) (*Page, error)
+
+func (f ParserFunc) Parse(doc *Document) (*Page, error) {
+	return f(doc)
+}
+
+// Parsers выбирает парсер документа по типу содержимого. Безопасен для
+// одновременного использования.
+type Parsers struct {
+	mu      sync.RWMutex
+	parsers map[string]Parser
+}
+
+// NewParsers возвращает набор со встроенными парсерами HTML, текста,
+// Markdown, лент RSS и Atom и PDF.
+func NewParsers() *Parsers {
+	p := &Parsers{parsers: make(map[string]Parser)}
+	for _, t := range []string{"text/html", "application/xhtml+xml"} {
+		p.Register(t, HTMLParser{})
+	}
+	p.Register("text/plain", TextParser{})
+	for _, t := range []string{"text/markdown", "text/x-markdown"} {
+		p.Register(t, MarkdownParser{})
+	}
+	for _, t := range []string{"application/rss+xml", "application/atom+xml", "application/rdf+xml"} {
+		p.Register(t, FeedParser{})
+	}
+	for _, t := range []string{"application/pdf", "application/x-pdf"} {
+		p.Register(t, PDFParser{})
+	}
+	return p
+}
+
+// Register задает парсер для типа содержимого "type/subtype" или для всех
+// подтипов "type/*", заменяя прежний; nil удаляет парсер.
+func (p *Parsers) Register(mediaType string, parser Parser) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	mediaType = strings.ToLower(mediaType)
+	if parser == nil {
+		delete(p.parsers, mediaType)
+		return
+	}
+	p.parsers[mediaType] = parser
+}
+
+// Lookup возвращает парсер для типа содержимого или nil, если тип не
+// поддерживается.
+func (p *Parsers) Lookup(mediaType string) Parser {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if parser, ok := p.parsers[mediaType]; ok {
+		return parser
+	}
+	major, _, _ := strings.Cut(mediaType, "/")
+	return p.parsers[major+"/*"]
+}
+
+// detectMediaType определяет тип содержимого документа. Заголовок
+// Content-Type уточняется по расширению адреса и по содержимому, если
+// сервер не указал тип, указал общий (application/octet-stream, XML) или
+// отдает Markdown как текст.
+func detectMediaType(contentType, rawURL string, body []byte) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = ""
+	}
+	ext := ""
+	if u, err := url.Parse(rawURL); err == nil {
+		ext = strings.ToLower(path.Ext(u.Path))
+	}
+
+	switch mediaType {
+	case "", "application/octet-stream", "binary/octet-stream", "application/unknown":
+		mediaType, _, _ = mime.ParseMediaType(http.DetectContentType(body))
+		if mediaType == "text/plain" && (ext == ".md" || ext == ".markdown") {
+			mediaType = "text/markdown"
+		}
+	case "text/plain":
+		if ext == ".md" || ext == ".markdown" {
+			mediaType = "text/markdown"
+		}
+	}
+	if mediaType == "text/xml" || mediaType == "application/xml" {
+		switch xmlRoot(body) {
+		case "rss":
+			mediaType = "application/rss+xml"
+		case "feed":
+			mediaType = "application/atom+xml"
+		case "RDF":
+			mediaType = "application/rdf+xml"
+		case "html":
+			mediaType = "application/xhtml+xml"
+		}
+	}
+	return mediaType
+}
+
+// xmlRoot возвращает локальное имя корневого элемента XML-документа.
+func xmlRoot(body []byte) string {
+	dec := xml.NewDecoder(bytes.NewReader(body))
+	dec.CharsetReader = charset.NewReaderLabel
+	dec.Strict = false
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return ""
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			return start.Name.Local
+		}
+	}
+}
+
+var errNoText = errors.New("в документе не найден текст")
+
+// urlPattern находит адреса http и https в тексте.
+var urlPattern = regexp.MustCompile(`https?://[^\s<>()\[\]"'` + "`" + `]+`)
+
+// textLinks возвращает ссылки на адреса, встречающиеся в тексте.
+func textLinks(doc *Document, text string) []storage.Link {
+	var links []storage.Link
+	for _, match := range urlPattern.FindAllString(text, -1) {
+		match = strings.TrimRight(match, ".,;:!?")
+		if resolved, err := doc.ResolveURL(match); err == nil {
+			links = append(links, storage.Link{ToURL: resolved})
+		}
+	}
+	return links
+}
+
+// truncate обрезает строку до limit символов.
+func truncate(s string, limit int) string {
+	if utf8.RuneCountInString(s) <= limit {
+		return s
+	}
+	return string([]rune(s)[:limit])
+}
+
+// TextParser разбирает обычный текст: заголовком считается первая
+// непустая строка, если она короткая, ссылками - адреса в тексте.
+type TextParser struct{}
+
+func (TextParser) Parse(doc *Document) (*Page, error) {
+	text := doc.Text()
+	page := &Page{}
+	page.Body = strings.Join(strings.Fields(text), " ")
+	if page.Body == "" {
+		return nil, errNoText
+	}
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			if utf8.RuneCountInString(line) <= maxTitleText {
+				page.Title = line
+			}
+			break
+		}
+	}
+	page.Links = textLinks(doc, text)
+	return page, nil
+}
+
+// maxTitleText - строка длиннее этого числа символов не считается
+// заголовком текстового документа.
+const maxTitleText = 120
+
+// HTMLParser разбирает HTML-страницы.
+type HTMLParser struct{}
+
+func (HTMLParser) Parse(doc *Document) (*Page, error) {
+	r, err := charset.NewReader(bytes.NewReader(doc.Body), doc.Header.Get("Content-Type"))
+	if err != nil {
+		r = bytes.NewReader(doc.Body)
+	}
+	return parseHTML(doc, r), nil
+}
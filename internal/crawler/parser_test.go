@@ -0,0 +1,287 @@
+package crawler
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"cis-engine/internal/storage"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectMediaType(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		url         string
+		body        string
+		want        string
+	}{
+		{"Заголовок с параметрами", "Text/HTML; charset=windows-1251", "https://example.com/", "", "text/html"},
+		{"HTML без заголовка", "", "https://example.com/", "<!DOCTYPE html><html></html>", "text/html"},
+		{"PDF как octet-stream", "application/octet-stream", "https://example.com/a", "%PDF-1.4\n", "application/pdf"},
+		{"Markdown как текст", "text/plain; charset=utf-8", "https://example.com/README.md", "# Title", "text/markdown"},
+		{"RSS как XML", "text/xml", "https://example.com/feed", `<?xml version="1.0"?><rss version="2.0"></rss>`, "application/rss+xml"},
+		{"Atom без заголовка", "", "https://example.com/atom", `<?xml version="1.0"?><feed xmlns="http://www.w3.org/2005/Atom"></feed>`, "application/atom+xml"},
+		{"Изображение", "image/png", "https://example.com/a.png", "\x89PNG", "image/png"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, detectMediaType(tt.contentType, tt.url, []byte(tt.body)))
+		})
+	}
+}
+
+// parseDocument разбирает документ встроенным парсером для его типа.
+func parseDocument(t *testing.T, contentType, rawURL, body string) (*Page, error) {
+	t.Helper()
+	mediaType := detectMediaType(contentType, rawURL, []byte(body))
+	parser := NewParsers().Lookup(mediaType)
+	require.NotNil(t, parser, "нет парсера для %s", mediaType)
+	return parser.Parse(&Document{
+		URL:           rawURL,
+		MediaType:     mediaType,
+		Header:        http.Header{"Content-Type": {contentType}},
+		Body:          []byte(body),
+		Normalization: DefaultConfig().Normalization,
+	})
+}
+
+func linkURLs(links []storage.Link) []string {
+	urls := make([]string, len(links))
+	for i, l := range links {
+		urls[i] = l.ToURL
+	}
+	return urls
+}
+
+func TestParsers(t *testing.T) {
+	t.Run("HTML в windows-1251", func(t *testing.T) {
+		page, err := parseDocument(t, "text/html; charset=windows-1251", "https://example.com/",
+			"<html><title>\xcf\xf0\xe8\xe2\xe5\xf2</title><body>\xec\xe8\xf0</body></html>")
+		require.NoError(t, err)
+		require.Equal(t, "Привет", page.Title)
+		require.Equal(t, "Привет мир", page.Body)
+	})
+
+	t.Run("Текст", func(t *testing.T) {
+		page, err := parseDocument(t, "text/plain", "https://example.com/notes.txt", `
+Release notes
+
+Version 2 is available at https://example.com/download/v2, see also
+https://example.com/changelog.
+`)
+		require.NoError(t, err)
+		require.Equal(t, "Release notes", page.Title)
+		require.Equal(t, "Release notes Version 2 is available at https://example.com/download/v2, see also https://example.com/changelog.", page.Body)
+		require.Equal(t, []string{"https://example.com/download/v2", "https://example.com/changelog"}, linkURLs(page.Links))
+	})
+
+	t.Run("Markdown", func(t *testing.T) {
+		page, err := parseDocument(t, "text/plain", "https://example.com/docs/guide.md", `---
+title: "Guide"
+description: Getting started
+---
+Install
+=======
+
+Run **go install** and read the [tutorial](tutorial.md "Tutorial")
+or the [FAQ][faq]. ![Diagram](diagram.png)
+
+## Next _steps_
+
+- Visit <https://go.dev/>
+- Use `+"`snake_case`"+` names
+
+`+"```go"+`
+fmt.Println("[not](link)")
+`+"```"+`
+
+[faq]: /docs/faq
+`)
+		require.NoError(t, err)
+		require.Equal(t, "Guide", page.Title)
+		require.Equal(t, "Getting started", page.Description)
+		require.Equal(t, []storage.Heading{{Level: 1, Text: "Install"}, {Level: 2, Text: "Next steps"}}, page.Headings)
+		require.Equal(t, `Install Run go install and read the tutorial or the FAQ. Diagram Next steps Visit https://go.dev/ Use snake_case names fmt.Println("[not](link)")`, page.Body)
+		require.Equal(t, []string{"https://example.com/docs/tutorial.md", "https://example.com/docs/faq", "https://go.dev/"}, linkURLs(page.Links))
+		require.Equal(t, "tutorial", page.Links[0].AnchorText)
+		require.Equal(t, "FAQ", page.Links[1].AnchorText)
+	})
+
+	t.Run("RSS", func(t *testing.T) {
+		page, err := parseDocument(t, "application/rss+xml", "https://example.com/feed", `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:content="http://purl.org/rss/1.0/modules/content/">
+<channel>
+	<title>Example blog</title>
+	<description>News &amp; notes</description>
+	<language>en</language>
+	<item>
+		<title>First post</title>
+		<link>/posts/1</link>
+		<description><![CDATA[<p>Hello <b>world</b></p>]]></description>
+	</item>
+	<item>
+		<title>Second post</title>
+		<link>https://example.com/posts/2</link>
+		<description>Short</description>
+		<content:encoded><![CDATA[<p>Full&nbsp;text</p>]]></content:encoded>
+	</item>
+</channel>
+</rss>`)
+		require.NoError(t, err)
+		require.Equal(t, "Example blog", page.Title)
+		require.Equal(t, "News & notes", page.Description)
+		require.Equal(t, "en", page.Lang)
+		require.Equal(t, []storage.Heading{{Level: 2, Text: "First post"}, {Level: 2, Text: "Second post"}}, page.Headings)
+		require.Equal(t, "Example blog News & notes First post Hello world Second post Full text", page.Body)
+		require.Equal(t, []string{"https://example.com/posts/1", "https://example.com/posts/2"}, linkURLs(page.Links))
+		require.Equal(t, "First post", page.Links[0].AnchorText)
+	})
+
+	t.Run("Atom", func(t *testing.T) {
+		page, err := parseDocument(t, "application/xml", "https://example.com/atom.xml", `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom" xml:lang="de">
+	<title type="html">Example &lt;i&gt;feed&lt;/i&gt;</title>
+	<entry>
+		<title>Entry</title>
+		<link rel="edit" href="/edit/1"/>
+		<link href="/entries/1"/>
+		<content type="xhtml"><div xmlns="http://www.w3.org/1999/xhtml"><p>Entry text</p></div></content>
+	</entry>
+</feed>`)
+		require.NoError(t, err)
+		require.Equal(t, "Example feed", page.Title)
+		require.Equal(t, "de", page.Lang)
+		require.Equal(t, "Example feed Entry Entry text", page.Body)
+		require.Equal(t, []string{"https://example.com/entries/1"}, linkURLs(page.Links))
+	})
+
+	t.Run("PDF", func(t *testing.T) {
+		var content bytes.Buffer
+		zw := zlib.NewWriter(&content)
+		fmt.Fprint(zw, "BT /F1 12 Tf 72 712 Td (Hello, \\(PDF\\) world) Tj 0 -14 Td [(Compr) 20 (essed) -300 (text)] TJ ET")
+		zw.Close()
+
+		var pdf bytes.Buffer
+		pdf.WriteString("%PDF-1.4\n")
+		pdf.WriteString("1 0 obj\n<< /Title (Test document) /Author <FEFF0410> >>\nendobj\n")
+		fmt.Fprintf(&pdf, "2 0 obj\n<< /Length %d /Filter /FlateDecode >>\nstream\n", content.Len())
+		pdf.Write(content.Bytes())
+		pdf.WriteString("\nendstream\nendobj\n")
+		pdf.WriteString("3 0 obj\n<< /Type /XObject /Subtype /Image /Length 4 >>\nstream\n(Tj)\nendstream\nendobj\n")
+		pdf.WriteString("4 0 obj\n<< /Length 30 >>\nstream\nBT <FEFF041F04400438043204350442> Tj ET\nendstream\nendobj\n")
+		pdf.WriteString("trailer\n<< /Info 1 0 R >>\n%%EOF\n")
+
+		page, err := parseDocument(t, "application/pdf", "https://example.com/doc.pdf", pdf.String())
+		require.NoError(t, err)
+		require.Equal(t, "Test document", page.Title)
+		require.Equal(t, "Hello, (PDF) world Compressed text Привет", page.Body)
+	})
+
+	t.Run("PDF с распаковкой сверх лимита", func(t *testing.T) {
+		var bomb bytes.Buffer
+		zw := zlib.NewWriter(&bomb)
+		zw.Write(bytes.Repeat([]byte(" "), maxPDFDecoded/2+1))
+		zw.Close()
+
+		var pdf bytes.Buffer
+		pdf.WriteString("%PDF-1.4\n")
+		for i := range 3 {
+			fmt.Fprintf(&pdf, "%d 0 obj\n<< /Filter /FlateDecode >>\nstream\n", i+1)
+			pdf.Write(bomb.Bytes())
+			pdf.WriteString("\nendstream\nendobj\n")
+		}
+		pdf.WriteString("4 0 obj\n<< >>\nstream\nBT (Late text) Tj ET\nendstream\nendobj\n")
+
+		_, err := parseDocument(t, "application/pdf", "https://example.com/bomb.pdf", pdf.String())
+		require.ErrorIs(t, err, errNoText, "потоки после исчерпания лимита не распаковываются")
+	})
+
+	t.Run("PDF из множества потоков", func(t *testing.T) {
+		// Раньше словарь каждого потока искался просмотром документа с
+		// начала, и разбор такого файла занимал минуты.
+		var pdf bytes.Buffer
+		pdf.WriteString("%PDF-1.4\n1 0 obj\n")
+		for pdf.Len() < 4<<20 {
+			pdf.WriteString("<<>>stream\nx\nendstream\n")
+		}
+
+		start := time.Now()
+		_, err := parseDocument(t, "application/pdf", "https://example.com/many.pdf", pdf.String())
+		require.ErrorIs(t, err, errNoText)
+		require.Less(t, time.Since(start), 5*time.Second)
+	})
+
+	t.Run("PDF без текста", func(t *testing.T) {
+		_, err := parseDocument(t, "application/pdf", "https://example.com/scan.pdf", "%PDF-1.4\n%%EOF\n")
+		require.ErrorIs(t, err, errNoText)
+	})
+}
+
+func TestCrawlerParsers(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/robots.txt", http.NotFound)
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><title>Home</title><a href="/logo.png">L</a><a href="/data.csv">D</a><a href="/notes.txt">N</a></html>`)
+	})
+	mux.HandleFunc("/logo.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("\x89PNG\r\n\x1a\n"))
+	})
+	mux.HandleFunc("/data.csv", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/csv")
+		fmt.Fprint(w, "name,value\nalpha,1\n")
+	})
+	mux.HandleFunc("/notes.txt", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprint(w, "Notes\n\nPlain text notes.")
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	cfg := DefaultConfig()
+	cfg.HostRate = 0
+	cfg.PollInterval = 20 * time.Millisecond
+	cfg.Sitemaps = false
+
+	store := newMemoryFrontier()
+	c := NewCrawler(cfg, store, NewHTTPFetcher(time.Second))
+	c.RegisterParser("text/csv", ParserFunc(func(doc *Document) (*Page, error) {
+		page := &Page{}
+		page.Title = "CSV"
+		page.Body = strings.Join(strings.Fields(strings.ReplaceAll(string(doc.Body), ",", " ")), " ")
+		return page, nil
+	}))
+	c.Start(context.Background(), []string{server.URL})
+	select {
+	case <-c.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("краулер не сообщил о завершении обхода")
+	}
+	stopCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, c.Stop(stopCtx))
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	require.Len(t, store.pages, 3, "изображение не сохраняется")
+	require.NotContains(t, store.pages, server.URL+"/logo.png")
+	require.NotEmpty(t, store.urls[server.URL+"/logo.png"].ContentHash, "неподдерживаемый документ не загружается повторно без изменений")
+
+	csv := store.pages[server.URL+"/data.csv"]
+	require.Equal(t, "CSV", csv.Title)
+	require.Equal(t, "name value alpha 1", csv.Body)
+	require.Equal(t, "text/csv", csv.ContentType)
+
+	notes := store.pages[server.URL+"/notes.txt"]
+	require.Equal(t, "Notes", notes.Title)
+	require.Equal(t, "Notes Plain text notes.", notes.Body)
+}
@@ -0,0 +1,434 @@
+package crawler
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+)
+
+// Извлечение текста из PDF без сторонних библиотек: из потоков содержимого
+// страниц, сжатых FlateDecode или несжатых, берутся строки операторов
+// вывода текста Tj, TJ, ' и ". Шрифты со встроенными кодировками глифов
+// (например, Identity-H без таблицы ToUnicode) дают бессмысленные коды -
+// такие потоки отбрасываются по доле печатных символов.
+
+var errNotPDF = errors.New("документ не является PDF")
+
+const (
+	// minPrintableRatio - текст потока с меньшей долей букв, цифр, пробелов
+	// и знаков препинания считается мусором.
+	minPrintableRatio = 0.8
+	// tjWordSpace - сдвиг в массиве TJ (в тысячных долях кегля), начиная с
+	// которого он считается пробелом между словами.
+	tjWordSpace = 200
+	// maxPDFDecoded ограничивает суммарный объем распакованных потоков
+	// документа; потоки сверх него не разбираются.
+	maxPDFDecoded = 2 * maxBodySize
+)
+
+var (
+	pdfFilter = regexp.MustCompile(`/Filter\s*(\[[^\]]*\]|/[A-Za-z0-9]+)`)
+	// pdfSkip - потоки с изображениями, шрифтами, перекрестными ссылками и
+	// метаданными не содержат текста страниц.
+	pdfSkip   = regexp.MustCompile(`/Type\s*/(XRef|XObject\s*/Subtype\s*/Image|Metadata|EmbeddedFile)\b|/Subtype\s*/(Image|Type1C|CIDFontType0C|OpenType|XML)\b|/Length[123]\b|/Predictor`)
+	pdfObjStm = regexp.MustCompile(`/Type\s*/ObjStm\b`)
+)
+
+// PDFParser извлекает текст и заголовок из документов PDF.
+type PDFParser struct{}
+
+func (PDFParser) Parse(doc *Document) (*Page, error) {
+	body := doc.Body
+	if !bytes.Contains(body[:min(len(body), 1024)], []byte("%PDF-")) {
+		return nil, errNotPDF
+	}
+
+	var text strings.Builder
+	// objects - несжатые словари объектов и содержимое потоков объектов,
+	// где ищется словарь Info с заголовком документа.
+	objects := [][]byte{body}
+	budget := maxPDFDecoded
+	for _, s := range pdfStreams(body) {
+		if budget <= 0 {
+			break
+		}
+		if pdfSkip.Match(s.dict) {
+			continue
+		}
+		data, ok := pdfDecode(s, budget)
+		if !ok {
+			continue
+		}
+		budget -= len(data)
+		if pdfObjStm.Match(s.dict) {
+			objects = append(objects, data)
+			continue
+		}
+		if t := pdfContentText(data); printableRatio(t) >= minPrintableRatio {
+			text.WriteString(t)
+			text.WriteString(" ")
+		}
+	}
+
+	page := &Page{}
+	page.Body = strings.Join(strings.Fields(text.String()), " ")
+	if page.Body == "" {
+		return nil, errNoText
+	}
+	for _, data := range objects {
+		if title := pdfTitle(data); title != "" {
+			page.Title = title
+			break
+		}
+	}
+	page.Links = textLinks(doc, page.Body)
+	return page, nil
+}
+
+// pdfStream - поток объекта PDF: его словарь и данные.
+type pdfStream struct {
+	dict []byte
+	data []byte
+}
+
+// pdfStreams находит потоки документа по ключевым словам stream и
+// endstream, не разбирая таблицу перекрестных ссылок: она часто
+// повреждена, а длина потока бывает задана косвенной ссылкой. Документ
+// просматривается за один проход: словарь потока начинается с последнего
+// "obj" перед ним, но не раньше конца предыдущего потока.
+func pdfStreams(body []byte) []pdfStream {
+	var streams []pdfStream
+	// objPos - начало последнего найденного "obj", scanned - до какого места
+	// он искался, prevEnd - конец предыдущего потока.
+	objPos, scanned, prevEnd := -1, 0, 0
+	for pos := 0; ; {
+		i := bytes.Index(body[pos:], []byte("stream"))
+		if i < 0 {
+			return streams
+		}
+		start := pos + i
+		pos = start + len("stream")
+		for {
+			j := bytes.Index(body[scanned:start], []byte("obj"))
+			if j < 0 {
+				break
+			}
+			objPos = scanned + j
+			scanned = objPos + len("obj")
+		}
+		scanned = max(scanned, start-len("obj")+1)
+		// Ключевое слово stream следует сразу за словарем "<< ... >>".
+		if !bytes.HasSuffix(bytes.TrimRight(body[:start], " \t\r\n"), []byte(">>")) {
+			continue
+		}
+		if objPos < prevEnd {
+			continue
+		}
+		data := body[pos:]
+		data = bytes.TrimPrefix(data, []byte("\r"))
+		data = bytes.TrimPrefix(data, []byte("\n"))
+		end := bytes.Index(data, []byte("endstream"))
+		if end < 0 {
+			return streams
+		}
+		streams = append(streams, pdfStream{
+			dict: body[objPos:start],
+			data: bytes.TrimRight(data[:end], "\r\n"),
+		})
+		pos = len(body) - len(data) + end + len("endstream")
+		prevEnd = pos
+	}
+}
+
+// pdfDecode распаковывает не больше limit байт данных потока.
+// Поддерживаются несжатые потоки и потоки с единственным фильтром
+// FlateDecode.
+func pdfDecode(s pdfStream, limit int) ([]byte, bool) {
+	m := pdfFilter.FindSubmatch(s.dict)
+	if m == nil {
+		return s.data[:min(len(s.data), limit)], true
+	}
+	filters := strings.Fields(strings.NewReplacer("[", " ", "]", " ", "/", " /").Replace(string(m[1])))
+	if len(filters) != 1 || filters[0] != "/FlateDecode" {
+		return nil, false
+	}
+	zr, err := zlib.NewReader(bytes.NewReader(s.data))
+	if err != nil {
+		return nil, false
+	}
+	defer zr.Close()
+	data, err := io.ReadAll(io.LimitReader(zr, int64(limit)))
+	// Оборванный поток все равно может содержать текст.
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, false
+	}
+	return data, len(data) > 0
+}
+
+// pdfTitle ищет заголовок в словаре Info документа.
+func pdfTitle(data []byte) string {
+	i := bytes.Index(data, []byte("/Title"))
+	if i < 0 {
+		return ""
+	}
+	lex := pdfLexer{data: data, pos: i + len("/Title")}
+	tok, ok := lex.next()
+	if !ok || tok.kind != pdfString {
+		return ""
+	}
+	return strings.Join(strings.Fields(pdfDecodeString(tok.value)), " ")
+}
+
+// pdfContentText собирает строки операторов вывода текста потока
+// содержимого.
+func pdfContentText(data []byte) string {
+	var b strings.Builder
+	// operands - строки и пробелы, накопленные до ближайшего оператора.
+	var operands []string
+	lex := pdfLexer{data: data}
+	for {
+		tok, ok := lex.next()
+		if !ok {
+			break
+		}
+		switch tok.kind {
+		case pdfString:
+			operands = append(operands, pdfDecodeString(tok.value))
+		case pdfNumber:
+			// Большой отрицательный сдвиг внутри массива TJ - пробел.
+			if lex.depth > 0 {
+				if n, err := strconv.ParseFloat(string(tok.value), 64); err == nil && n <= -tjWordSpace {
+					operands = append(operands, " ")
+				}
+			}
+		case pdfOperator:
+			switch string(tok.value) {
+			case "Tj", "TJ":
+				b.WriteString(strings.Join(operands, ""))
+			case "'", "\"":
+				b.WriteString("\n")
+				b.WriteString(strings.Join(operands, ""))
+			case "Td", "TD", "T*", "Tm", "ET":
+				b.WriteString(" ")
+			case "ID":
+				lex.skipInlineImage()
+			}
+			operands = operands[:0]
+		}
+	}
+	return b.String()
+}
+
+// printableRatio - доля букв, цифр, пробелов и знаков препинания в тексте.
+func printableRatio(s string) float64 {
+	var total, printable int
+	for _, r := range s {
+		total++
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsSpace(r) || unicode.IsPunct(r) {
+			printable++
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(printable) / float64(total)
+}
+
+// pdfDecodeString переводит строку PDF в UTF-8: строки с меткой порядка
+// байтов - из UTF-16BE, остальные - из PDFDocEncoding, который для
+// печатных символов совпадает с Latin-1.
+func pdfDecodeString(s []byte) string {
+	if len(s) >= 2 && s[0] == 0xfe && s[1] == 0xff {
+		units := make([]uint16, 0, len(s)/2)
+		for i := 2; i+1 < len(s); i += 2 {
+			units = append(units, uint16(s[i])<<8|uint16(s[i+1]))
+		}
+		return string(utf16.Decode(units))
+	}
+	runes := make([]rune, len(s))
+	for i, c := range s {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
+
+type pdfTokenKind int
+
+const (
+	pdfString pdfTokenKind = iota
+	pdfNumber
+	pdfName
+	pdfOperator
+	pdfDelimiter
+)
+
+type pdfToken struct {
+	kind  pdfTokenKind
+	value []byte
+}
+
+// pdfLexer разбивает поток содержимого PDF на лексемы.
+type pdfLexer struct {
+	data []byte
+	pos  int
+	// depth - вложенность массивов [ ].
+	depth int
+}
+
+func pdfWhitespace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\f' || c == 0
+}
+
+func pdfDelim(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
+
+func (l *pdfLexer) next() (pdfToken, bool) {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		switch {
+		case pdfWhitespace(c):
+			l.pos++
+		case c == '%':
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+		case c == '(':
+			return pdfToken{kind: pdfString, value: l.literal()}, true
+		case c == '<':
+			if l.pos+1 < len(l.data) && l.data[l.pos+1] == '<' {
+				l.pos += 2
+				return pdfToken{kind: pdfDelimiter, value: []byte("<<")}, true
+			}
+			return pdfToken{kind: pdfString, value: l.hex()}, true
+		case c == '[' || c == ']' || c == '{' || c == '}' || c == '>' || c == ')':
+			l.pos++
+			if c == '[' {
+				l.depth++
+			} else if c == ']' && l.depth > 0 {
+				l.depth--
+			}
+			if c == '>' && l.pos < len(l.data) && l.data[l.pos] == '>' {
+				l.pos++
+			}
+			return pdfToken{kind: pdfDelimiter, value: []byte{c}}, true
+		case c == '/':
+			start := l.pos
+			l.pos++
+			l.word()
+			return pdfToken{kind: pdfName, value: l.data[start:l.pos]}, true
+		default:
+			start := l.pos
+			l.word()
+			value := l.data[start:l.pos]
+			if _, err := strconv.ParseFloat(string(value), 64); err == nil {
+				return pdfToken{kind: pdfNumber, value: value}, true
+			}
+			return pdfToken{kind: pdfOperator, value: value}, true
+		}
+	}
+	return pdfToken{}, false
+}
+
+// word пропускает обычные символы до разделителя или пробела.
+func (l *pdfLexer) word() {
+	for l.pos < len(l.data) && !pdfWhitespace(l.data[l.pos]) && !pdfDelim(l.data[l.pos]) {
+		l.pos++
+	}
+}
+
+// literal читает строку в круглых скобках с учетом вложенных скобок и
+// escape-последовательностей.
+func (l *pdfLexer) literal() []byte {
+	var out []byte
+	depth := 0
+	for l.pos++; l.pos < len(l.data); l.pos++ {
+		c := l.data[l.pos]
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			if depth == 0 {
+				l.pos++
+				return out
+			}
+			depth--
+		case '\\':
+			l.pos++
+			if l.pos >= len(l.data) {
+				return out
+			}
+			c = l.data[l.pos]
+			switch c {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r', '\n':
+				// Перенос строки после обратной косой черты не входит в строку.
+				if c == '\r' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '\n' {
+					l.pos++
+				}
+				continue
+			default:
+				if c >= '0' && c <= '7' {
+					n := int(c - '0')
+					for k := 0; k < 2 && l.pos+1 < len(l.data) && l.data[l.pos+1] >= '0' && l.data[l.pos+1] <= '7'; k++ {
+						l.pos++
+						n = n*8 + int(l.data[l.pos]-'0')
+					}
+					c = byte(n)
+				}
+			}
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+// hex читает шестнадцатеричную строку в угловых скобках.
+func (l *pdfLexer) hex() []byte {
+	var digits []byte
+	for l.pos++; l.pos < len(l.data) && l.data[l.pos] != '>'; l.pos++ {
+		if c := l.data[l.pos]; strings.IndexByte("0123456789abcdefABCDEF", c) >= 0 {
+			digits = append(digits, c)
+		}
+	}
+	l.pos++
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	out := make([]byte, len(digits)/2)
+	for i := range out {
+		n, _ := strconv.ParseUint(string(digits[2*i:2*i+2]), 16, 8)
+		out[i] = byte(n)
+	}
+	return out
+}
+
+// skipInlineImage пропускает данные встроенного изображения до EI.
+func (l *pdfLexer) skipInlineImage() {
+	for l.pos < len(l.data) {
+		i := bytes.Index(l.data[l.pos:], []byte("EI"))
+		if i < 0 {
+			l.pos = len(l.data)
+			return
+		}
+		l.pos += i + 2
+		if pdfWhitespace(l.data[l.pos-3]) && (l.pos == len(l.data) || pdfWhitespace(l.data[l.pos])) {
+			return
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package crawler
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"time"
+
+	"cis-engine/internal/storage"
+)
+
+// hostScheduler раздает задания воркерам по кругу между хостами, соблюдая
+// для каждого хоста интервал между запросами, лимит одновременных
+// соединений и паузу после ответов 429/503.
+type hostScheduler struct {
+	mu      sync.Mutex
+	hosts   map[string]*hostQueue
+	ring    []string
+	cursor  int
+	pending int
+	closed  bool
+	// changed закрывается и пересоздается при каждом изменении состояния,
+	// чтобы разбудить ожидающих воркеров.
+	changed chan struct{}
+
+	interval   time.Duration
+	maxConns   int
+	maxBackoff time.Duration
+}
+
+type hostQueue struct {
+	jobs         []*storage.FrontierURL
+	active       int
+	crawlDelay   time.Duration
+	nextAt       time.Time
+	blockedUntil time.Time
+	backoff      time.Duration
+}
+
+func newHostScheduler(hostRate float64, maxConns int, maxBackoff time.Duration) *hostScheduler {
+	var interval time.Duration
+	if hostRate > 0 {
+		interval = time.Duration(float64(time.Second) / hostRate)
+	}
+	return &hostScheduler{
+		hosts:      make(map[string]*hostQueue),
+		changed:    make(chan struct{}),
+		interval:   interval,
+		maxConns:   maxConns,
+		maxBackoff: maxBackoff,
+	}
+}
+
+func hostOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+func (s *hostScheduler) push(job *storage.FrontierURL) {
+	host := hostOf(job.URL)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	q, ok := s.hosts[host]
+	if !ok {
+		q = &hostQueue{}
+		s.hosts[host] = q
+		s.ring = append(s.ring, host)
+	}
+	q.jobs = append(q.jobs, job)
+	s.pending++
+	s.notify()
+}
+
+// next блокируется, пока какой-либо хост не будет готов принять запрос.
+// Возвращает false после close или отмены контекста.
+func (s *hostScheduler) next(ctx context.Context) (*storage.FrontierURL, bool) {
+	for {
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			return nil, false
+		}
+		job, wait := s.take(time.Now())
+		changed := s.changed
+		s.mu.Unlock()
+
+		if job != nil {
+			return job, true
+		}
+
+		var timer *time.Timer
+		var fire <-chan time.Time
+		if wait > 0 {
+			timer = time.NewTimer(wait)
+			fire = timer.C
+		}
+		select {
+		case <-changed:
+		case <-fire:
+		case <-ctx.Done():
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if ctx.Err() != nil {
+			return nil, false
+		}
+	}
+}
+
+// take выбирает задание следующего готового хоста по кругу. Если готовых
+// нет, возвращает время до ближайшей готовности (0 - ждать изменений).
+func (s *hostScheduler) take(now time.Time) (*storage.FrontierURL, time.Duration) {
+	s.sweep(now)
+
+	var wait time.Duration
+	for i := 0; i < len(s.ring); i++ {
+		idx := (s.cursor + i) % len(s.ring)
+		host := s.ring[idx]
+		q := s.hosts[host]
+		if len(q.jobs) == 0 || q.active >= s.maxConns {
+			continue
+		}
+
+		readyAt := q.nextAt
+		if q.blockedUntil.After(readyAt) {
+			readyAt = q.blockedUntil
+		}
+		if readyAt.After(now) {
+			if d := readyAt.Sub(now); wait == 0 || d < wait {
+				wait = d
+			}
+			continue
+		}
+
+		job := q.jobs[0]
+		q.jobs[0] = nil
+		q.jobs = q.jobs[1:]
+		q.active++
+		q.nextAt = now.Add(max(s.interval, q.crawlDelay))
+		s.pending--
+		s.cursor = (idx + 1) % len(s.ring)
+		return job, 0
+	}
+	return nil, wait
+}
+
+// setCrawlDelay учитывает Crawl-delay из robots.txt для хоста.
+func (s *hostScheduler) setCrawlDelay(host string, delay time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	q, ok := s.hosts[host]
+	if !ok || q.crawlDelay == delay {
+		return
+	}
+	if delay > q.crawlDelay {
+		q.nextAt = q.nextAt.Add(delay - q.crawlDelay)
+	}
+	q.crawlDelay = delay
+}
+
+// done освобождает соединение хоста. throttled означает ответ 429/503:
+// хост приостанавливается на retryAfter или на экспоненциально растущую паузу.
+// Возвращает фактическую длительность паузы.
+func (s *hostScheduler) done(host string, throttled bool, retryAfter time.Duration) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer s.notify()
+
+	q, ok := s.hosts[host]
+	if !ok {
+		return 0
+	}
+	q.active--
+
+	if !throttled {
+		q.backoff = 0
+		return 0
+	}
+
+	q.backoff = min(max(2*q.backoff, time.Second), s.maxBackoff)
+	pause := max(retryAfter, q.backoff)
+	q.blockedUntil = time.Now().Add(pause)
+	return pause
+}
+
+// sweep удаляет простаивающие хосты, чтобы карта не росла бесконечно.
+// Crawl-delay удаленного хоста восстановится при следующей проверке robots.txt.
+func (s *hostScheduler) sweep(now time.Time) {
+	ring := s.ring[:0]
+	for i, host := range s.ring {
+		q := s.hosts[host]
+		if len(q.jobs) == 0 && q.active == 0 && !q.nextAt.After(now) && !q.blockedUntil.After(now) {
+			delete(s.hosts, host)
+			if i < s.cursor {
+				s.cursor--
+			}
+			continue
+		}
+		ring = append(ring, host)
+	}
+	clear(s.ring[len(ring):])
+	s.ring = ring
+	if len(s.ring) == 0 || s.cursor >= len(s.ring) {
+		s.cursor = 0
+	}
+}
+
+func (s *hostScheduler) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pending
+}
+
+// drain извлекает все невыданные задания и возвращает их идентификаторы.
+func (s *hostScheduler) drain() []int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var ids []int64
+	for _, q := range s.hosts {
+		for _, job := range q.jobs {
+			ids = append(ids, job.ID)
+		}
+		q.jobs = nil
+	}
+	s.pending = 0
+	return ids
+}
+
+func (s *hostScheduler) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	s.notify()
+}
+
+func (s *hostScheduler) notify() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
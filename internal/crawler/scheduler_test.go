@@ -0,0 +1,74 @@
+package crawler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cis-engine/internal/storage"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHostScheduler(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Хосты обходятся по кругу", func(t *testing.T) {
+		s := newHostScheduler(0, 10, time.Minute)
+		s.push(&storage.FrontierURL{URL: "https://a.example/1"})
+		s.push(&storage.FrontierURL{URL: "https://a.example/2"})
+		s.push(&storage.FrontierURL{URL: "https://b.example/1"})
+
+		var order []string
+		for i := 0; i < 3; i++ {
+			job, ok := s.next(ctx)
+			require.True(t, ok)
+			order = append(order, job.URL)
+		}
+		require.Equal(t, []string{"https://a.example/1", "https://b.example/1", "https://a.example/2"}, order)
+	})
+
+	t.Run("Лимит соединений на хост", func(t *testing.T) {
+		s := newHostScheduler(0, 1, time.Minute)
+		s.push(&storage.FrontierURL{URL: "https://a.example/1"})
+		s.push(&storage.FrontierURL{URL: "https://a.example/2"})
+
+		_, ok := s.next(ctx)
+		require.True(t, ok)
+
+		waitCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+		defer cancel()
+		_, ok = s.next(waitCtx)
+		require.False(t, ok, "второе соединение к хосту не должно выдаваться")
+
+		s.done("a.example", false, 0)
+		job, ok := s.next(ctx)
+		require.True(t, ok)
+		require.Equal(t, "https://a.example/2", job.URL)
+	})
+
+	t.Run("Пауза после 429", func(t *testing.T) {
+		s := newHostScheduler(0, 1, time.Minute)
+		s.push(&storage.FrontierURL{URL: "https://a.example/1"})
+		job, _ := s.next(ctx)
+
+		pause := s.done("a.example", true, 5*time.Second)
+		require.Equal(t, 5*time.Second, pause)
+		s.push(job)
+
+		waitCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+		defer cancel()
+		_, ok := s.next(waitCtx)
+		require.False(t, ok)
+	})
+
+	t.Run("close будит ожидающих воркеров", func(t *testing.T) {
+		s := newHostScheduler(0, 1, time.Minute)
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			s.close()
+		}()
+		_, ok := s.next(ctx)
+		require.False(t, ok)
+	})
+}
@@ -0,0 +1,135 @@
+package crawler
+
+import (
+	"encoding/json"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+
+	"cis-engine/internal/storage"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// scopePolicy - скомпилированная область обхода storage.CrawlScope.
+type scopePolicy struct {
+	scope   storage.CrawlScope
+	seed    string
+	domain  string
+	include []*regexp.Regexp
+	exclude []*regexp.Regexp
+}
+
+func compileScope(scope storage.CrawlScope) (*scopePolicy, error) {
+	if err := scope.Validate(); err != nil {
+		return nil, err
+	}
+	p := &scopePolicy{scope: scope, seed: strings.ToLower(scope.Seed)}
+	if host, _, err := net.SplitHostPort(p.seed); err == nil {
+		p.seed = host
+	}
+	if p.seed != "" {
+		p.domain = registrableDomain(p.seed)
+	}
+	for _, expr := range scope.Include {
+		p.include = append(p.include, regexp.MustCompile(expr))
+	}
+	for _, expr := range scope.Exclude {
+		p.exclude = append(p.exclude, regexp.MustCompile(expr))
+	}
+	return p, nil
+}
+
+// allows проверяет, входит ли ссылка глубины depth в область обхода.
+func (p *scopePolicy) allows(u *url.URL, depth int) bool {
+	if p.scope.MaxDepth > 0 && depth > p.scope.MaxDepth {
+		return false
+	}
+
+	host := strings.ToLower(u.Hostname())
+	switch p.scope.Mode {
+	case storage.ScopeHost:
+		if host != p.seed {
+			return false
+		}
+	case storage.ScopeDomain:
+		if registrableDomain(host) != p.domain {
+			return false
+		}
+	case storage.ScopeList:
+		if !inDomains(host, p.scope.AllowedDomains) {
+			return false
+		}
+	}
+
+	target := u.EscapedPath()
+	if u.RawQuery != "" {
+		target += "?" + u.RawQuery
+	}
+	for _, re := range p.exclude {
+		if re.MatchString(target) {
+			return false
+		}
+	}
+	if len(p.include) == 0 {
+		return true
+	}
+	for _, re := range p.include {
+		if re.MatchString(target) {
+			return true
+		}
+	}
+	return false
+}
+
+// inDomains сообщает, совпадает ли хост с одним из доменов или является его поддоменом.
+func inDomains(host string, domains []string) bool {
+	for _, d := range domains {
+		d = strings.ToLower(strings.TrimPrefix(d, "."))
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+func registrableDomain(host string) string {
+	host = strings.ToLower(host)
+	if domain, err := publicsuffix.EffectiveTLDPlusOne(host); err == nil {
+		return domain
+	}
+	return host
+}
+
+// scopeCache хранит скомпилированные политики, чтобы не разбирать
+// регулярные выражения заново для каждой ссылки.
+type scopeCache struct {
+	mu       sync.Mutex
+	policies map[string]*scopePolicy
+}
+
+func newScopeCache() *scopeCache {
+	return &scopeCache{policies: make(map[string]*scopePolicy)}
+}
+
+func (c *scopeCache) get(scope storage.CrawlScope) (*scopePolicy, error) {
+	raw, err := json.Marshal(scope)
+	if err != nil {
+		return nil, err
+	}
+	key := string(raw)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if p, ok := c.policies[key]; ok {
+		return p, nil
+	}
+	p, err := compileScope(scope)
+	if err != nil {
+		return nil, err
+	}
+	c.policies[key] = p
+	return p, nil
+}
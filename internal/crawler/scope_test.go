@@ -0,0 +1,58 @@
+package crawler
+
+import (
+	"net/url"
+	"testing"
+
+	"cis-engine/internal/storage"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestScopePolicy(t *testing.T) {
+	mustParse := func(raw string) *url.URL {
+		u, err := url.Parse(raw)
+		require.NoError(t, err)
+		return u
+	}
+
+	t.Run("Только хост начального URL", func(t *testing.T) {
+		p, err := compileScope(storage.CrawlScope{Mode: storage.ScopeHost, Seed: "go.dev:443"})
+		require.NoError(t, err)
+		require.True(t, p.allows(mustParse("https://go.dev/doc"), 1))
+		require.False(t, p.allows(mustParse("https://pkg.go.dev/fmt"), 1))
+	})
+
+	t.Run("Регистрируемый домен", func(t *testing.T) {
+		p, err := compileScope(storage.CrawlScope{Mode: storage.ScopeDomain, Seed: "www.example.co.uk"})
+		require.NoError(t, err)
+		require.True(t, p.allows(mustParse("https://blog.example.co.uk/"), 1))
+		require.False(t, p.allows(mustParse("https://other.co.uk/"), 1))
+	})
+
+	t.Run("Список доменов", func(t *testing.T) {
+		p, err := compileScope(storage.CrawlScope{Mode: storage.ScopeList, AllowedDomains: []string{"go.dev", "golang.org"}})
+		require.NoError(t, err)
+		require.True(t, p.allows(mustParse("https://pkg.go.dev/"), 1))
+		require.True(t, p.allows(mustParse("https://golang.org/"), 1))
+		require.False(t, p.allows(mustParse("https://notgo.dev/"), 1))
+	})
+
+	t.Run("Глубина и шаблоны", func(t *testing.T) {
+		p, err := compileScope(storage.CrawlScope{
+			MaxDepth: 2,
+			Include:  []string{"^/blog/"},
+			Exclude:  []string{`[?&]page=\d+`},
+		})
+		require.NoError(t, err)
+		require.True(t, p.allows(mustParse("https://a.example/blog/post"), 2))
+		require.False(t, p.allows(mustParse("https://a.example/blog/post"), 3))
+		require.False(t, p.allows(mustParse("https://a.example/about"), 1))
+		require.False(t, p.allows(mustParse("https://a.example/blog/?page=2"), 1))
+	})
+
+	t.Run("Некорректная область", func(t *testing.T) {
+		_, err := compileScope(storage.CrawlScope{Mode: storage.ScopeList})
+		require.Error(t, err)
+	})
+}
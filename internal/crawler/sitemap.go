@@ -0,0 +1,138 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"net/url"
+
+	"cis-engine/internal/sitemap"
+	"cis-engine/internal/storage"
+)
+
+// AddSitemap добавляет в очередь файл sitemap; страницы из него получают
+// область обхода из конфигурации с хостом sitemap в качестве начального.
+func (c *Crawler) AddSitemap(ctx context.Context, rawURL string) error {
+	normalized, err := c.cfg.Normalization.Normalize(rawURL)
+	if err != nil {
+		return err
+	}
+	scope := c.cfg.Scope
+	scope.Seed = hostOf(normalized)
+	return c.storage.EnqueueURLs(ctx, []*storage.FrontierURL{{
+		URL:      normalized,
+		Priority: seedPriority,
+		Scope:    &scope,
+		Sitemap:  true,
+	}})
+}
+
+// discoverSitemaps при первом обращении к хосту добавляет в очередь его
+// sitemap из robots.txt или, если их там нет, /sitemap.xml.
+func (c *Crawler) discoverSitemaps(ctx context.Context, job *storage.FrontierURL, scope storage.CrawlScope) {
+	u, err := url.Parse(job.URL)
+	if err != nil {
+		return
+	}
+	origin := u.Scheme + "://" + u.Host
+	if !c.sitemapOrigins.AddIfNotExists(origin) {
+		return
+	}
+	rules, err := c.robots.Get(ctx, u)
+	if err != nil {
+		return
+	}
+
+	locs := rules.Sitemaps
+	if len(locs) == 0 {
+		locs = []string{origin + "/sitemap.xml"}
+	}
+	urls := make([]*storage.FrontierURL, 0, len(locs))
+	for _, loc := range locs {
+		normalized, err := resolveURL(origin, loc, c.cfg.Normalization)
+		if err != nil {
+			continue
+		}
+		urls = append(urls, &storage.FrontierURL{URL: normalized, Depth: job.Depth, Scope: &scope, Sitemap: true})
+	}
+	if err := c.storage.EnqueueURLs(ctx, urls); err != nil {
+		log.Printf("Ошибка добавления sitemap хоста %s в очередь: %v", origin, err)
+	}
+}
+
+// ingestSitemap разбирает загруженный sitemap и добавляет в очередь
+// входящие в область обхода вложенные sitemap и страницы с приоритетом,
+// временем изменения и интервалом обхода из sitemap.
+func (c *Crawler) ingestSitemap(ctx context.Context, job *storage.FrontierURL, resp *Response, scope storage.CrawlScope) {
+	sm, err := sitemap.Parse(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		if errors.Is(err, sitemap.ErrNotSitemap) {
+			log.Printf("%s не является sitemap", job.URL)
+		} else {
+			log.Printf("Ошибка разбора sitemap %s: %v", job.URL, err)
+		}
+		c.complete(ctx, job)
+		return
+	}
+
+	policy, err := c.scopes.get(scope)
+	if err != nil {
+		log.Printf("Некорректная область обхода: %v", err)
+		c.complete(ctx, job)
+		return
+	}
+
+	urls := make([]*storage.FrontierURL, 0, len(sm.Sitemaps)+len(sm.URLs))
+	for _, entry := range sm.Sitemaps {
+		loc, err := resolveURL(resp.URL, entry.Loc, c.cfg.Normalization)
+		if err != nil {
+			continue
+		}
+		u, err := url.Parse(loc)
+		if err != nil || !policy.allows(u, job.Depth) {
+			continue
+		}
+		urls = append(urls, &storage.FrontierURL{
+			URL:          loc,
+			Depth:        job.Depth,
+			Scope:        &scope,
+			Sitemap:      true,
+			LastModified: entry.LastMod,
+		})
+	}
+	for _, entry := range sm.URLs {
+		loc, err := resolveURL(resp.URL, entry.Loc, c.cfg.Normalization)
+		if err != nil {
+			continue
+		}
+		u, err := url.Parse(loc)
+		if err != nil || !policy.allows(u, job.Depth) {
+			continue
+		}
+		c.visited.AddIfNotExists(loc)
+		// yearly и never не должны откладывать обход дольше, чем допускает
+		// подбор интервала по изменениям страницы.
+		revisit := min(sitemap.ChangeInterval(entry.ChangeFreq), maxRevisitInterval)
+		urls = append(urls, &storage.FrontierURL{
+			URL:             loc,
+			Priority:        sitemapPriority(entry.Priority),
+			Depth:           job.Depth,
+			Scope:           &scope,
+			LastModified:    entry.LastMod,
+			RevisitInterval: revisit,
+		})
+	}
+	if err := c.storage.EnqueueURLs(ctx, urls); err != nil {
+		log.Printf("Ошибка добавления URL из sitemap %s в очередь: %v", job.URL, err)
+	}
+	log.Printf("Sitemap %s: %d страниц, %d вложенных sitemap", job.URL, len(sm.URLs), len(sm.Sitemaps))
+	c.complete(ctx, job)
+}
+
+// sitemapPriority переводит приоритет sitemap от 0 до 1 в приоритет
+// очереди: 1.0 соответствует seedPriority, ссылки со страниц имеют 0.
+func sitemapPriority(p float64) int {
+	return int(math.Round(p * seedPriority))
+}
@@ -0,0 +1,135 @@
+// Package dedup находит почти дубликаты страниц (зеркала, версии для
+// печати, страницы списков) по отпечаткам SimHash их текста.
+package dedup
+
+import (
+	"cmp"
+	"hash/fnv"
+	"math/bits"
+	"slices"
+
+	"cis-engine/internal/analysis"
+	"cis-engine/internal/storage"
+)
+
+const (
+	// MinWords - у текстов короче отпечаток не вычисляется: короткие
+	// страницы (ошибки, заглушки) слишком похожи друг на друга.
+	MinWords = 20
+	// DefaultMaxDistance - наибольшее расстояние Хэмминга между отпечатками
+	// почти дубликатов.
+	DefaultMaxDistance = 3
+	// MaxDistance - верхняя граница расстояния для Cluster: при ней блоки
+	// отпечатка имеют ширину 8 бит и еще отбирают немногих кандидатов, а
+	// при более узких блоках сравнение вырождается в попарное.
+	MaxDistance = 7
+)
+
+// Fingerprint вычисляет SimHash текста: признаками служат его термы с весом,
+// равным числу вхождений. Для текстов короче MinWords слов возвращается 0 -
+// отпечаток отсутствует.
+func Fingerprint(text string) uint64 {
+	terms := analysis.Terms(text)
+	if len(terms) < MinWords {
+		return 0
+	}
+
+	var weights [64]int
+	h := fnv.New64a()
+	for _, term := range terms {
+		h.Reset()
+		h.Write([]byte(term))
+		sum := h.Sum64()
+		for bit := range weights {
+			if sum&(1<<bit) != 0 {
+				weights[bit]++
+			} else {
+				weights[bit]--
+			}
+		}
+	}
+
+	var fp uint64
+	for bit, w := range weights {
+		if w > 0 {
+			fp |= 1 << bit
+		}
+	}
+	// Нулевой отпечаток зарезервирован за отсутствующим.
+	return max(fp, 1)
+}
+
+// Distance - расстояние Хэмминга между отпечатками.
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// Cluster объединяет страницы, отпечатки которых отличаются не больше чем
+// на maxDistance бит, и выбирает в каждой группе каноническую страницу:
+// с наибольшей авторитетностью, затем с самым коротким URL. Возвращает
+// для каждого дубликата ID его канонической страницы; канонические
+// страницы и страницы без отпечатка в результат не входят. maxDistance
+// ограничивается диапазоном от 0 до MaxDistance.
+func Cluster(pages []*storage.PageFingerprint, maxDistance int) map[int64]int64 {
+	maxDistance = min(max(maxDistance, 0), MaxDistance)
+	pages = slices.DeleteFunc(slices.Clone(pages), func(p *storage.PageFingerprint) bool { return p.SimHash == 0 })
+	parent := make([]int, len(pages))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	// Если отпечатки отличаются не больше чем на maxDistance бит, то хотя бы
+	// один из maxDistance+1 блоков у них совпадает, поэтому сравниваются
+	// только страницы с общим блоком.
+	blocks := maxDistance + 1
+	width := 64 / blocks
+	for b := range blocks {
+		shift := b * width
+		mask := uint64(1)<<width - 1
+		if b == blocks-1 {
+			mask = ^uint64(0) >> shift
+		}
+		buckets := make(map[uint64][]int)
+		for i, p := range pages {
+			key := p.SimHash >> shift & mask
+			for _, j := range buckets[key] {
+				if Distance(p.SimHash, pages[j].SimHash) <= maxDistance {
+					parent[find(i)] = find(j)
+				}
+			}
+			buckets[key] = append(buckets[key], i)
+		}
+	}
+
+	groups := make(map[int][]*storage.PageFingerprint)
+	for i, p := range pages {
+		root := find(i)
+		groups[root] = append(groups[root], p)
+	}
+	duplicates := make(map[int64]int64)
+	for _, group := range groups {
+		if len(group) < 2 {
+			continue
+		}
+		canonical := slices.MinFunc(group, func(a, b *storage.PageFingerprint) int {
+			return cmp.Or(
+				cmp.Compare(b.Authority, a.Authority),
+				cmp.Compare(len(a.URL), len(b.URL)),
+				cmp.Compare(a.ID, b.ID),
+			)
+		})
+		for _, p := range group {
+			if p != canonical {
+				duplicates[p.ID] = canonical.ID
+			}
+		}
+	}
+	return duplicates
+}
@@ -0,0 +1,55 @@
+package dedup
+
+import (
+	"strings"
+	"testing"
+
+	"cis-engine/internal/storage"
+
+	"github.com/stretchr/testify/require"
+)
+
+const article = `Go is an open source programming language supported by Google. It is easy to learn
+and great for teams, has built-in concurrency and a robust standard library, and a large ecosystem
+of partners, communities and tools. Go is used by companies to build fast, reliable and efficient
+software at scale: cloud and network services, command-line tools, web development and DevOps.`
+
+func TestFingerprint(t *testing.T) {
+	fp := Fingerprint(article)
+	require.NotZero(t, fp)
+	require.Equal(t, fp, Fingerprint(strings.ToUpper(article)), "регистр не учитывается")
+
+	printVersion := article + " Printed from example.com"
+	require.LessOrEqual(t, Distance(fp, Fingerprint(printVersion)), DefaultMaxDistance)
+
+	other := Fingerprint(`Vue.js is a progressive, incrementally-adoptable JavaScript framework for building
+UI on the web. It builds on top of standard HTML, CSS and JavaScript and provides a declarative,
+component-based programming model that helps you efficiently develop user interfaces of any complexity.`)
+	require.Greater(t, Distance(fp, other), 10)
+
+	require.Zero(t, Fingerprint("Страница не найдена"), "у коротких текстов нет отпечатка")
+}
+
+func TestCluster(t *testing.T) {
+	pages := []*storage.PageFingerprint{
+		{ID: 1, URL: "https://mirror.example/docs/intro", SimHash: 0b1011_0000},
+		{ID: 2, URL: "https://example.com/docs", SimHash: 0b1011_0011},
+		{ID: 3, URL: "https://example.com/docs?print=1", SimHash: 0b1111_0001},
+		{ID: 4, URL: "https://example.com/other", SimHash: 0xffff_0000_0000_0000},
+		{ID: 5, URL: "https://example.com/empty"},
+	}
+	require.Equal(t, map[int64]int64{1: 2, 3: 2}, Cluster(pages, DefaultMaxDistance),
+		"каноническая страница - с самым коротким URL")
+
+	pages[0].Authority = 0.5
+	require.Equal(t, map[int64]int64{2: 1, 3: 1}, Cluster(pages, DefaultMaxDistance),
+		"авторитетность важнее длины URL")
+
+	require.Empty(t, Cluster(pages, 0))
+
+	far := []*storage.PageFingerprint{
+		{ID: 1, URL: "https://example.com/a", SimHash: 1 << 40},
+		{ID: 2, URL: "https://example.com/b", SimHash: 1<<40 | 0x1ff},
+	}
+	require.Empty(t, Cluster(far, 15), "расстояние ограничивается MaxDistance")
+}
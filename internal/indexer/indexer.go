@@ -4,55 +4,130 @@ import (
 	"cis-engine/internal/storage"
 	"context"
 	"log"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// Config задает параметры индексации.
+type Config struct {
+	// BatchSize - сколько страниц индексируется за один запрос к хранилищу.
+	BatchSize int
+	// Workers - число одновременно индексирующих горутин.
+	Workers int
+	// PollInterval - как часто проверять очередь, если уведомления о новых
+	// страницах не приходят или недоступны.
+	PollInterval time.Duration
+}
+
+func DefaultConfig() Config {
+	return Config{
+		BatchSize:    100,
+		Workers:      4,
+		PollInterval: 30 * time.Second,
+	}
+}
+
 type Indexer struct {
-	storage  storage.Storer
-	ticker   *time.Ticker
-	doneChan chan bool
+	storage storage.Storer
+	cfg     Config
+	stop    chan struct{}
+	done    chan struct{}
 }
 
-func NewIndexer(s storage.Storer, interval time.Duration) *Indexer {
+func NewIndexer(s storage.Storer, cfg Config) *Indexer {
 	return &Indexer{
-		storage:  s,
-		ticker:   time.NewTicker(interval),
-		doneChan: make(chan bool),
+		storage: s,
+		cfg:     cfg,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
 	}
 }
 
+// Start индексирует страницы, пока не будет вызван Stop. Очередь
+// разбирается до конца при запуске, после уведомления о сохранении
+// страниц и раз в PollInterval.
 func (i *Indexer) Start(ctx context.Context) {
+	defer close(i.done)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-i.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
 	log.Println("Сервис индексации запущен.")
+	ticker := time.NewTicker(i.cfg.PollInterval)
+	defer ticker.Stop()
+
+	events := i.watch(ctx)
 	for {
+		i.drain(ctx)
+
 		select {
-		case <-i.doneChan:
+		case <-ctx.Done():
 			log.Println("Сервис индексации остановлен.")
 			return
-		case <-i.ticker.C:
-			err := i.indexNextPage(ctx)
-			if err != nil {
-				log.Printf("Ошибка при индексации страницы: %v", err)
+		case _, ok := <-events:
+			if !ok {
+				events = nil
+			}
+		case <-ticker.C:
+			if events == nil {
+				events = i.watch(ctx)
 			}
 		}
 	}
 }
 
+// Stop останавливает индексацию и ждет завершения текущих пакетов.
 func (i *Indexer) Stop() {
-	i.ticker.Stop()
-	i.doneChan <- true
+	close(i.stop)
+	<-i.done
 }
 
-func (i *Indexer) indexNextPage(ctx context.Context) error {
-	page, err := i.storage.GetNextPageToIndex(ctx)
+// watch подписывается на уведомления о новых страницах. При ошибке
+// возвращает nil, и индексатор опрашивает очередь по таймеру.
+func (i *Indexer) watch(ctx context.Context) <-chan struct{} {
+	events, err := i.storage.WatchPages(ctx)
 	if err != nil {
-		return err
-	}
-
-	if page == nil {
+		log.Printf("Уведомления о новых страницах недоступны, используется опрос: %v", err)
 		return nil
 	}
+	return events
+}
 
-	log.Printf("Индексируется страница #%d (%s)", page.ID, page.URL)
+// drain индексирует страницы пулом воркеров, пока очередь не опустеет.
+func (i *Indexer) drain(ctx context.Context) {
+	var total atomic.Int64
+	start := time.Now()
 
-	return i.storage.UpdatePageVector(ctx, page)
+	var wg sync.WaitGroup
+	for range i.cfg.Workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				n, err := i.storage.IndexPages(ctx, i.cfg.BatchSize)
+				if err != nil {
+					if ctx.Err() == nil {
+						log.Printf("Ошибка при индексации страниц: %v", err)
+					}
+					return
+				}
+				total.Add(int64(n))
+				if n < i.cfg.BatchSize {
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := total.Load(); n > 0 {
+		log.Printf("Проиндексировано страниц: %d за %s", n, time.Since(start).Round(time.Millisecond))
+	}
 }
@@ -0,0 +1,119 @@
+// Package lang определяет язык текста страниц.
+package lang
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Коды языков ISO 639-1, которые распознает Detect.
+const (
+	English    = "en"
+	Russian    = "ru"
+	German     = "de"
+	French     = "fr"
+	Spanish    = "es"
+	Italian    = "it"
+	Portuguese = "pt"
+	Dutch      = "nl"
+)
+
+// Normalize приводит языковой тег вида "en-US" или "RU_ru" к коду ISO 639-1.
+// Для некорректного тега возвращает пустую строку.
+func Normalize(tag string) string {
+	tag = strings.TrimSpace(tag)
+	if i := strings.IndexAny(tag, "-_"); i >= 0 {
+		tag = tag[:i]
+	}
+	tag = strings.ToLower(tag)
+	if len(tag) < 2 || len(tag) > 3 {
+		return ""
+	}
+	for _, r := range tag {
+		if r < 'a' || r > 'z' {
+			return ""
+		}
+	}
+	return tag
+}
+
+// Known сообщает, распознает ли Detect язык с кодом code.
+func Known(code string) bool {
+	_, ok := profiles[code]
+	return ok
+}
+
+// Resolve определяет язык страницы: сначала по атрибуту lang элемента
+// <html>, затем по заголовку Content-Language и в последнюю очередь по
+// тексту. Возвращает пустую строку, если язык определить не удалось.
+func Resolve(htmlLang, contentLanguage, text string) string {
+	if code := Normalize(htmlLang); code != "" {
+		return code
+	}
+	// Content-Language может перечислять несколько языков через запятую;
+	// берется первый.
+	first, _, _ := strings.Cut(contentLanguage, ",")
+	if code := Normalize(first); code != "" {
+		return code
+	}
+	return Detect(text)
+}
+
+// maxDetectText ограничивает объем текста, по которому определяется язык.
+const maxDetectText = 4096
+
+// minDetectLetters - на более коротком тексте язык не определяется.
+const minDetectLetters = 20
+
+// Detect определяет язык текста по частотам символьных триграмм.
+// Возвращает пустую строку, если текст слишком короткий.
+func Detect(text string) string {
+	if len(text) > maxDetectText {
+		text = text[:maxDetectText]
+	}
+
+	var letters, cyrillic int
+	for _, r := range text {
+		if unicode.IsLetter(r) {
+			letters++
+			if unicode.Is(unicode.Cyrillic, r) {
+				cyrillic++
+			}
+		}
+	}
+	if letters < minDetectLetters {
+		return ""
+	}
+	// Из поддерживаемых языков кириллицу использует только русский.
+	if cyrillic*2 > letters {
+		return Russian
+	}
+
+	grams := trigrams(text)
+	best, bestScore := "", 0.0
+	for code, p := range profiles {
+		if code == Russian {
+			continue
+		}
+		if score := p.score(grams); best == "" || score > bestScore {
+			best, bestScore = code, score
+		}
+	}
+	return best
+}
+
+// trigrams разбивает текст на слова и возвращает частоты триграмм слов,
+// дополненных пробелами по краям.
+func trigrams(text string) map[string]int {
+	grams := make(map[string]int)
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	for _, w := range words {
+		runes := []rune(" " + w + " ")
+		for i := 0; i+3 <= len(runes); i++ {
+			grams[string(runes[i:i+3])]++
+		}
+	}
+	return grams
+}
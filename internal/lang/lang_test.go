@@ -0,0 +1,45 @@
+package lang
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetect(t *testing.T) {
+	cases := map[string]string{
+		English:    "Go is an open source programming language that makes it easy to build simple, reliable, and efficient software.",
+		Russian:    "Поисковый движок обходит сайты, сохраняет страницы и строит полнотекстовый индекс.",
+		German:     "Go ist eine quelloffene Programmiersprache, mit der sich einfache und zuverlässige Software schreiben lässt.",
+		French:     "Go est un langage de programmation open source qui permet de créer facilement des logiciels simples et fiables.",
+		Spanish:    "Go es un lenguaje de programación de código abierto que facilita la creación de software sencillo y fiable.",
+		Italian:    "Go è un linguaggio di programmazione open source che rende facile costruire software semplice e affidabile.",
+		Portuguese: "Go é uma linguagem de programação de código aberto que facilita a criação de software simples e confiável.",
+		Dutch:      "Go is een opensource programmeertaal waarmee je eenvoudig betrouwbare en efficiënte software kunt bouwen.",
+	}
+	for want, text := range cases {
+		t.Run(want, func(t *testing.T) {
+			require.Equal(t, want, Detect(text))
+		})
+	}
+
+	t.Run("короткий текст", func(t *testing.T) {
+		require.Equal(t, "", Detect("Go 1.24"))
+	})
+}
+
+func TestResolve(t *testing.T) {
+	text := "Go is an open source programming language that makes it easy to build software."
+
+	require.Equal(t, "de", Resolve("de-AT", "fr", text))
+	require.Equal(t, "fr", Resolve("", "fr-CA, en", text))
+	require.Equal(t, "en", Resolve("", "", text))
+	require.Equal(t, "en", Resolve("x-invalid-", "", text))
+}
+
+func TestNormalize(t *testing.T) {
+	require.Equal(t, "en", Normalize("en-US"))
+	require.Equal(t, "ru", Normalize(" RU_ru "))
+	require.Equal(t, "", Normalize("english"))
+	require.Equal(t, "", Normalize(""))
+}
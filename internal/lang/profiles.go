@@ -0,0 +1,122 @@
+package lang
+
+import "math"
+
+// Образцы текста, по которым строятся триграммные профили языков. Образцы
+// составлены из частотных служебных слов и типичных фраз веб-страниц.
+var samples = map[string]string{
+	English: `The quick brown fox jumps over the lazy dog. This is the documentation for the
+		package and it describes how to install, configure and use the library in your own
+		projects. You can find more information about the language, the standard library and
+		the tools on the official website. We would like to thank all of the people who have
+		contributed to this project over the years. Please read the following instructions
+		carefully before you start. There are many ways to write programs, but some of them are
+		better than others. What is the best way to learn something new? It should be simple,
+		reliable and efficient, and it would help if the examples were short and clear.
+		Getting started with the tutorial is easy: download the latest release, open the
+		terminal and run the command. Which of these options do you want? All rights reserved.
+		Read more about our privacy policy, terms of service and contact us for support.`,
+	Russian: `Съешь же ещё этих мягких французских булок, да выпей чаю. Это документация по
+		пакету, в которой описано, как установить, настроить и использовать библиотеку в своих
+		проектах. Больше информации о языке, стандартной библиотеке и инструментах можно найти
+		на официальном сайте. Мы благодарим всех, кто участвовал в развитии проекта. Пожалуйста,
+		внимательно прочитайте следующие инструкции перед началом работы. Все права защищены.`,
+	German: `Der schnelle braune Fuchs springt über den faulen Hund. Dies ist die Dokumentation
+		für das Paket und sie beschreibt, wie man die Bibliothek in eigenen Projekten installiert,
+		konfiguriert und verwendet. Weitere Informationen über die Sprache, die Standardbibliothek
+		und die Werkzeuge finden Sie auf der offiziellen Webseite. Wir möchten uns bei allen
+		bedanken, die im Laufe der Jahre zu diesem Projekt beigetragen haben. Bitte lesen Sie die
+		folgenden Anweisungen sorgfältig, bevor Sie beginnen. Es gibt viele Möglichkeiten, Programme
+		zu schreiben, aber nicht alle sind gleich gut. Was ist der beste Weg, etwas Neues zu lernen?
+		Es sollte einfach, zuverlässig und effizient sein, und die Beispiele sollten kurz und klar
+		sein. Der Einstieg ist leicht: laden Sie die neueste Version herunter, öffnen Sie das Terminal
+		und führen Sie den Befehl aus. Alle Rechte vorbehalten. Datenschutz und Impressum.`,
+	French: `Portez ce vieux whisky au juge blond qui fume. Ceci est la documentation du paquet
+		et elle décrit comment installer, configurer et utiliser la bibliothèque dans vos propres
+		projets. Vous trouverez plus d'informations sur le langage, la bibliothèque standard et les
+		outils sur le site officiel. Nous remercions toutes les personnes qui ont contribué à ce
+		projet au cours des années. Veuillez lire attentivement les instructions suivantes avant de
+		commencer. Il existe de nombreuses façons d'écrire des programmes, mais elles ne sont pas
+		toutes aussi bonnes. Quelle est la meilleure façon d'apprendre quelque chose de nouveau ?
+		Cela doit être simple, fiable et efficace, et les exemples doivent être courts et clairs.
+		Pour commencer, téléchargez la dernière version, ouvrez le terminal et lancez la commande.
+		Tous droits réservés. Politique de confidentialité et mentions légales.`,
+	Spanish: `El veloz murciélago hindú comía feliz cardillo y kiwi. Esta es la documentación del
+		paquete y describe cómo instalar, configurar y usar la biblioteca en sus propios proyectos.
+		Puede encontrar más información sobre el lenguaje, la biblioteca estándar y las herramientas
+		en el sitio oficial. Queremos agradecer a todas las personas que han contribuido a este
+		proyecto durante los años. Por favor, lea atentamente las siguientes instrucciones antes de
+		empezar. Hay muchas formas de escribir programas, pero no todas son igual de buenas. ¿Cuál es
+		la mejor manera de aprender algo nuevo? Debe ser sencillo, fiable y eficiente, y los ejemplos
+		deben ser cortos y claros. Para empezar, descargue la última versión, abra el terminal y
+		ejecute el comando. Todos los derechos reservados. Política de privacidad y aviso legal.`,
+	Italian: `Quel vituperabile xenofobo zelante assaggia il whisky ed esclama alleluja. Questa è
+		la documentazione del pacchetto e descrive come installare, configurare e usare la libreria
+		nei propri progetti. Puoi trovare maggiori informazioni sul linguaggio, sulla libreria
+		standard e sugli strumenti nel sito ufficiale. Vogliamo ringraziare tutte le persone che
+		hanno contribuito a questo progetto nel corso degli anni. Si prega di leggere attentamente le
+		seguenti istruzioni prima di iniziare. Ci sono molti modi per scrivere programmi, ma non sono
+		tutti ugualmente buoni. Qual è il modo migliore per imparare qualcosa di nuovo? Dovrebbe essere
+		semplice, affidabile ed efficiente, e gli esempi dovrebbero essere brevi e chiari. Per
+		iniziare, scarica l'ultima versione, apri il terminale ed esegui il comando. Tutti i diritti
+		riservati. Informativa sulla privacy e note legali.`,
+	Portuguese: `Um pequeno jabuti xereta viu dez cegonhas felizes. Esta é a documentação do
+		pacote e descreve como instalar, configurar e usar a biblioteca nos seus próprios projetos.
+		Você pode encontrar mais informações sobre a linguagem, a biblioteca padrão e as ferramentas
+		no site oficial. Queremos agradecer a todas as pessoas que contribuíram para este projeto ao
+		longo dos anos. Por favor, leia com atenção as seguintes instruções antes de começar. Existem
+		muitas maneiras de escrever programas, mas nem todas são igualmente boas. Qual é a melhor
+		maneira de aprender algo novo? Deve ser simples, confiável e eficiente, e os exemplos devem
+		ser curtos e claros. Para começar, baixe a versão mais recente, abra o terminal e execute o
+		comando. Todos os direitos reservados. Política de privacidade e termos de uso.`,
+	Dutch: `Pa's wijze lynx bezag vroom het fikse aquaduct. Dit is de documentatie van het pakket
+		en het beschrijft hoe je de bibliotheek in je eigen projecten installeert, configureert en
+		gebruikt. Meer informatie over de taal, de standaardbibliotheek en de hulpmiddelen vind je
+		op de officiële website. Wij willen iedereen bedanken die in de loop der jaren aan dit
+		project heeft bijgedragen. Lees de volgende instructies zorgvuldig voordat je begint. Er
+		zijn veel manieren om programma's te schrijven, maar ze zijn niet allemaal even goed. Wat is
+		de beste manier om iets nieuws te leren? Het moet eenvoudig, betrouwbaar en efficiënt zijn,
+		en de voorbeelden moeten kort en duidelijk zijn. Om te beginnen download je de nieuwste
+		versie, open je de terminal en voer je de opdracht uit. Alle rechten voorbehouden.`,
+}
+
+// unseenLogProb - логарифм вероятности триграммы, отсутствующей в образце.
+var unseenLogProb = math.Log(1e-4)
+
+type profile struct {
+	logProb map[string]float64
+}
+
+func newProfile(sample string) *profile {
+	grams := trigrams(sample)
+	var total int
+	for _, n := range grams {
+		total += n
+	}
+	p := &profile{logProb: make(map[string]float64, len(grams))}
+	for g, n := range grams {
+		p.logProb[g] = math.Log(float64(n) / float64(total))
+	}
+	return p
+}
+
+// score - логарифм правдоподобия текста с частотами триграмм grams.
+func (p *profile) score(grams map[string]int) float64 {
+	var s float64
+	for g, n := range grams {
+		lp, ok := p.logProb[g]
+		if !ok {
+			lp = unseenLogProb
+		}
+		s += float64(n) * lp
+	}
+	return s
+}
+
+var profiles = func() map[string]*profile {
+	m := make(map[string]*profile, len(samples))
+	for code, sample := range samples {
+		m[code] = newProfile(sample)
+	}
+	return m
+}()
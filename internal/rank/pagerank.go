@@ -0,0 +1,106 @@
+// Package rank вычисляет авторитетность страниц по графу ссылок.
+package rank
+
+import (
+	"math"
+
+	"cis-engine/internal/storage"
+)
+
+// Options - параметры PageRank.
+type Options struct {
+	// Damping - вероятность перехода по ссылке (обычно 0.85).
+	Damping float64
+	// Iterations - максимальное число итераций.
+	Iterations int
+	// Tolerance - итерации прекращаются, когда суммарное изменение рангов меньше этого значения.
+	Tolerance float64
+}
+
+func DefaultOptions() Options {
+	return Options{Damping: 0.85, Iterations: 50, Tolerance: 1e-6}
+}
+
+// PageRank вычисляет ранги страниц степенным методом. Ранг страниц без
+// исходящих ссылок распределяется равномерно по всем страницам. Сумма
+// рангов равна 1.
+func PageRank(graph *storage.LinkGraph, opts Options) map[int64]float64 {
+	n := len(graph.Nodes)
+	if n == 0 {
+		return map[int64]float64{}
+	}
+
+	index := make(map[int64]int, n)
+	for i, id := range graph.Nodes {
+		index[id] = i
+	}
+
+	outDegree := make([]int, n)
+	inbound := make([][]int, n)
+	for _, e := range graph.Edges {
+		from, okFrom := index[e.From]
+		to, okTo := index[e.To]
+		if !okFrom || !okTo || from == to {
+			continue
+		}
+		outDegree[from]++
+		inbound[to] = append(inbound[to], from)
+	}
+
+	ranks := make([]float64, n)
+	next := make([]float64, n)
+	for i := range ranks {
+		ranks[i] = 1 / float64(n)
+	}
+
+	for iter := 0; iter < opts.Iterations; iter++ {
+		var dangling float64
+		for i, r := range ranks {
+			if outDegree[i] == 0 {
+				dangling += r
+			}
+		}
+		base := (1-opts.Damping)/float64(n) + opts.Damping*dangling/float64(n)
+
+		var delta float64
+		for i := range next {
+			var sum float64
+			for _, from := range inbound[i] {
+				sum += ranks[from] / float64(outDegree[from])
+			}
+			next[i] = base + opts.Damping*sum
+			delta += math.Abs(next[i] - ranks[i])
+		}
+		ranks, next = next, ranks
+
+		if delta < opts.Tolerance {
+			break
+		}
+	}
+
+	scores := make(map[int64]float64, n)
+	for i, id := range graph.Nodes {
+		scores[id] = ranks[i]
+	}
+	return scores
+}
+
+// Normalize переводит ранги в шкалу [0, 1] в логарифмическом масштабе,
+// чтобы их можно было смешивать с текстовой релевантностью: страница со
+// средним рангом получает ненулевую оценку, а лидеры не подавляют остальных.
+func Normalize(scores map[int64]float64) map[int64]float64 {
+	n := float64(len(scores))
+	var maxScore float64
+	for _, s := range scores {
+		maxScore = max(maxScore, s)
+	}
+
+	normalized := make(map[int64]float64, len(scores))
+	denominator := math.Log1p(maxScore * n)
+	for id, s := range scores {
+		if denominator > 0 {
+			normalized[id] = math.Log1p(s*n) / denominator
+		}
+	}
+	return normalized
+}
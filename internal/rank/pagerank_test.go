@@ -0,0 +1,38 @@
+package rank
+
+import (
+	"testing"
+
+	"cis-engine/internal/storage"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPageRank(t *testing.T) {
+	// 1 и 2 ссылаются на 3, 3 ссылается на 1; 4 - без ссылок.
+	graph := &storage.LinkGraph{
+		Nodes: []int64{1, 2, 3, 4},
+		Edges: []storage.Edge{{From: 1, To: 3}, {From: 2, To: 3}, {From: 3, To: 1}, {From: 3, To: 3}},
+	}
+
+	scores := PageRank(graph, DefaultOptions())
+
+	var sum float64
+	for _, s := range scores {
+		sum += s
+	}
+	require.InDelta(t, 1.0, sum, 1e-6)
+	require.Greater(t, scores[3], scores[1])
+	require.Greater(t, scores[1], scores[2])
+	require.InDelta(t, scores[2], scores[4], 1e-9)
+
+	normalized := Normalize(scores)
+	require.InDelta(t, 1.0, normalized[3], 1e-9)
+	require.Greater(t, normalized[2], 0.0)
+	require.Less(t, normalized[2], normalized[1])
+}
+
+func TestPageRankEmpty(t *testing.T) {
+	require.Empty(t, PageRank(&storage.LinkGraph{}, DefaultOptions()))
+	require.Empty(t, Normalize(map[int64]float64{}))
+}
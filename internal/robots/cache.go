@@ -0,0 +1,145 @@
+package robots
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// maxRobotsSize - сколько байт robots.txt разбирается (RFC 9309 требует не меньше 500 КиБ).
+const maxRobotsSize = 512 * 1024
+
+// errorTTL - сколько хранится результат неудачной загрузки robots.txt.
+// Срок короткий, чтобы повторная попытка обхода URL загрузила его заново.
+const errorTTL = time.Minute
+
+// ErrUnavailable возвращается, если robots.txt временно недоступен: сетевая
+// ошибка, ответ 5xx или 429. URL хоста следует проверить позже.
+var ErrUnavailable = errors.New("robots.txt временно недоступен")
+
+// Cache загружает и кэширует robots.txt для каждого хоста.
+type Cache struct {
+	client    *http.Client
+	userAgent string
+	ttl       time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*entry
+}
+
+type entry struct {
+	ready     chan struct{}
+	robots    *Robots
+	err       error
+	expiresAt time.Time
+	// aborted - загрузка прервана отменой контекста загружавшего.
+	aborted bool
+}
+
+func NewCache(client *http.Client, userAgent string, ttl time.Duration) *Cache {
+	return &Cache{
+		client:    client,
+		userAgent: userAgent,
+		ttl:       ttl,
+		entries:   make(map[string]*entry),
+	}
+}
+
+// Get возвращает правила для хоста URL. Одновременные запросы к одному
+// хосту дожидаются единственной загрузки robots.txt. Если robots.txt
+// временно недоступен, возвращается ошибка ErrUnavailable.
+func (c *Cache) Get(ctx context.Context, u *url.URL) (*Robots, error) {
+	key := u.Scheme + "://" + u.Host
+
+	for {
+		c.mu.Lock()
+		e, ok := c.entries[key]
+		if ok {
+			select {
+			case <-e.ready:
+				if time.Now().After(e.expiresAt) {
+					ok = false
+				}
+			default:
+			}
+		}
+		if !ok {
+			e = &entry{ready: make(chan struct{})}
+			c.entries[key] = e
+			c.mu.Unlock()
+
+			robots, ttl, err := c.fetch(ctx, key)
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				// Загрузка прервана - не запоминаем результат.
+				c.mu.Lock()
+				delete(c.entries, key)
+				c.mu.Unlock()
+				e.aborted = true
+				close(e.ready)
+				return nil, ctxErr
+			}
+			e.robots, e.err = robots, err
+			e.expiresAt = time.Now().Add(ttl)
+			close(e.ready)
+			return robots, err
+		}
+		c.mu.Unlock()
+
+		select {
+		case <-e.ready:
+			if e.aborted {
+				// Загружавший отменил запрос - загружаем заново.
+				continue
+			}
+			return e.robots, e.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Allowed - сокращение для Get + Robots.Allowed.
+func (c *Cache) Allowed(ctx context.Context, u *url.URL) (bool, *Robots, error) {
+	robots, err := c.Get(ctx, u)
+	if err != nil {
+		return false, nil, err
+	}
+	path := u.EscapedPath()
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	return robots.Allowed(c.userAgent, path), robots, nil
+}
+
+func (c *Cache) fetch(ctx context.Context, origin string) (*Robots, time.Duration, error) {
+	robotsURL := origin + "/robots.txt"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, robotsURL, nil)
+	if err != nil {
+		log.Printf("Не удалось создать запрос к %s: %v", robotsURL, err)
+		return DisallowAll, errorTTL, nil
+	}
+	req.Header.Set("User-Agent", c.userAgent)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, errorTTL, fmt.Errorf("%w: ошибка загрузки %s: %v", ErrUnavailable, robotsURL, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return Parse(io.LimitReader(resp.Body, maxRobotsSize)), c.ttl, nil
+	case resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests:
+		// robots.txt отсутствует или недоступен - ограничений нет.
+		return AllowAll, c.ttl, nil
+	default:
+		return nil, errorTTL, fmt.Errorf("%w: сервер вернул %s для %s", ErrUnavailable, resp.Status, robotsURL)
+	}
+}
@@ -0,0 +1,182 @@
+package robots
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Robots - разобранный robots.txt одного хоста.
+type Robots struct {
+	groups   []*group
+	Sitemaps []string
+}
+
+type group struct {
+	agents     []string
+	rules      []rule
+	crawlDelay time.Duration
+}
+
+type rule struct {
+	allow   bool
+	pattern string
+}
+
+// AllowAll - правила, разрешающие обход всего сайта (например, при 404 на robots.txt).
+var AllowAll = &Robots{}
+
+// DisallowAll - правила, запрещающие обход всего сайта (например, при 5xx на robots.txt).
+var DisallowAll = &Robots{groups: []*group{{agents: []string{"*"}, rules: []rule{{allow: false, pattern: "/"}}}}}
+
+// Parse разбирает robots.txt по RFC 9309. Неизвестные директивы и
+// некорректные строки пропускаются.
+func Parse(r io.Reader) *Robots {
+	robots := &Robots{}
+	var current *group
+	// Подряд идущие строки User-agent относятся к одной группе.
+	lastWasAgent := false
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 512*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			if !lastWasAgent || current == nil {
+				current = &group{}
+				robots.groups = append(robots.groups, current)
+			}
+			current.agents = append(current.agents, strings.ToLower(value))
+			lastWasAgent = true
+			continue
+		case "allow", "disallow":
+			if current != nil && value != "" {
+				current.rules = append(current.rules, rule{allow: key == "allow", pattern: value})
+			}
+		case "crawl-delay":
+			if current != nil {
+				if secs, err := strconv.ParseFloat(value, 64); err == nil && secs > 0 {
+					current.crawlDelay = time.Duration(secs * float64(time.Second))
+				}
+			}
+		case "sitemap":
+			if value != "" {
+				robots.Sitemaps = append(robots.Sitemaps, value)
+			}
+		}
+		lastWasAgent = false
+	}
+
+	return robots
+}
+
+// Allowed сообщает, разрешено ли агенту загружать путь (с query-строкой).
+// Побеждает самое длинное совпавшее правило, при равенстве - Allow.
+func (r *Robots) Allowed(agent, path string) bool {
+	if path == "" {
+		path = "/"
+	}
+	if path == "/robots.txt" {
+		return true
+	}
+
+	allowed := true
+	longest := -1
+	for _, g := range r.groupsFor(agent) {
+		for _, rl := range g.rules {
+			if !match(rl.pattern, path) {
+				continue
+			}
+			if len(rl.pattern) > longest || (len(rl.pattern) == longest && rl.allow) {
+				longest = len(rl.pattern)
+				allowed = rl.allow
+			}
+		}
+	}
+	return allowed
+}
+
+// CrawlDelay возвращает задержку между запросами для агента или 0.
+func (r *Robots) CrawlDelay(agent string) time.Duration {
+	var delay time.Duration
+	for _, g := range r.groupsFor(agent) {
+		if g.crawlDelay > delay {
+			delay = g.crawlDelay
+		}
+	}
+	return delay
+}
+
+// groupsFor выбирает группы, относящиеся к агенту. Группы с совпавшим
+// именем объединяются; группа "*" используется, только если таких нет.
+func (r *Robots) groupsFor(agent string) []*group {
+	token := productToken(agent)
+
+	var matched, wildcard []*group
+	for _, g := range r.groups {
+		for _, a := range g.agents {
+			if a == "*" {
+				wildcard = append(wildcard, g)
+				break
+			}
+			if a != "" && strings.Contains(token, a) {
+				matched = append(matched, g)
+				break
+			}
+		}
+	}
+	if len(matched) > 0 {
+		return matched
+	}
+	return wildcard
+}
+
+// productToken выделяет имя агента из строки User-Agent: "Bot/1.0" -> "bot".
+func productToken(agent string) string {
+	if i := strings.IndexAny(agent, "/ "); i >= 0 {
+		agent = agent[:i]
+	}
+	return strings.ToLower(agent)
+}
+
+// match проверяет путь по шаблону robots.txt: "*" - любая
+// последовательность символов, "$" в конце - конец пути.
+func match(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	if anchored {
+		pattern = pattern[:len(pattern)-1]
+	}
+
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	pos := len(parts[0])
+
+	for i := 1; i < len(parts); i++ {
+		part := parts[i]
+		if i == len(parts)-1 && anchored {
+			return len(path)-pos >= len(part) && strings.HasSuffix(path, part)
+		}
+		idx := strings.Index(path[pos:], part)
+		if idx < 0 {
+			return false
+		}
+		pos += idx + len(part)
+	}
+
+	return !anchored || pos == len(path)
+}
@@ -0,0 +1,169 @@
+package robots
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const agent = "CIS-Engine-Crawler/1.0"
+
+func TestParseAndAllowed(t *testing.T) {
+	robots := Parse(strings.NewReader(`
+# comment
+User-agent: *
+Disallow: /private/
+Allow: /private/public
+Disallow: /*.pdf$
+Crawl-delay: 2
+
+User-agent: OtherBot
+User-agent: cis-engine-crawler
+Disallow: /no-cis
+Allow: /no-cis/ok$
+Crawl-delay: 0.5
+
+Sitemap: https://example.com/sitemap.xml
+`))
+
+	t.Run("Группа по имени агента заменяет группу *", func(t *testing.T) {
+		require.False(t, robots.Allowed(agent, "/no-cis/page"))
+		require.True(t, robots.Allowed(agent, "/no-cis/ok"))
+		require.False(t, robots.Allowed(agent, "/no-cis/ok/more"))
+		require.True(t, robots.Allowed(agent, "/private/secret"))
+		require.Equal(t, 500*time.Millisecond, robots.CrawlDelay(agent))
+	})
+
+	t.Run("Группа * для остальных агентов", func(t *testing.T) {
+		require.False(t, robots.Allowed("SomeBot/2.0", "/private/secret"))
+		require.True(t, robots.Allowed("SomeBot/2.0", "/private/public/page"))
+		require.False(t, robots.Allowed("SomeBot/2.0", "/docs/file.pdf"))
+		require.True(t, robots.Allowed("SomeBot/2.0", "/docs/file.pdf?download=1"))
+		require.Equal(t, 2*time.Second, robots.CrawlDelay("SomeBot/2.0"))
+	})
+
+	t.Run("Sitemap", func(t *testing.T) {
+		require.Equal(t, []string{"https://example.com/sitemap.xml"}, robots.Sitemaps)
+	})
+}
+
+func TestMatch(t *testing.T) {
+	cases := []struct {
+		pattern, path string
+		want          bool
+	}{
+		{"/", "/anything", true},
+		{"/fish", "/fish.html", true},
+		{"/fish", "/Fish.html", false},
+		{"/fish*.php", "/fish/salmon.php?x=1", true},
+		{"/*.php$", "/index.php", true},
+		{"/*.php$", "/index.php?x", false},
+		{"/a*b*c", "/axxbyyc", true},
+		{"/a*b*c", "/axxcyyb", false},
+		{"/end$", "/end", true},
+		{"/end$", "/endless", false},
+	}
+	for _, c := range cases {
+		require.Equal(t, c.want, match(c.pattern, c.path), "%s ~ %s", c.pattern, c.path)
+	}
+}
+
+func TestCache(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		require.Equal(t, agent, r.UserAgent())
+		w.Write([]byte("User-agent: *\nDisallow: /admin\n"))
+	}))
+	defer server.Close()
+
+	cache := NewCache(server.Client(), agent, time.Hour)
+	ctx := context.Background()
+
+	admin, _ := url.Parse(server.URL + "/admin/users")
+	allowed, _, err := cache.Allowed(ctx, admin)
+	require.NoError(t, err)
+	require.False(t, allowed)
+
+	home, _ := url.Parse(server.URL + "/")
+	allowed, _, err = cache.Allowed(ctx, home)
+	require.NoError(t, err)
+	require.True(t, allowed)
+
+	require.Equal(t, int32(1), hits.Load(), "robots.txt должен загружаться один раз")
+
+	t.Run("Ошибки сервера не запрещают обход", func(t *testing.T) {
+		failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer failing.Close()
+
+		u, _ := url.Parse(failing.URL + "/page")
+		_, _, err := cache.Allowed(ctx, u)
+		require.ErrorIs(t, err, ErrUnavailable)
+		_, err = cache.Get(ctx, u)
+		require.ErrorIs(t, err, ErrUnavailable, "ошибка кэшируется")
+
+		failing.Close()
+		down, _ := url.Parse(failing.URL + "/other")
+		cache.mu.Lock()
+		delete(cache.entries, down.Scheme+"://"+down.Host)
+		cache.mu.Unlock()
+		_, err = cache.Get(ctx, down)
+		require.ErrorIs(t, err, ErrUnavailable, "сетевая ошибка")
+	})
+
+	t.Run("Прерванная загрузка не передается ожидающим", func(t *testing.T) {
+		var calls atomic.Int32
+		slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if calls.Add(1) == 1 {
+				<-r.Context().Done()
+				return
+			}
+			w.Write([]byte("User-agent: *\nDisallow: /admin\n"))
+		}))
+		defer slow.Close()
+		u, _ := url.Parse(slow.URL + "/page")
+
+		fetchCtx, cancel := context.WithCancel(ctx)
+		fetched := make(chan error, 1)
+		go func() {
+			_, err := cache.Get(fetchCtx, u)
+			fetched <- err
+		}()
+		require.Eventually(t, func() bool { return calls.Load() == 1 }, time.Second, time.Millisecond)
+
+		waited := make(chan error, 1)
+		go func() {
+			allowed, _, err := cache.Allowed(ctx, u)
+			if err == nil && !allowed {
+				err = errors.New("URL запрещен")
+			}
+			waited <- err
+		}()
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+
+		require.ErrorIs(t, <-fetched, context.Canceled)
+		require.NoError(t, <-waited, "ожидающий загружает robots.txt заново")
+		require.Equal(t, int32(2), calls.Load())
+	})
+
+	t.Run("Отсутствие robots.txt разрешает обход", func(t *testing.T) {
+		missing := httptest.NewServer(http.NotFoundHandler())
+		defer missing.Close()
+
+		u, _ := url.Parse(missing.URL + "/page")
+		allowed, _, err := cache.Allowed(ctx, u)
+		require.NoError(t, err)
+		require.True(t, allowed)
+	})
+}
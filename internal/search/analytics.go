@@ -0,0 +1,166 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"cis-engine/internal/storage"
+	"cis-engine/internal/suggest"
+)
+
+// Параметры записи журнала запросов.
+const (
+	queryLogBuffer        = 1024
+	queryLogBatch         = 100
+	queryLogFlushInterval = time.Second
+	queryLogPruneInterval = time.Hour
+	queryLogFlushTimeout  = 5 * time.Second
+)
+
+// DefaultQueryLogRetention - срок хранения журнала запросов по умолчанию.
+const DefaultQueryLogRetention = 30 * 24 * time.Hour
+
+// Параметры отчетов аналитики.
+const (
+	DefaultAnalyticsWindow = 24 * time.Hour
+	DefaultAnalyticsLimit  = 20
+	MaxAnalyticsLimit      = 1000
+)
+
+// LatencyBounds - границы корзин гистограммы времени ответа.
+var LatencyBounds = []time.Duration{
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+}
+
+// logQuery ставит запрос в очередь на запись в журнал. Если очередь
+// заполнена, запись отбрасывается, чтобы не задерживать поиск.
+func (s *Service) logQuery(req *Request, total int64, latency time.Duration) {
+	e := &storage.QueryLogEntry{
+		Query:      req.Query,
+		Normalized: suggest.Normalize(req.Query),
+		Results:    total,
+		Latency:    latency,
+		ClientID:   req.ClientID,
+		CreatedAt:  time.Now(),
+	}
+	select {
+	case s.queries <- e:
+	default:
+		log.Printf("Очередь журнала запросов заполнена, запрос '%s' не записан", req.Query)
+	}
+}
+
+// RunQueryLog записывает журнал запросов в хранилище пачками и раз в час
+// удаляет записи старше retention; при retention 0 записи не удаляются.
+// Возвращается после отмены ctx, записав оставшиеся в очереди запросы.
+func (s *Service) RunQueryLog(ctx context.Context, retention time.Duration) {
+	flush := time.NewTicker(queryLogFlushInterval)
+	defer flush.Stop()
+	prune := time.NewTicker(queryLogPruneInterval)
+	defer prune.Stop()
+
+	var batch []*storage.QueryLogEntry
+	write := func(ctx context.Context) {
+		if len(batch) == 0 {
+			return
+		}
+		if err := s.storage.LogQueries(ctx, batch); err != nil {
+			log.Printf("Ошибка записи журнала запросов: %v", err)
+		}
+		batch = nil
+	}
+
+	s.pruneQueryLog(ctx, retention)
+	for {
+		select {
+		case <-ctx.Done():
+			for len(s.queries) > 0 {
+				batch = append(batch, <-s.queries)
+			}
+			flushCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), queryLogFlushTimeout)
+			write(flushCtx)
+			cancel()
+			return
+		case e := <-s.queries:
+			if batch = append(batch, e); len(batch) >= queryLogBatch {
+				write(ctx)
+			}
+		case <-flush.C:
+			write(ctx)
+		case <-prune.C:
+			s.pruneQueryLog(ctx, retention)
+		}
+	}
+}
+
+func (s *Service) pruneQueryLog(ctx context.Context, retention time.Duration) {
+	if retention <= 0 {
+		return
+	}
+	n, err := s.storage.DeleteQueryLog(ctx, time.Now().Add(-retention))
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("Ошибка очистки журнала запросов: %v", err)
+		}
+		return
+	}
+	if n > 0 {
+		log.Printf("Из журнала запросов удалено %d записей старше %s", n, retention)
+	}
+}
+
+// AnalyticsRequest - параметры отчета аналитики запросов.
+type AnalyticsRequest struct {
+	// From и To задают период [From, To). Нулевой To - текущий момент,
+	// нулевой From - DefaultAnalyticsWindow до To.
+	From, To time.Time
+	// Limit - число запросов в отчете; 0 - DefaultAnalyticsLimit.
+	Limit int
+}
+
+func (r *AnalyticsRequest) normalize() error {
+	if r.To.IsZero() {
+		r.To = time.Now()
+	}
+	if r.From.IsZero() {
+		r.From = r.To.Add(-DefaultAnalyticsWindow)
+	}
+	if !r.From.Before(r.To) {
+		return fmt.Errorf("%w: начало периода должно быть раньше конца", ErrInvalidRequest)
+	}
+	if r.Limit == 0 {
+		r.Limit = DefaultAnalyticsLimit
+	}
+	if r.Limit < 0 || r.Limit > MaxAnalyticsLimit {
+		return fmt.Errorf("%w: limit должен быть от 1 до %d", ErrInvalidRequest, MaxAnalyticsLimit)
+	}
+	return nil
+}
+
+// TopQueries возвращает самые частые запросы за период; при zeroResults -
+// только запросы, по которым ничего не найдено.
+func (s *Service) TopQueries(ctx context.Context, req AnalyticsRequest, zeroResults bool) ([]*storage.QueryCount, error) {
+	if err := req.normalize(); err != nil {
+		return nil, err
+	}
+	return s.storage.TopQueries(ctx, req.From, req.To, req.Limit, zeroResults)
+}
+
+// QueryLatency возвращает распределение времени ответа за период по
+// корзинам LatencyBounds.
+func (s *Service) QueryLatency(ctx context.Context, req AnalyticsRequest) (*storage.LatencyStats, error) {
+	if err := req.normalize(); err != nil {
+		return nil, err
+	}
+	return s.storage.QueryLatency(ctx, req.From, req.To, LatencyBounds)
+}
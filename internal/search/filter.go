@@ -0,0 +1,118 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"cis-engine/internal/storage"
+)
+
+// Операторы фильтров, которые можно указать прямо в тексте запроса,
+// например "goroutines site:go.dev after:2024-01-01". Значение оператора
+// заменяет одноименный параметр запроса.
+const (
+	opSite   = "site"
+	opHost   = "host"
+	opPath   = "path"
+	opLang   = "lang"
+	opType   = "type"
+	opAfter  = "after"
+	opBefore = "before"
+)
+
+// extractOperators удаляет операторы фильтров из текста запроса и
+// переносит их значения в req. Слова в кавычках не разбираются.
+func extractOperators(req *Request) error {
+	var words []string
+	quoted := false
+	for _, word := range strings.Fields(req.Query) {
+		name, value, ok := strings.Cut(word, ":")
+		if !quoted && ok && value != "" {
+			err := req.applyOperator(strings.ToLower(name), value)
+			if err == nil {
+				continue
+			}
+			if !errors.Is(err, errUnknownOperator) {
+				return err
+			}
+		}
+		if strings.Count(word, `"`)%2 == 1 {
+			quoted = !quoted
+		}
+		words = append(words, word)
+	}
+	req.Query = strings.Join(words, " ")
+	return nil
+}
+
+var errUnknownOperator = errors.New("неизвестный оператор")
+
+func (req *Request) applyOperator(name, value string) error {
+	switch name {
+	case opSite, opHost:
+		req.Filter.Site = value
+	case opPath:
+		req.Filter.PathPrefix = value
+	case opLang:
+		req.Lang = value
+	case opType:
+		req.Filter.ContentType = value
+	case opAfter, opBefore:
+		t, err := ParseTime(value)
+		if err != nil {
+			return fmt.Errorf("%w: оператор %s: %v", ErrInvalidRequest, name, err)
+		}
+		if name == opAfter {
+			req.Filter.CrawledAfter = t
+		} else {
+			req.Filter.CrawledBefore = t
+		}
+	default:
+		return errUnknownOperator
+	}
+	return nil
+}
+
+// ParseTime разбирает границу времени фильтра: дату в формате YYYY-MM-DD
+// (полночь UTC) или время в формате RFC 3339.
+func ParseTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("ожидается дата YYYY-MM-DD или время RFC 3339, получено %q", value)
+	}
+	return t, nil
+}
+
+// normalizeFilter приводит значения фильтра к виду, в котором они
+// сравниваются с данными страниц, и проверяет их.
+func normalizeFilter(f *storage.SearchFilter) error {
+	if f.Site != "" {
+		site := strings.ToLower(f.Site)
+		if _, rest, ok := strings.Cut(site, "://"); ok {
+			site = rest
+		}
+		site = strings.TrimPrefix(strings.TrimSuffix(site, "/"), "*.")
+		if site == "" || strings.ContainsAny(site, "/:?# ") {
+			return fmt.Errorf("%w: некорректный сайт %q", ErrInvalidRequest, f.Site)
+		}
+		f.Site = site
+	}
+	if f.PathPrefix != "" && !strings.HasPrefix(f.PathPrefix, "/") {
+		return fmt.Errorf("%w: префикс пути должен начинаться с \"/\"", ErrInvalidRequest)
+	}
+	if f.ContentType != "" {
+		f.ContentType = strings.ToLower(strings.TrimSpace(f.ContentType))
+		if !strings.Contains(f.ContentType, "/") {
+			return fmt.Errorf("%w: некорректный тип содержимого %q", ErrInvalidRequest, f.ContentType)
+		}
+	}
+	if !f.CrawledAfter.IsZero() && !f.CrawledBefore.IsZero() && !f.CrawledAfter.Before(f.CrawledBefore) {
+		return fmt.Errorf("%w: crawled_after должно быть раньше crawled_before", ErrInvalidRequest)
+	}
+	return nil
+}
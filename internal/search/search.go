@@ -1,62 +1,282 @@
 package search
 
 import (
+	"cis-engine/internal/lang"
+	"cis-engine/internal/spell"
 	"cis-engine/internal/storage"
+	"cis-engine/internal/suggest"
+	"cis-engine/internal/urlnorm"
+	"cmp"
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
+	"net/url"
+	"time"
 )
 
+// manualCrawlPriority ставит URL, добавленные через API, впереди
+// обнаруженных краулером ссылок.
+const manualCrawlPriority = 100
+
 type Service struct {
-	storage storage.Storer
+	storage   storage.Storer
+	suggester *suggest.Suggester
+	corrector *spell.Corrector
+	// queries - очередь журнала запросов, которую разбирает RunQueryLog.
+	queries chan *storage.QueryLogEntry
 }
 
 func NewService(s storage.Storer) *Service {
-	return &Service{storage: s}
+	return &Service{
+		storage:   s,
+		suggester: suggest.New(),
+		corrector: spell.New(),
+		queries:   make(chan *storage.QueryLogEntry, queryLogBuffer),
+	}
 }
 
+// Ограничения размера страницы выдачи.
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
 type Result struct {
 	URL   string `json:"url"`
 	Title string `json:"title"`
+	// Snippet - фрагменты текста, в которых найденные слова обрамлены
+	// маркерами из Request.Snippet.
+	Snippet string  `json:"snippet,omitempty"`
+	Score   float64 `json:"score"`
+	Lang    string  `json:"lang,omitempty"`
+	// Duplicates - число почти дубликатов страницы, скрытых из выдачи.
+	Duplicates int `json:"duplicates,omitempty"`
+}
+
+// Request - параметры поискового запроса.
+type Request struct {
+	Query string
+	// Lang - язык запроса (например, "en" или "ru-RU"); пустой - запрос
+	// разбирается для всех языков.
+	Lang string
+	// Ranker - storage.RankerTSRank или storage.RankerBM25; пустой -
+	// storage.RankerTSRank.
+	Ranker string
+	// Filter ограничивает выдачу. Операторы фильтров в тексте запроса
+	// (site:, host:, path:, lang:, type:, after:, before:) дополняют и
+	// переопределяют его.
+	Filter storage.SearchFilter
+	// Snippet задает построение сниппетов; nil - параметры по умолчанию.
+	Snippet *storage.SnippetOptions
+	// Limit - размер страницы выдачи; 0 - DefaultLimit.
+	Limit  int
+	Offset int
+	// Cursor - значение Response.NextCursor предыдущей страницы. Нельзя
+	// указывать вместе с Offset.
+	Cursor string
+	// ClientID - идентификатор клиента для журнала запросов.
+	ClientID string
+	// ShowDuplicates отключает схлопывание почти дубликатов в выдаче.
+	ShowDuplicates bool
+}
+
+// Response - страница выдачи.
+type Response struct {
+	Results []Result
+	// Total - общее число найденных страниц.
+	Total int64
+	// NextCursor - курсор следующей страницы; пустой, если она последняя.
+	NextCursor string
+	// Suggestion - исправленный запрос, по которому найдено больше страниц;
+	// предлагается, только если результатов меньше sparseResults.
+	Suggestion string
 }
 
-func (s *Service) Search(ctx context.Context, query string) ([]Result, error) {
-	if query == "" {
-		return []Result{}, nil
+// Search выполняет поиск. Первые страницы выдачи записываются в журнал
+// запросов.
+func (s *Service) Search(ctx context.Context, req Request) (*Response, error) {
+	start := time.Now()
+	if err := extractOperators(&req); err != nil {
+		return nil, err
+	}
+	if req.Query == "" {
+		return &Response{Results: []Result{}}, nil
+	}
+	log.Printf("Поисковый запрос: '%s'", req.Query)
+	if err := normalizeFilter(&req.Filter); err != nil {
+		return nil, err
 	}
-	log.Printf("Поисковый запрос: '%s'", query)
 
-	pages, err := s.storage.SearchPages(ctx, query)
+	query := &storage.SearchQuery{
+		Text:           req.Query,
+		Ranker:         req.Ranker,
+		Filter:         req.Filter,
+		Snippet:        storage.DefaultSnippetOptions(),
+		Limit:          req.Limit,
+		Offset:         req.Offset,
+		ShowDuplicates: req.ShowDuplicates,
+	}
+	if req.Lang != "" {
+		if query.Lang = lang.Normalize(req.Lang); query.Lang == "" {
+			return nil, fmt.Errorf("%w: некорректный код языка %q", ErrInvalidRequest, req.Lang)
+		}
+	}
+	switch query.Ranker {
+	case "", storage.RankerTSRank, storage.RankerBM25:
+	default:
+		return nil, fmt.Errorf("%w: ranker должен быть %s или %s", ErrInvalidRequest, storage.RankerTSRank, storage.RankerBM25)
+	}
+	if query.Limit == 0 {
+		query.Limit = DefaultLimit
+	}
+	if query.Limit < 0 || query.Limit > MaxLimit || query.Offset < 0 {
+		return nil, fmt.Errorf("%w: limit должен быть от 1 до %d, offset - неотрицательным", ErrInvalidRequest, MaxLimit)
+	}
+	if req.Snippet != nil {
+		if err := req.Snippet.Validate(); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+		}
+		query.Snippet = *req.Snippet
+	}
+	if req.Cursor != "" {
+		if req.Offset != 0 {
+			return nil, fmt.Errorf("%w: cursor и offset нельзя указывать вместе", ErrInvalidRequest)
+		}
+		after, err := decodeCursor(req.Cursor)
+		if err != nil {
+			return nil, fmt.Errorf("%w: некорректный cursor", ErrInvalidRequest)
+		}
+		query.After = after
+	}
+
+	found, err := s.storage.SearchPages(ctx, query)
 	if err != nil {
 		return nil, err
 	}
 
-	if len(pages) == 0 {
-		log.Printf("Результаты для запроса '%s' не найдены", query)
-		return []Result{}, nil
+	resp := &Response{Results: make([]Result, 0, len(found.Pages)), Total: found.Total}
+	if found.Total < sparseResults && query.Offset == 0 && query.After == nil {
+		resp.Suggestion = s.correct(ctx, query, found.Total)
+	}
+	if query.Offset == 0 && query.After == nil {
+		s.logQuery(&req, found.Total, time.Since(start))
+	}
+	if len(found.Pages) == 0 {
+		log.Printf("Результаты для запроса '%s' не найдены", req.Query)
+		return resp, nil
+	}
+
+	if query.Offset == 0 && query.After == nil {
+		s.suggester.Record(req.Query)
 	}
 
-	results := make([]Result, 0, len(pages))
-	for _, page := range pages {
-		results = append(results, Result{
-			URL:   page.URL,
-			Title: page.Title,
+	for _, page := range found.Pages {
+		resp.Results = append(resp.Results, Result{
+			URL:        page.URL,
+			Title:      page.Title,
+			Snippet:    page.Snippet,
+			Score:      page.Score,
+			Lang:       page.Lang,
+			Duplicates: page.Duplicates,
 		})
 	}
 
-	return results, nil
+	more := len(found.Pages) == query.Limit
+	if query.After == nil {
+		more = more && int64(query.Offset+len(found.Pages)) < found.Total
+	}
+	if more {
+		last := found.Pages[len(found.Pages)-1]
+		resp.NextCursor = encodeCursor(&storage.SearchCursor{Score: last.Score, ID: last.ID})
+	}
+
+	return resp, nil
 }
 
-func (s *Service) ScheduleCrawl(ctx context.Context, url string) error {
-	log.Printf("Получен запрос на сканирование URL: %s", url)
+// encodeCursor и decodeCursor преобразуют позицию в выдаче в непрозрачную
+// для клиента строку.
+func encodeCursor(c *storage.SearchCursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
 
-	page := &storage.Page{
-		URL:   url,
-		Title: "Запланировано для сканирования",
-		Body:  "",
+func decodeCursor(raw string) (*storage.SearchCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, err
 	}
+	var c storage.SearchCursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// ErrInvalidRequest оборачивает ошибки некорректных параметров запроса.
+var ErrInvalidRequest = errors.New("некорректный запрос")
 
-	_, err := s.storage.StorePage(ctx, page)
-	return err
+// ScheduleCrawl ставит URL в очередь краулера. Если scope задан, ссылки,
+// найденные при обходе, ограничиваются этой областью.
+func (s *Service) ScheduleCrawl(ctx context.Context, rawURL string, scope *storage.CrawlScope) error {
+	log.Printf("Получен запрос на сканирование URL: %s", rawURL)
+	return s.schedule(ctx, rawURL, scope, false)
+}
+
+// ScheduleSitemap ставит в очередь краулера файл sitemap, страницы из
+// которого будут добавлены в очередь с областью обхода scope.
+func (s *Service) ScheduleSitemap(ctx context.Context, rawURL string, scope *storage.CrawlScope) error {
+	log.Printf("Получен запрос на обход sitemap: %s", rawURL)
+	return s.schedule(ctx, rawURL, scope, true)
+}
+
+func (s *Service) schedule(ctx context.Context, rawURL string, scope *storage.CrawlScope, sitemap bool) error {
+	normalized, err := urlnorm.Normalize(rawURL)
+	if err != nil {
+		return fmt.Errorf("%w: ожидается абсолютный http(s) URL", ErrInvalidRequest)
+	}
+	u, err := url.Parse(normalized)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+	if scope != nil {
+		// Без режима область ограничивается доменом, как у краулера по умолчанию.
+		scope.Mode = cmp.Or(scope.Mode, storage.ScopeDomain)
+		if err := scope.Validate(); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+		}
+		scope.Seed = u.Host
+	}
+
+	return s.storage.EnqueueURLs(ctx, []*storage.FrontierURL{{
+		URL:         normalized,
+		Priority:    manualCrawlPriority,
+		NextFetchAt: time.Now(),
+		Scope:       scope,
+		Force:       true,
+		Sitemap:     sitemap,
+	}})
+}
+
+// PageLinks - исходящие и входящие ссылки страницы.
+type PageLinks struct {
+	PageID   int64           `json:"page_id"`
+	Outbound []*storage.Link `json:"outbound"`
+	Inbound  []*storage.Link `json:"inbound"`
+}
+
+func (s *Service) GetPageLinks(ctx context.Context, pageID int64, limit int) (*PageLinks, error) {
+	outbound, err := s.storage.GetOutboundLinks(ctx, pageID, limit)
+	if err != nil {
+		return nil, err
+	}
+	inbound, err := s.storage.GetInboundLinks(ctx, pageID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return &PageLinks{PageID: pageID, Outbound: outbound, Inbound: inbound}, nil
 }
 
 func (s *Service) GetStats(ctx context.Context) (*storage.Metrics, error) {
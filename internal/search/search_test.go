@@ -3,7 +3,9 @@ package search
 import (
 	"context"
 	"errors"
+	"sync"
 	"testing"
+	"time"
 
 	"cis-engine/internal/storage"
 
@@ -11,11 +13,19 @@ import (
 )
 
 type mockStorer struct {
-	searchPagesFunc func(ctx context.Context, query string) ([]*storage.Page, error)
+	searchPagesFunc func(ctx context.Context, query *storage.SearchQuery) (*storage.SearchResult, error)
 	getMetricsFunc  func(ctx context.Context) (*storage.Metrics, error)
+	enqueueURLsFunc func(ctx context.Context, urls []*storage.FrontierURL) error
+
+	suggestionSources *storage.SuggestionSources
+
+	logMu     sync.Mutex
+	logged    []*storage.QueryLogEntry
+	deletedTo time.Time
+	topQuery  func(from, to time.Time, limit int, zeroResults bool) ([]*storage.QueryCount, error)
 }
 
-func (m *mockStorer) SearchPages(ctx context.Context, query string) ([]*storage.Page, error) {
+func (m *mockStorer) SearchPages(ctx context.Context, query *storage.SearchQuery) (*storage.SearchResult, error) {
 	if m.searchPagesFunc != nil {
 		return m.searchPagesFunc(ctx, query)
 	}
@@ -29,56 +39,232 @@ func (m *mockStorer) GetMetrics(ctx context.Context) (*storage.Metrics, error) {
 	return nil, errors.New("getMetricsFunc не был определен")
 }
 
+func (m *mockStorer) EnqueueURLs(ctx context.Context, urls []*storage.FrontierURL) error {
+	if m.enqueueURLsFunc != nil {
+		return m.enqueueURLsFunc(ctx, urls)
+	}
+	return errors.New("enqueueURLsFunc не был определен")
+}
+
 func (m *mockStorer) StorePage(ctx context.Context, page *storage.Page) (int64, error) { return 0, nil }
-func (m *mockStorer) GetNextPageToIndex(ctx context.Context) (*storage.Page, error)    { return nil, nil }
-func (m *mockStorer) UpdatePageVector(ctx context.Context, page *storage.Page) error   { return nil }
-func (m *mockStorer) Close()                                                           {}
+func (m *mockStorer) IndexPages(ctx context.Context, limit int) (int, error)           { return 0, nil }
+func (m *mockStorer) WatchPages(ctx context.Context) (<-chan struct{}, error) {
+	return nil, errors.New("не поддерживается")
+}
+func (m *mockStorer) Close() {}
+func (m *mockStorer) LeaseURLs(ctx context.Context, owner string, limit, perHost int, lease time.Duration) ([]*storage.FrontierURL, error) {
+	return nil, nil
+}
+func (m *mockStorer) CompleteURL(ctx context.Context, id int64, nextFetchAt time.Time, fetch *storage.FetchState) error {
+	return nil
+}
+func (m *mockStorer) FailURL(ctx context.Context, id int64, retryAt time.Time) error { return nil }
+func (m *mockStorer) ReleaseURLs(ctx context.Context, ids []int64) error             { return nil }
+func (m *mockStorer) GetOutboundLinks(ctx context.Context, pageID int64, limit int) ([]*storage.Link, error) {
+	return nil, nil
+}
+func (m *mockStorer) GetInboundLinks(ctx context.Context, pageID int64, limit int) ([]*storage.Link, error) {
+	return nil, nil
+}
+func (m *mockStorer) GetPage(ctx context.Context, id int64) (*storage.Page, error) { return nil, nil }
+func (m *mockStorer) GetLinkGraph(ctx context.Context) (*storage.LinkGraph, error) { return nil, nil }
+func (m *mockStorer) UpdateAuthorityScores(ctx context.Context, scores map[int64]float64) error {
+	return nil
+}
+func (m *mockStorer) GetFingerprints(ctx context.Context) ([]*storage.PageFingerprint, error) {
+	return nil, nil
+}
+func (m *mockStorer) UpdateDuplicates(ctx context.Context, duplicates map[int64]int64) error {
+	return nil
+}
+func (m *mockStorer) UpdateTermStats(ctx context.Context) error { return nil }
+func (m *mockStorer) GetSuggestionSources(ctx context.Context, limit int) (*storage.SuggestionSources, error) {
+	if m.suggestionSources != nil {
+		return m.suggestionSources, nil
+	}
+	return &storage.SuggestionSources{}, nil
+}
+func (m *mockStorer) LogQueries(ctx context.Context, entries []*storage.QueryLogEntry) error {
+	m.logMu.Lock()
+	defer m.logMu.Unlock()
+	m.logged = append(m.logged, entries...)
+	return nil
+}
+func (m *mockStorer) DeleteQueryLog(ctx context.Context, before time.Time) (int64, error) {
+	m.logMu.Lock()
+	defer m.logMu.Unlock()
+	m.deletedTo = before
+	return 0, nil
+}
+func (m *mockStorer) TopQueries(ctx context.Context, from, to time.Time, limit int, zeroResults bool) ([]*storage.QueryCount, error) {
+	if m.topQuery != nil {
+		return m.topQuery(from, to, limit, zeroResults)
+	}
+	return nil, nil
+}
+func (m *mockStorer) QueryLatency(ctx context.Context, from, to time.Time, bounds []time.Duration) (*storage.LatencyStats, error) {
+	return &storage.LatencyStats{}, nil
+}
 
 func TestSearchService(t *testing.T) {
 	ctx := context.Background()
 
 	t.Run("Успешный поиск", func(t *testing.T) {
 		mockStorage := &mockStorer{
-			searchPagesFunc: func(ctx context.Context, query string) ([]*storage.Page, error) {
-				require.Equal(t, "go", query)
-				return []*storage.Page{
-					{URL: "https://golang.org", Title: "The Go Language"},
-					{URL: "https://go.dev", Title: "Official Go Website"},
-				}, nil
+			searchPagesFunc: func(ctx context.Context, query *storage.SearchQuery) (*storage.SearchResult, error) {
+				require.Equal(t, "go", query.Text)
+				require.Equal(t, storage.DefaultSnippetOptions(), query.Snippet)
+				require.Equal(t, DefaultLimit, query.Limit)
+				return &storage.SearchResult{Total: 2, Pages: []*storage.Page{
+					{URL: "https://golang.org", Title: "The Go Language", Snippet: "The <mark>Go</mark> programming language", Score: 0.5},
+					{URL: "https://go.dev", Title: "Official Go Website", Score: 0.25},
+				}}, nil
 			},
 		}
 		service := NewService(mockStorage)
 
-		results, err := service.Search(ctx, "go")
+		resp, err := service.Search(ctx, Request{Query: "go"})
 
 		require.NoError(t, err)
-		require.Len(t, results, 2)
-		require.Equal(t, "https://golang.org", results[0].URL)
+		require.Len(t, resp.Results, 2)
+		require.Equal(t, int64(2), resp.Total)
+		require.Equal(t, "https://golang.org", resp.Results[0].URL)
+		require.Equal(t, "The <mark>Go</mark> programming language", resp.Results[0].Snippet)
+		require.Equal(t, 0.5, resp.Results[0].Score)
+		require.Empty(t, resp.NextCursor)
+	})
+
+	t.Run("Постраничная выдача и язык", func(t *testing.T) {
+		var lastQuery *storage.SearchQuery
+		mockStorage := &mockStorer{
+			searchPagesFunc: func(ctx context.Context, query *storage.SearchQuery) (*storage.SearchResult, error) {
+				lastQuery = query
+				return &storage.SearchResult{Total: 5, Pages: []*storage.Page{
+					{ID: 7, URL: "https://a.example", Score: 0.9},
+					{ID: 3, URL: "https://b.example", Score: 0.4},
+				}}, nil
+			},
+		}
+		service := NewService(mockStorage)
+
+		resp, err := service.Search(ctx, Request{Query: "go", Limit: 2, Offset: 2})
+		require.NoError(t, err)
+		require.Equal(t, 2, lastQuery.Offset)
+		require.NotEmpty(t, resp.NextCursor)
+
+		_, err = service.Search(ctx, Request{Query: "go", Limit: 2, Cursor: resp.NextCursor})
+		require.NoError(t, err)
+		require.Equal(t, &storage.SearchCursor{Score: 0.4, ID: 3}, lastQuery.After)
+
+		_, err = service.Search(ctx, Request{Query: "go", Offset: 2, Cursor: resp.NextCursor})
+		require.ErrorIs(t, err, ErrInvalidRequest)
+		_, err = service.Search(ctx, Request{Query: "go", Cursor: "not a cursor"})
+		require.ErrorIs(t, err, ErrInvalidRequest)
+		_, err = service.Search(ctx, Request{Query: "go", Limit: MaxLimit + 1})
+		require.ErrorIs(t, err, ErrInvalidRequest)
+
+		_, err = service.Search(ctx, Request{Query: "go", Lang: "en-US"})
+		require.NoError(t, err)
+		require.Equal(t, "en", lastQuery.Lang)
+		_, err = service.Search(ctx, Request{Query: "go", Lang: "english"})
+		require.ErrorIs(t, err, ErrInvalidRequest)
+
+		resp, err = service.Search(ctx, Request{Query: "go", Limit: 2, Offset: 3})
+		require.NoError(t, err)
+		require.Empty(t, resp.NextCursor, "последняя страница не должна возвращать курсор")
+	})
+
+	t.Run("Параметры сниппета", func(t *testing.T) {
+		snippet := storage.DefaultSnippetOptions()
+		snippet.MaxFragments = 1
+		snippet.StartSel, snippet.StopSel = "[", "]"
+		mockStorage := &mockStorer{
+			searchPagesFunc: func(ctx context.Context, query *storage.SearchQuery) (*storage.SearchResult, error) {
+				require.Equal(t, snippet, query.Snippet)
+				return &storage.SearchResult{}, nil
+			},
+		}
+		service := NewService(mockStorage)
+
+		_, err := service.Search(ctx, Request{Query: "go", Snippet: &snippet})
+		require.NoError(t, err)
+
+		snippet.MinWords = snippet.MaxWords
+		_, err = service.Search(ctx, Request{Query: "go", Snippet: &snippet})
+		require.ErrorIs(t, err, ErrInvalidRequest)
+	})
+
+	t.Run("Выбор ранжирования", func(t *testing.T) {
+		mockStorage := &mockStorer{
+			searchPagesFunc: func(ctx context.Context, query *storage.SearchQuery) (*storage.SearchResult, error) {
+				require.Equal(t, storage.RankerBM25, query.Ranker)
+				return &storage.SearchResult{}, nil
+			},
+		}
+		service := NewService(mockStorage)
+
+		_, err := service.Search(ctx, Request{Query: "go", Ranker: storage.RankerBM25})
+		require.NoError(t, err)
+		_, err = service.Search(ctx, Request{Query: "go", Ranker: "tfidf"})
+		require.ErrorIs(t, err, ErrInvalidRequest)
+	})
+
+	t.Run("Фильтры и операторы в запросе", func(t *testing.T) {
+		var lastQuery *storage.SearchQuery
+		mockStorage := &mockStorer{
+			searchPagesFunc: func(ctx context.Context, query *storage.SearchQuery) (*storage.SearchResult, error) {
+				lastQuery = query
+				return &storage.SearchResult{}, nil
+			},
+		}
+		service := NewService(mockStorage)
+
+		req := Request{
+			Query:  `goroutines site:Go.dev path:/blog/ "type:literal" lang:en after:2024-01-01`,
+			Filter: storage.SearchFilter{Site: "golang.org", ContentType: "Text/HTML"},
+		}
+		_, err := service.Search(ctx, req)
+		require.NoError(t, err)
+		require.Equal(t, `goroutines "type:literal"`, lastQuery.Text)
+		require.Equal(t, "en", lastQuery.Lang)
+		require.Equal(t, storage.SearchFilter{
+			Site:         "go.dev",
+			PathPrefix:   "/blog/",
+			CrawledAfter: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			ContentType:  "text/html",
+		}, lastQuery.Filter)
+
+		_, err = service.Search(ctx, Request{Query: "go before:yesterday"})
+		require.ErrorIs(t, err, ErrInvalidRequest)
+		_, err = service.Search(ctx, Request{Query: "go path:blog"})
+		require.ErrorIs(t, err, ErrInvalidRequest)
+		_, err = service.Search(ctx, Request{Query: "go after:2024-02-01 before:2024-01-01"})
+		require.ErrorIs(t, err, ErrInvalidRequest)
 	})
 
 	t.Run("Поиск не дал результатов", func(t *testing.T) {
 		mockStorage := &mockStorer{
-			searchPagesFunc: func(ctx context.Context, query string) ([]*storage.Page, error) {
-				return []*storage.Page{}, nil
+			searchPagesFunc: func(ctx context.Context, query *storage.SearchQuery) (*storage.SearchResult, error) {
+				return &storage.SearchResult{}, nil
 			},
 		}
 		service := NewService(mockStorage)
 
-		results, err := service.Search(ctx, "nonexistent")
+		resp, err := service.Search(ctx, Request{Query: "nonexistent"})
 
 		require.NoError(t, err)
-		require.Len(t, results, 0)
+		require.Len(t, resp.Results, 0)
 	})
 
 	t.Run("Ошибка от хранилища", func(t *testing.T) {
 		mockStorage := &mockStorer{
-			searchPagesFunc: func(ctx context.Context, query string) ([]*storage.Page, error) {
+			searchPagesFunc: func(ctx context.Context, query *storage.SearchQuery) (*storage.SearchResult, error) {
 				return nil, errors.New("DB connection failed")
 			},
 		}
 		service := NewService(mockStorage)
 
-		_, err := service.Search(ctx, "any query")
+		_, err := service.Search(ctx, Request{Query: "any query"})
 
 		require.Error(t, err)
 		require.Equal(t, "DB connection failed", err.Error())
@@ -115,3 +301,223 @@ func TestGetStats(t *testing.T) {
 		require.Equal(t, "failed to query metrics", err.Error())
 	})
 }
+
+func TestScheduleCrawl(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("URL добавляется в очередь с приоритетом", func(t *testing.T) {
+		var enqueued []*storage.FrontierURL
+		mockStorage := &mockStorer{
+			enqueueURLsFunc: func(ctx context.Context, urls []*storage.FrontierURL) error {
+				enqueued = urls
+				return nil
+			},
+		}
+		service := NewService(mockStorage)
+
+		err := service.ScheduleCrawl(ctx, "https://Go.dev?utm_source=x", nil)
+
+		require.NoError(t, err)
+		require.Len(t, enqueued, 1)
+		require.Equal(t, "https://go.dev/", enqueued[0].URL)
+		require.Equal(t, manualCrawlPriority, enqueued[0].Priority)
+		require.True(t, enqueued[0].Force)
+		require.False(t, enqueued[0].Sitemap)
+
+		require.NoError(t, service.ScheduleSitemap(ctx, "https://go.dev/sitemap.xml", nil))
+		require.Equal(t, "https://go.dev/sitemap.xml", enqueued[0].URL)
+		require.True(t, enqueued[0].Sitemap)
+	})
+
+	t.Run("Ошибка от хранилища при добавлении в очередь", func(t *testing.T) {
+		mockStorage := &mockStorer{
+			enqueueURLsFunc: func(ctx context.Context, urls []*storage.FrontierURL) error {
+				return errors.New("queue is unavailable")
+			},
+		}
+		service := NewService(mockStorage)
+
+		err := service.ScheduleCrawl(ctx, "https://go.dev", nil)
+
+		require.Error(t, err)
+	})
+
+	t.Run("Область обхода получает начальный хост", func(t *testing.T) {
+		var enqueued []*storage.FrontierURL
+		mockStorage := &mockStorer{
+			enqueueURLsFunc: func(ctx context.Context, urls []*storage.FrontierURL) error {
+				enqueued = urls
+				return nil
+			},
+		}
+		service := NewService(mockStorage)
+
+		err := service.ScheduleCrawl(ctx, "https://go.dev/doc/", &storage.CrawlScope{Mode: storage.ScopeHost, MaxDepth: 3})
+
+		require.NoError(t, err)
+		require.NotNil(t, enqueued[0].Scope)
+		require.Equal(t, "go.dev", enqueued[0].Scope.Seed)
+
+		err = service.ScheduleCrawl(ctx, "https://go.dev/doc/", &storage.CrawlScope{MaxDepth: 3})
+
+		require.NoError(t, err)
+		require.Equal(t, storage.ScopeDomain, enqueued[0].Scope.Mode, "область без режима ограничивается доменом")
+	})
+
+	t.Run("Некорректные параметры запроса", func(t *testing.T) {
+		service := NewService(&mockStorer{})
+
+		err := service.ScheduleCrawl(ctx, "ftp://go.dev", nil)
+		require.ErrorIs(t, err, ErrInvalidRequest)
+
+		err = service.ScheduleCrawl(ctx, "https://go.dev", &storage.CrawlScope{Exclude: []string{"("}})
+		require.ErrorIs(t, err, ErrInvalidRequest)
+	})
+}
+
+func TestSuggest(t *testing.T) {
+	ctx := context.Background()
+	mockStorage := &mockStorer{
+		searchPagesFunc: func(ctx context.Context, query *storage.SearchQuery) (*storage.SearchResult, error) {
+			if query.Text == "nothing" {
+				return &storage.SearchResult{}, nil
+			}
+			return &storage.SearchResult{Total: 1, Pages: []*storage.Page{{ID: 1, URL: "https://go.dev"}}}, nil
+		},
+		suggestionSources: &storage.SuggestionSources{
+			Titles: []storage.WeightedText{{Text: "Go Language", Weight: 0.5}},
+			Terms:  []storage.WeightedText{{Text: "goroutine", Weight: 10}},
+		},
+	}
+	service := NewService(mockStorage)
+
+	_, err := service.Search(ctx, Request{Query: "gopher site:go.dev"})
+	require.NoError(t, err)
+	_, err = service.Search(ctx, Request{Query: "nothing"})
+	require.NoError(t, err)
+	require.NoError(t, service.rebuildSuggestions(ctx))
+
+	suggestions, err := service.Suggest(ctx, "go", 0)
+	require.NoError(t, err)
+	var texts []string
+	for _, sg := range suggestions {
+		texts = append(texts, sg.Text)
+	}
+	require.ElementsMatch(t, []string{"Go Language", "goroutine", "gopher"}, texts)
+
+	suggestions, err = service.Suggest(ctx, "no", 0)
+	require.NoError(t, err)
+	require.Empty(t, suggestions, "запросы без результатов не должны предлагаться")
+
+	_, err = service.Suggest(ctx, "", 0)
+	require.ErrorIs(t, err, ErrInvalidRequest)
+	_, err = service.Suggest(ctx, "go", MaxSuggestLimit+1)
+	require.ErrorIs(t, err, ErrInvalidRequest)
+}
+
+func TestSearchSuggestion(t *testing.T) {
+	ctx := context.Background()
+	mockStorage := &mockStorer{
+		searchPagesFunc: func(ctx context.Context, query *storage.SearchQuery) (*storage.SearchResult, error) {
+			switch query.Text {
+			case "goroutine", "привет":
+				return &storage.SearchResult{Total: 5}, nil
+			case "golang":
+				return &storage.SearchResult{Total: 1}, nil
+			}
+			return &storage.SearchResult{}, nil
+		},
+		suggestionSources: &storage.SuggestionSources{Terms: []storage.WeightedText{
+			{Text: "goroutine", Weight: 10},
+			{Text: "golang", Weight: 10},
+			{Text: "привет", Weight: 3},
+		}},
+	}
+	service := NewService(mockStorage)
+	require.NoError(t, service.rebuildSuggestions(ctx))
+
+	resp, err := service.Search(ctx, Request{Query: "gorutine"})
+	require.NoError(t, err)
+	require.Equal(t, "goroutine", resp.Suggestion)
+
+	resp, err = service.Search(ctx, Request{Query: "ghbdtn"})
+	require.NoError(t, err)
+	require.Equal(t, "привет", resp.Suggestion, "запрос в неверной раскладке")
+
+	resp, err = service.Search(ctx, Request{Query: "golnag"})
+	require.NoError(t, err)
+	require.Equal(t, "golang", resp.Suggestion)
+
+	resp, err = service.Search(ctx, Request{Query: "golang"})
+	require.NoError(t, err)
+	require.Empty(t, resp.Suggestion)
+
+	resp, err = service.Search(ctx, Request{Query: "gorutine", Offset: 10})
+	require.NoError(t, err)
+	require.Empty(t, resp.Suggestion, "исправление предлагается только на первой странице")
+}
+
+func TestQueryLog(t *testing.T) {
+	mockStorage := &mockStorer{
+		searchPagesFunc: func(ctx context.Context, query *storage.SearchQuery) (*storage.SearchResult, error) {
+			if query.Text != "nothing" {
+				return &storage.SearchResult{Total: 30, Pages: []*storage.Page{{ID: 1, URL: "https://go.dev"}}}, nil
+			}
+			return &storage.SearchResult{}, nil
+		},
+	}
+	service := NewService(mockStorage)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		service.RunQueryLog(ctx, time.Hour)
+		close(done)
+	}()
+
+	_, err := service.Search(context.Background(), Request{Query: "  Go ", ClientID: "10.0.0.1"})
+	require.NoError(t, err)
+	_, err = service.Search(context.Background(), Request{Query: "nothing"})
+	require.NoError(t, err)
+	_, err = service.Search(context.Background(), Request{Query: "go", Offset: 1})
+	require.NoError(t, err)
+
+	cancel()
+	<-done
+
+	require.Len(t, mockStorage.logged, 2, "следующие страницы выдачи не записываются")
+	first := mockStorage.logged[0]
+	require.Equal(t, "Go", first.Query)
+	require.Equal(t, "go", first.Normalized)
+	require.Equal(t, int64(30), first.Results)
+	require.Equal(t, "10.0.0.1", first.ClientID)
+	require.Positive(t, first.Latency)
+	require.Equal(t, int64(0), mockStorage.logged[1].Results)
+	require.WithinDuration(t, time.Now().Add(-time.Hour), mockStorage.deletedTo, time.Minute)
+}
+
+func TestTopQueries(t *testing.T) {
+	ctx := context.Background()
+	var gotFrom, gotTo time.Time
+	var gotLimit int
+	mockStorage := &mockStorer{
+		topQuery: func(from, to time.Time, limit int, zeroResults bool) ([]*storage.QueryCount, error) {
+			gotFrom, gotTo, gotLimit = from, to, limit
+			require.True(t, zeroResults)
+			return []*storage.QueryCount{{Query: "go", Count: 3}}, nil
+		},
+	}
+	service := NewService(mockStorage)
+
+	top, err := service.TopQueries(ctx, AnalyticsRequest{}, true)
+	require.NoError(t, err)
+	require.Len(t, top, 1)
+	require.Equal(t, DefaultAnalyticsLimit, gotLimit)
+	require.WithinDuration(t, time.Now(), gotTo, time.Minute)
+	require.Equal(t, DefaultAnalyticsWindow, gotTo.Sub(gotFrom))
+
+	now := time.Now()
+	_, err = service.TopQueries(ctx, AnalyticsRequest{From: now, To: now.Add(-time.Hour)}, true)
+	require.ErrorIs(t, err, ErrInvalidRequest)
+	_, err = service.TopQueries(ctx, AnalyticsRequest{Limit: MaxAnalyticsLimit + 1}, true)
+	require.ErrorIs(t, err, ErrInvalidRequest)
+}
@@ -0,0 +1,91 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"cis-engine/internal/storage"
+	"cis-engine/internal/suggest"
+)
+
+// Ограничения числа подсказок автодополнения.
+const (
+	DefaultSuggestLimit = 10
+	MaxSuggestLimit     = suggest.MaxLimit
+)
+
+// SuggestRefreshInterval - как часто словарь подсказок перестраивается из
+// хранилища.
+const SuggestRefreshInterval = 5 * time.Minute
+
+// suggestSourceLimit ограничивает число заголовков и слов в словаре.
+const suggestSourceLimit = 50_000
+
+// Suggest возвращает варианты продолжения запроса prefix; limit 0 -
+// DefaultSuggestLimit.
+func (s *Service) Suggest(ctx context.Context, prefix string, limit int) ([]suggest.Suggestion, error) {
+	if prefix == "" {
+		return nil, fmt.Errorf("%w: prefix не может быть пустым", ErrInvalidRequest)
+	}
+	if limit == 0 {
+		limit = DefaultSuggestLimit
+	}
+	if limit < 0 || limit > MaxSuggestLimit {
+		return nil, fmt.Errorf("%w: limit должен быть от 1 до %d", ErrInvalidRequest, MaxSuggestLimit)
+	}
+	return s.suggester.Complete(prefix, limit), nil
+}
+
+// RefreshSuggestions перестраивает словари подсказок и исправления опечаток
+// сразу и затем каждые interval, пока не отменен ctx.
+func (s *Service) RefreshSuggestions(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := s.rebuildSuggestions(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Ошибка обновления подсказок: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Service) rebuildSuggestions(ctx context.Context) error {
+	src, err := s.storage.GetSuggestionSources(ctx, suggestSourceLimit)
+	if err != nil {
+		return err
+	}
+	s.suggester.Rebuild(src)
+	s.corrector.Rebuild(src)
+	return nil
+}
+
+// sparseResults - при меньшем числе найденных страниц ищется исправление
+// запроса.
+const sparseResults = 3
+
+// correct возвращает исправленный текст запроса, если по нему найдено
+// больше страниц, чем total; иначе пустую строку.
+func (s *Service) correct(ctx context.Context, query *storage.SearchQuery, total int64) string {
+	fixed, ok := s.corrector.CorrectQuery(query.Text)
+	if !ok {
+		return ""
+	}
+	q := *query
+	q.Text, q.Limit = fixed, 1
+	found, err := s.storage.SearchPages(ctx, &q)
+	if err != nil {
+		log.Printf("Ошибка проверки исправления запроса '%s': %v", fixed, err)
+		return ""
+	}
+	if found.Total <= total {
+		return ""
+	}
+	log.Printf("Запрос '%s' исправлен на '%s'", query.Text, fixed)
+	return fixed
+}
@@ -0,0 +1,178 @@
+// Package sitemap разбирает файлы Sitemap (https://www.sitemaps.org/protocol.html):
+// списки страниц (urlset) и индексы (sitemapindex), в том числе сжатые gzip.
+package sitemap
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html/charset"
+)
+
+// Ограничения протокола: не больше MaxURLs записей и MaxSize байт в
+// распакованном файле. Записи и данные сверх них отбрасываются.
+const (
+	MaxURLs = 50_000
+	MaxSize = 50 << 20
+)
+
+// DefaultPriority - приоритет страницы, для которой он не указан.
+const DefaultPriority = 0.5
+
+// URL - запись sitemap: страница из urlset или вложенный sitemap из
+// sitemapindex.
+type URL struct {
+	Loc string
+	// LastMod - время последнего изменения; нулевое, если не указано.
+	LastMod time.Time
+	// ChangeFreq - ожидаемая частота изменений: always, hourly, daily,
+	// weekly, monthly, yearly или never; пустая, если не указана.
+	ChangeFreq string
+	// Priority - от 0 до 1.
+	Priority float64
+}
+
+// Sitemap - разобранный файл. Заполнен один из списков в зависимости от
+// корневого элемента.
+type Sitemap struct {
+	URLs     []URL
+	Sitemaps []URL
+}
+
+var ErrNotSitemap = errors.New("документ не является sitemap")
+
+// Parse разбирает sitemap, распаковывая его, если он сжат gzip. Записи
+// без адреса пропускаются.
+func Parse(r io.Reader) (*Sitemap, error) {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r = zr
+	} else {
+		r = br
+	}
+
+	dec := xml.NewDecoder(io.LimitReader(r, MaxSize))
+	dec.CharsetReader = charset.NewReaderLabel
+	dec.Strict = false
+
+	var root string
+	sm := &Sitemap{}
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			if root == "" {
+				return nil, ErrNotSitemap
+			}
+			// Обрезанный по MaxSize или поврежденный файл: записи до ошибки
+			// остаются в результате.
+			break
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if root == "" {
+			root = start.Name.Local
+			if root != "urlset" && root != "sitemapindex" {
+				return nil, ErrNotSitemap
+			}
+			continue
+		}
+		if (root == "urlset" && start.Name.Local != "url") || (root == "sitemapindex" && start.Name.Local != "sitemap") {
+			if err := dec.Skip(); err != nil {
+				break
+			}
+			continue
+		}
+
+		var entry struct {
+			Loc        string `xml:"loc"`
+			LastMod    string `xml:"lastmod"`
+			ChangeFreq string `xml:"changefreq"`
+			Priority   string `xml:"priority"`
+		}
+		if err := dec.DecodeElement(&entry, &start); err != nil {
+			break
+		}
+		u := URL{
+			Loc:        strings.TrimSpace(entry.Loc),
+			LastMod:    parseLastMod(entry.LastMod),
+			ChangeFreq: strings.ToLower(strings.TrimSpace(entry.ChangeFreq)),
+			Priority:   parsePriority(entry.Priority),
+		}
+		if u.Loc == "" {
+			continue
+		}
+		if root == "urlset" {
+			sm.URLs = append(sm.URLs, u)
+		} else {
+			sm.Sitemaps = append(sm.Sitemaps, u)
+		}
+		if len(sm.URLs)+len(sm.Sitemaps) >= MaxURLs {
+			break
+		}
+	}
+	if root == "" {
+		return nil, ErrNotSitemap
+	}
+	return sm, nil
+}
+
+// lastModLayouts - варианты формата W3C Datetime, допустимые в lastmod.
+var lastModLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04Z07:00",
+	time.DateOnly,
+	"2006-01",
+	"2006",
+}
+
+func parseLastMod(s string) time.Time {
+	s = strings.TrimSpace(s)
+	for _, layout := range lastModLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func parsePriority(s string) float64 {
+	p, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || p < 0 || p > 1 {
+		return DefaultPriority
+	}
+	return p
+}
+
+// ChangeInterval переводит changefreq в интервал между загрузками; 0 для
+// пустого или неизвестного значения.
+func ChangeInterval(changeFreq string) time.Duration {
+	switch changeFreq {
+	case "always", "hourly":
+		return time.Hour
+	case "daily":
+		return 24 * time.Hour
+	case "weekly":
+		return 7 * 24 * time.Hour
+	case "monthly":
+		return 30 * 24 * time.Hour
+	case "yearly", "never":
+		return 365 * 24 * time.Hour
+	}
+	return 0
+}
@@ -0,0 +1,75 @@
+package sitemap
+
+import (
+	"bytes"
+	"compress/gzip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseURLSet(t *testing.T) {
+	sm, err := Parse(strings.NewReader(`<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+	<url>
+		<loc> https://example.com/ </loc>
+		<lastmod>2024-05-01T10:30:00+03:00</lastmod>
+		<changefreq>Daily</changefreq>
+		<priority>0.8</priority>
+	</url>
+	<url>
+		<loc>https://example.com/about</loc>
+		<lastmod>2024-04</lastmod>
+		<priority>2</priority>
+	</url>
+	<url><lastmod>2024-01-01</lastmod></url>
+</urlset>`))
+	require.NoError(t, err)
+	require.Empty(t, sm.Sitemaps)
+	require.Len(t, sm.URLs, 2, "запись без loc пропускается")
+
+	home := sm.URLs[0]
+	require.Equal(t, "https://example.com/", home.Loc)
+	require.True(t, home.LastMod.Equal(time.Date(2024, 5, 1, 7, 30, 0, 0, time.UTC)))
+	require.Equal(t, "daily", home.ChangeFreq)
+	require.Equal(t, 0.8, home.Priority)
+	require.Equal(t, 24*time.Hour, ChangeInterval(home.ChangeFreq))
+
+	about := sm.URLs[1]
+	require.Equal(t, time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC), about.LastMod)
+	require.Equal(t, DefaultPriority, about.Priority, "некорректный приоритет заменяется значением по умолчанию")
+	require.Zero(t, ChangeInterval(about.ChangeFreq))
+}
+
+func TestParseIndex(t *testing.T) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, err := zw.Write([]byte(`<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+	<sitemap><loc>https://example.com/posts.xml.gz</loc><lastmod>2024-05-01</lastmod></sitemap>
+	<sitemap><loc>https://example.com/pages.xml</loc></sitemap>
+</sitemapindex>`))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	sm, err := Parse(&buf)
+	require.NoError(t, err)
+	require.Empty(t, sm.URLs)
+	require.Len(t, sm.Sitemaps, 2)
+	require.Equal(t, "https://example.com/posts.xml.gz", sm.Sitemaps[0].Loc)
+	require.Equal(t, 2024, sm.Sitemaps[0].LastMod.Year())
+	require.True(t, sm.Sitemaps[1].LastMod.IsZero())
+}
+
+func TestParseNotSitemap(t *testing.T) {
+	for _, doc := range []string{
+		`<!DOCTYPE html><html><body>Not found</body></html>`,
+		`<rss version="2.0"><channel></channel></rss>`,
+		`plain text`,
+		``,
+	} {
+		_, err := Parse(strings.NewReader(doc))
+		require.ErrorIs(t, err, ErrNotSitemap, doc)
+	}
+}
@@ -0,0 +1,36 @@
+package spell
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Клавиши английской раскладки QWERTY и соответствующие им буквы русской
+// раскладки ЙЦУКЕН.
+const (
+	qwertyKeys = "`qwertyuiop[]asdfghjkl;'zxcvbnm,."
+	jcukenKeys = "ёйцукенгшщзхъфывапролджэячсмитьбю"
+)
+
+var layoutSwap = func() map[rune]rune {
+	m := make(map[rune]rune)
+	q, j := []rune(qwertyKeys), []rune(jcukenKeys)
+	for i := range q {
+		m[q[i]], m[j[i]] = j[i], q[i]
+		if u := unicode.ToUpper(q[i]); u != q[i] {
+			m[u], m[unicode.ToUpper(j[i])] = unicode.ToUpper(j[i]), u
+		}
+	}
+	return m
+}()
+
+// SwitchLayout переводит текст, набранный в раскладке QWERTY, в ЙЦУКЕН и
+// наоборот: "ghbdtn" становится "привет", "пщдфтп" - "golang".
+func SwitchLayout(text string) string {
+	return strings.Map(func(r rune) rune {
+		if s, ok := layoutSwap[r]; ok {
+			return s
+		}
+		return r
+	}, text)
+}
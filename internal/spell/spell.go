@@ -0,0 +1,196 @@
+// Package spell исправляет опечатки в запросах по словарю слов индекса:
+// поиск кандидатов методом symmetric delete и исправление текста,
+// набранного в неверной раскладке клавиатуры.
+package spell
+
+import (
+	"cmp"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"cis-engine/internal/analysis"
+	"cis-engine/internal/storage"
+)
+
+// maxDistance - наибольшее расстояние Дамерау-Левенштейна до исправления.
+// Для коротких слов допускается одна ошибка, иначе почти любое слово
+// исправляется в какое-нибудь другое.
+const (
+	maxDistance    = 2
+	shortWordRunes = 5
+)
+
+const minQueryWordRunes = 3
+
+// maxWordRunes - слова длиннее не исправляются: число вариантов с
+// удаленными буквами растет как куб длины слова.
+const maxWordRunes = 30
+
+// Corrector хранит словарь: частоту каждого слова и слова, получающиеся
+// из словарных удалением до maxDistance букв.
+type Corrector struct {
+	mu      sync.RWMutex
+	words   map[string]float64
+	deletes map[string][]string
+}
+
+func New() *Corrector {
+	return &Corrector{words: map[string]float64{}, deletes: map[string][]string{}}
+}
+
+// Rebuild заменяет словарь словами src.Terms.
+func (c *Corrector) Rebuild(src *storage.SuggestionSources) {
+	words := make(map[string]float64, len(src.Terms))
+	deletes := make(map[string][]string)
+	for _, t := range src.Terms {
+		word := strings.ReplaceAll(strings.ToLower(t.Text), "ё", "е")
+		if _, ok := words[word]; ok {
+			continue
+		}
+		words[word] = t.Weight
+		if utf8.RuneCountInString(word) > maxWordRunes+maxDistance {
+			continue
+		}
+		for d := range variants(word, distanceLimit(word)) {
+			deletes[d] = append(deletes[d], word)
+		}
+	}
+
+	c.mu.Lock()
+	c.words, c.deletes = words, deletes
+	c.mu.Unlock()
+}
+
+func distanceLimit(word string) int {
+	if utf8.RuneCountInString(word) < shortWordRunes {
+		return 1
+	}
+	return maxDistance
+}
+
+// variants возвращает слово и все строки, получающиеся из него удалением
+// не более n букв.
+func variants(word string, n int) map[string]bool {
+	result := map[string]bool{word: true}
+	level := []string{word}
+	for range n {
+		var next []string
+		for _, w := range level {
+			runes := []rune(w)
+			for i := range runes {
+				d := string(runes[:i]) + string(runes[i+1:])
+				if !result[d] {
+					result[d] = true
+					next = append(next, d)
+				}
+			}
+		}
+		level = next
+	}
+	return result
+}
+
+// Known сообщает, есть ли слово в словаре.
+func (c *Corrector) Known(word string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	_, ok := c.words[word]
+	return ok
+}
+
+// Correct возвращает ближайшее к word словарное слово, при равном
+// расстоянии - более частое. ok = false, если слово есть в словаре или
+// исправить его нельзя.
+func (c *Corrector) Correct(word string) (string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if _, ok := c.words[word]; ok || utf8.RuneCountInString(word) > maxWordRunes {
+		return "", false
+	}
+	limit := distanceLimit(word)
+	best, bestDist, bestFreq := "", limit+1, 0.0
+	for v := range variants(word, limit) {
+		for _, candidate := range c.deletes[v] {
+			dist := distance(word, candidate)
+			if dist > limit || dist > distanceLimit(candidate) {
+				continue
+			}
+			freq := c.words[candidate]
+			if cmp.Or(cmp.Compare(dist, bestDist), cmp.Compare(bestFreq, freq), strings.Compare(candidate, best)) < 0 {
+				best, bestDist, bestFreq = candidate, dist, freq
+			}
+		}
+	}
+	return best, best != ""
+}
+
+// distance - расстояние Дамерау-Левенштейна (с ограничением на
+// перестановки соседних букв) между a и b.
+func distance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev2 := make([]int, len(rb)+1)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return prev[len(rb)]
+}
+
+// CorrectQuery предлагает исправленный запрос: сначала проверяет, не набран
+// ли он в неверной раскладке, затем исправляет неизвестные слова по
+// отдельности. Слова короче minQueryWordRunes, в том числе оператор "or",
+// и длиннее maxWordRunes не исправляются. ok = false, если исправлять нечего.
+func (c *Corrector) CorrectQuery(query string) (string, bool) {
+	query = strings.ToLower(query)
+	if switched := SwitchLayout(query); c.allKnown(switched) && !c.allKnown(query) {
+		return switched, true
+	}
+
+	var b strings.Builder
+	last, changed := 0, false
+	for _, t := range analysis.Tokenize(query) {
+		word := strings.ReplaceAll(t.Text, "ё", "е")
+		if n := utf8.RuneCountInString(word); n < minQueryWordRunes || n > maxWordRunes || !strings.ContainsFunc(word, unicode.IsLetter) {
+			continue
+		}
+		if fixed, ok := c.Correct(word); ok {
+			b.WriteString(query[last:t.Start])
+			b.WriteString(fixed)
+			last, changed = t.End, true
+		}
+	}
+	if !changed {
+		return "", false
+	}
+	b.WriteString(query[last:])
+	return b.String(), true
+}
+
+// allKnown сообщает, что в тексте есть слова и все они есть в словаре.
+func (c *Corrector) allKnown(text string) bool {
+	tokens := analysis.Tokenize(text)
+	for _, t := range tokens {
+		if !c.Known(strings.ReplaceAll(t.Text, "ё", "е")) {
+			return false
+		}
+	}
+	return len(tokens) > 0
+}
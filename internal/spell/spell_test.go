@@ -0,0 +1,86 @@
+package spell
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"cis-engine/internal/storage"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newCorrector() *Corrector {
+	c := New()
+	c.Rebuild(&storage.SuggestionSources{Terms: []storage.WeightedText{
+		{Text: "golang", Weight: 50},
+		{Text: "goroutine", Weight: 30},
+		{Text: "channel", Weight: 20},
+		{Text: "chanel", Weight: 1},
+		{Text: "привет", Weight: 10},
+		{Text: "мир", Weight: 8},
+		{Text: "поиск", Weight: 5},
+		{Text: "go", Weight: 100},
+	}})
+	return c
+}
+
+func TestCorrect(t *testing.T) {
+	c := newCorrector()
+
+	fixed, ok := c.Correct("gorutine")
+	require.True(t, ok)
+	require.Equal(t, "goroutine", fixed)
+
+	fixed, ok = c.Correct("chanenl")
+	require.True(t, ok)
+	require.Equal(t, "channel", fixed, "при равном расстоянии выбирается более частое слово")
+
+	fixed, ok = c.Correct("пойск")
+	require.True(t, ok)
+	require.Equal(t, "поиск", fixed)
+
+	_, ok = c.Correct("golang")
+	require.False(t, ok, "словарное слово не исправляется")
+	_, ok = c.Correct("xyzzy")
+	require.False(t, ok)
+	_, ok = c.Correct("gp")
+	require.True(t, ok)
+	_, ok = c.Correct("gxyz")
+	require.False(t, ok, "для коротких слов допускается одна ошибка")
+
+	start := time.Now()
+	_, ok = c.Correct(strings.Repeat("goroutine", 1000))
+	require.False(t, ok, "длинные слова не исправляются")
+	require.Less(t, time.Since(start), time.Second)
+}
+
+func TestCorrectQuery(t *testing.T) {
+	c := newCorrector()
+
+	fixed, ok := c.CorrectQuery("Golnag gorutine")
+	require.True(t, ok)
+	require.Equal(t, "golang goroutine", fixed)
+
+	fixed, ok = c.CorrectQuery("ghbdtn vbh")
+	require.True(t, ok)
+	require.Equal(t, "привет мир", fixed)
+
+	fixed, ok = c.CorrectQuery("пщдфтп")
+	require.True(t, ok)
+	require.Equal(t, "golang", fixed)
+
+	_, ok = c.CorrectQuery("golang channel")
+	require.False(t, ok)
+
+	fixed, ok = c.CorrectQuery("gorutine " + strings.Repeat("x", 1000))
+	require.True(t, ok)
+	require.Equal(t, "goroutine "+strings.Repeat("x", 1000), fixed)
+}
+
+func TestDistance(t *testing.T) {
+	require.Equal(t, 0, distance("поиск", "поиск"))
+	require.Equal(t, 1, distance("ab", "ba"))
+	require.Equal(t, 3, distance("kitten", "sitting"))
+	require.Equal(t, 1, distance("мир", "мира"))
+}
@@ -0,0 +1,68 @@
+// Package backend выбирает реализацию хранилища по настройкам окружения.
+package backend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"cis-engine/internal/storage"
+	"cis-engine/internal/storage/memory"
+	"cis-engine/internal/storage/postgres"
+)
+
+// Значения STORAGE_BACKEND.
+const (
+	Postgres = "postgres"
+	Memory   = "memory"
+)
+
+type Config struct {
+	// Backend - postgres (по умолчанию) или memory.
+	Backend string
+	// DatabaseURL - строка подключения к PostgreSQL.
+	DatabaseURL string
+	// SnapshotPath - файл снимка встроенного хранилища; пустой - состояние
+	// не сохраняется между запусками.
+	SnapshotPath string
+}
+
+// FromEnv читает настройки из STORAGE_BACKEND, DATABASE_URL и
+// STORAGE_SNAPSHOT.
+func FromEnv() Config {
+	cfg := Config{
+		Backend:      os.Getenv("STORAGE_BACKEND"),
+		DatabaseURL:  os.Getenv("DATABASE_URL"),
+		SnapshotPath: os.Getenv("STORAGE_SNAPSHOT"),
+	}
+	if cfg.Backend == "" {
+		cfg.Backend = Postgres
+	}
+	return cfg
+}
+
+func Open(ctx context.Context, cfg Config) (storage.Storer, error) {
+	switch cfg.Backend {
+	case Postgres:
+		if cfg.DatabaseURL == "" {
+			return nil, errors.New("переменная окружения DATABASE_URL не установлена")
+		}
+		db, err := postgres.New(ctx, cfg.DatabaseURL)
+		if err != nil {
+			return nil, err
+		}
+		return db, nil
+	case Memory:
+		if cfg.SnapshotPath == "" {
+			return memory.New(), nil
+		}
+		s, err := memory.Open(cfg.SnapshotPath)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	default:
+		return nil, fmt.Errorf("неизвестное хранилище %q: ожидается %s или %s", cfg.Backend, Postgres, Memory)
+	}
+}
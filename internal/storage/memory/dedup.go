@@ -0,0 +1,43 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"slices"
+
+	"cis-engine/internal/storage"
+)
+
+func (s *Store) GetFingerprints(ctx context.Context) ([]*storage.PageFingerprint, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var fingerprints []*storage.PageFingerprint
+	for id, rec := range s.pages {
+		if rec.Page.SimHash != 0 {
+			fingerprints = append(fingerprints, &storage.PageFingerprint{
+				ID:        id,
+				URL:       rec.Page.URL,
+				SimHash:   rec.Page.SimHash,
+				Authority: rec.Authority,
+			})
+		}
+	}
+	slices.SortFunc(fingerprints, func(a, b *storage.PageFingerprint) int { return cmp.Compare(a.ID, b.ID) })
+	return fingerprints, nil
+}
+
+func (s *Store) UpdateDuplicates(ctx context.Context, duplicates map[int64]int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, rec := range s.pages {
+		// Каноническая страница могла быть удалена после чтения отпечатков.
+		canonical := duplicates[id]
+		if _, ok := s.pages[canonical]; !ok {
+			canonical = 0
+		}
+		rec.Page.DuplicateOf = canonical
+	}
+	return nil
+}
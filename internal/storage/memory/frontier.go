@@ -0,0 +1,167 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"net/url"
+	"slices"
+	"time"
+
+	"cis-engine/internal/storage"
+)
+
+type frontierRecord struct {
+	storage.FrontierURL
+	Host           string
+	LeaseOwner     string
+	LeaseExpiresAt time.Time
+}
+
+func (s *Store) EnqueueURLs(ctx context.Context, urls []*storage.FrontierURL) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for _, u := range urls {
+		normalized, err := normalizer.Normalize(u.URL)
+		if err != nil {
+			return fmt.Errorf("некорректный URL %s: %w", u.URL, err)
+		}
+		nextFetchAt := u.NextFetchAt
+		if nextFetchAt.IsZero() {
+			nextFetchAt = now
+		}
+
+		if id, ok := s.queue[normalized]; ok {
+			rec := s.frontier[id]
+			rec.Priority = max(rec.Priority, u.Priority)
+			rec.Depth = min(rec.Depth, u.Depth)
+			if u.Force {
+				if u.Scope != nil {
+					rec.Scope = u.Scope
+				}
+				if nextFetchAt.Before(rec.NextFetchAt) {
+					rec.NextFetchAt = nextFetchAt
+				}
+			} else if !rec.LastModified.IsZero() && u.LastModified.After(rec.LastModified) && now.Before(rec.NextFetchAt) {
+				rec.NextFetchAt = now
+			}
+			rec.Sitemap = rec.Sitemap || u.Sitemap
+			if u.LastModified.After(rec.LastModified) {
+				rec.LastModified = u.LastModified
+			}
+			if rec.RevisitInterval == 0 {
+				rec.RevisitInterval = u.RevisitInterval
+			}
+			continue
+		}
+
+		s.nextFrontierID++
+		rec := &frontierRecord{FrontierURL: *u}
+		rec.ID = s.nextFrontierID
+		rec.URL = normalized
+		rec.NextFetchAt = nextFetchAt
+		rec.Attempts = 0
+		rec.Force = false
+		if parsed, err := url.Parse(normalized); err == nil {
+			rec.Host = parsed.Host
+		}
+		s.frontier[rec.ID] = rec
+		s.queue[normalized] = rec.ID
+	}
+	return nil
+}
+
+func (s *Store) LeaseURLs(ctx context.Context, owner string, limit, perHost int, lease time.Duration) ([]*storage.FrontierURL, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	byHost := make(map[string][]*frontierRecord)
+	for _, rec := range s.frontier {
+		if rec.NextFetchAt.After(now) || rec.LeaseExpiresAt.After(now) {
+			continue
+		}
+		byHost[rec.Host] = append(byHost[rec.Host], rec)
+	}
+
+	// Как и в postgres, записи нумеруются внутри каждого хоста, поэтому
+	// URL разных хостов выдаются по кругу.
+	type ranked struct {
+		rec  *frontierRecord
+		rank int
+	}
+	var candidates []ranked
+	for _, recs := range byHost {
+		slices.SortFunc(recs, compareDue)
+		for i, rec := range recs[:min(perHost, len(recs))] {
+			candidates = append(candidates, ranked{rec, i})
+		}
+	}
+	slices.SortFunc(candidates, func(a, b ranked) int {
+		return cmp.Or(cmp.Compare(a.rank, b.rank), compareDue(a.rec, b.rec))
+	})
+
+	urls := make([]*storage.FrontierURL, 0, min(limit, len(candidates)))
+	for _, c := range candidates[:min(limit, len(candidates))] {
+		c.rec.LeaseOwner = owner
+		c.rec.LeaseExpiresAt = now.Add(lease)
+		u := c.rec.FrontierURL
+		urls = append(urls, &u)
+	}
+	slices.SortStableFunc(urls, func(a, b *storage.FrontierURL) int { return cmp.Compare(b.Priority, a.Priority) })
+	return urls, nil
+}
+
+// compareDue упорядочивает записи по убыванию приоритета, затем по времени
+// загрузки.
+func compareDue(a, b *frontierRecord) int {
+	return cmp.Or(
+		cmp.Compare(b.Priority, a.Priority),
+		a.NextFetchAt.Compare(b.NextFetchAt),
+		cmp.Compare(a.ID, b.ID),
+	)
+}
+
+func (s *Store) CompleteURL(ctx context.Context, id int64, nextFetchAt time.Time, fetch *storage.FetchState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if rec, ok := s.frontier[id]; ok {
+		rec.Attempts = 0
+		rec.NextFetchAt = nextFetchAt
+		rec.LeaseOwner, rec.LeaseExpiresAt = "", time.Time{}
+		if fetch != nil {
+			rec.ETag = fetch.ETag
+			rec.HTTPLastModified = fetch.HTTPLastModified
+			rec.ContentHash = fetch.ContentHash
+			rec.RevisitInterval = fetch.RevisitInterval
+		}
+	}
+	return nil
+}
+
+func (s *Store) FailURL(ctx context.Context, id int64, retryAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if rec, ok := s.frontier[id]; ok {
+		rec.Attempts++
+		rec.NextFetchAt = retryAt
+		rec.LeaseOwner, rec.LeaseExpiresAt = "", time.Time{}
+	}
+	return nil
+}
+
+func (s *Store) ReleaseURLs(ctx context.Context, ids []int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, id := range ids {
+		if rec, ok := s.frontier[id]; ok {
+			rec.LeaseOwner, rec.LeaseExpiresAt = "", time.Time{}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"maps"
+	"slices"
+	"strings"
+
+	"cis-engine/internal/analysis"
+)
+
+// Поля документа в индексе в порядке убывания веса по умолчанию.
+const (
+	fieldTitle = iota
+	fieldHeadings
+	fieldAnchors
+	fieldBody
+	numFields
+)
+
+// fieldGap - смещение позиций соседних полей. Позиция терма делится на
+// fieldGap, чтобы получить поле, а фраза не может пересечь границу полей.
+// Термы поля сверх fieldGap-1 позиций не индексируются.
+const fieldGap = 1 << 20
+
+// maxIndexedAnchors ограничивает число индексируемых текстов входящих
+// ссылок на страницу, как и в postgres.
+const maxIndexedAnchors = 200
+
+type docStats struct {
+	lengths [numFields]int
+	terms   []string
+}
+
+// invertedIndex хранит для каждого терма позиции его вхождений в
+// документы.
+type invertedIndex struct {
+	postings map[string]map[int64][]int
+	docs     map[int64]*docStats
+	totals   [numFields]int
+}
+
+func newInvertedIndex() *invertedIndex {
+	return &invertedIndex{
+		postings: make(map[string]map[int64][]int),
+		docs:     make(map[int64]*docStats),
+	}
+}
+
+// add индексирует документ. Части одного поля разделяются пропуском
+// позиции, чтобы фраза не объединяла, например, соседние заголовки.
+func (idx *invertedIndex) add(id int64, fields [numFields][]string) {
+	idx.remove(id)
+	stats := &docStats{}
+	terms := make(map[string]bool)
+	for f, parts := range fields {
+		pos := f * fieldGap
+		limit := (f+1)*fieldGap - 1
+	field:
+		for _, part := range parts {
+			for _, term := range analysis.Terms(part) {
+				if pos >= limit {
+					break field
+				}
+				docs := idx.postings[term]
+				if docs == nil {
+					docs = make(map[int64][]int)
+					idx.postings[term] = docs
+				}
+				docs[id] = append(docs[id], pos)
+				terms[term] = true
+				stats.lengths[f]++
+				pos++
+			}
+			pos++
+		}
+		idx.totals[f] += stats.lengths[f]
+	}
+	stats.terms = slices.Collect(maps.Keys(terms))
+	idx.docs[id] = stats
+}
+
+func (idx *invertedIndex) remove(id int64) {
+	stats, ok := idx.docs[id]
+	if !ok {
+		return
+	}
+	for _, term := range stats.terms {
+		delete(idx.postings[term], id)
+		if len(idx.postings[term]) == 0 {
+			delete(idx.postings, term)
+		}
+	}
+	for f := range numFields {
+		idx.totals[f] -= stats.lengths[f]
+	}
+	delete(idx.docs, id)
+}
+
+func (s *Store) IndexPages(ctx context.Context, limit int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := slices.Sorted(maps.Keys(s.pending))
+	ids = ids[:min(limit, len(ids))]
+	for _, id := range ids {
+		s.indexPage(id)
+	}
+	return len(ids), nil
+}
+
+// indexPage добавляет страницу в индекс. Вызывается под блокировкой s.mu.
+func (s *Store) indexPage(id int64) {
+	rec := s.pages[id]
+	page := &rec.Page
+
+	var fields [numFields][]string
+	fields[fieldTitle] = []string{page.Title}
+	fields[fieldHeadings] = []string{page.Description}
+	for _, h := range page.Headings {
+		fields[fieldHeadings] = append(fields[fieldHeadings], h.Text)
+	}
+	_, path, _ := strings.Cut(page.URL, "://")
+	fields[fieldAnchors] = []string{path}
+	fields[fieldAnchors] = append(fields[fieldAnchors], s.anchors(id)...)
+	fields[fieldBody] = []string{page.MainText()}
+
+	s.index.add(id, fields)
+	rec.Indexed = true
+	delete(s.pending, id)
+}
+
+// anchors возвращает тексты ссылок других страниц на страницу id.
+func (s *Store) anchors(id int64) []string {
+	type ref struct {
+		from int64
+		i    int
+	}
+	var refs []ref
+	for _, u := range s.pageURLs(id) {
+		for from, i := range s.linksTo[u] {
+			if from != id {
+				refs = append(refs, ref{from, i})
+			}
+		}
+	}
+	slices.SortFunc(refs, func(a, b ref) int {
+		return cmp.Or(cmp.Compare(a.from, b.from), cmp.Compare(a.i, b.i))
+	})
+
+	var anchors []string
+	for _, r := range refs {
+		if l := s.links[r.from][r.i]; l.AnchorText != "" && len(anchors) < maxIndexedAnchors {
+			anchors = append(anchors, l.AnchorText)
+		}
+	}
+	return anchors
+}
@@ -0,0 +1,145 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+
+	"cis-engine/internal/storage"
+)
+
+// storeLinks заменяет исходящие ссылки страницы. Вызывается под
+// блокировкой s.mu.
+func (s *Store) storeLinks(pageID int64, links []storage.Link) {
+	s.deleteLinks(pageID)
+	stored := make([]storage.Link, 0, len(links))
+	seen := make(map[string]bool, len(links))
+	for _, link := range links {
+		toURL, err := normalizer.Normalize(link.ToURL)
+		if err != nil || seen[toURL] {
+			continue
+		}
+		seen[toURL] = true
+		stored = append(stored, storage.Link{
+			FromPageID: pageID,
+			ToURL:      toURL,
+			AnchorText: link.AnchorText,
+			Rel:        link.Rel,
+			NoFollow:   link.NoFollow,
+		})
+	}
+	s.links[pageID] = stored
+	for i, l := range stored {
+		from := s.linksTo[l.ToURL]
+		if from == nil {
+			from = make(map[int64]int)
+			s.linksTo[l.ToURL] = from
+		}
+		from[pageID] = i
+	}
+}
+
+// deleteLinks удаляет исходящие ссылки страницы. Вызывается под
+// блокировкой s.mu.
+func (s *Store) deleteLinks(pageID int64) {
+	for _, l := range s.links[pageID] {
+		delete(s.linksTo[l.ToURL], pageID)
+		if len(s.linksTo[l.ToURL]) == 0 {
+			delete(s.linksTo, l.ToURL)
+		}
+	}
+	delete(s.links, pageID)
+}
+
+// link возвращает копию ссылки с заполненными FromURL и ToPageID.
+func (s *Store) link(l storage.Link) *storage.Link {
+	l.FromURL = s.pages[l.FromPageID].Page.URL
+	l.ToPageID = s.resolve(l.ToURL)
+	return &l
+}
+
+func (s *Store) GetOutboundLinks(ctx context.Context, pageID int64, limit int) ([]*storage.Link, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.pages[pageID]; !ok {
+		return nil, fmt.Errorf("страница #%d: %w", pageID, storage.ErrNotFound)
+	}
+	links := []*storage.Link{}
+	for _, l := range s.links[pageID] {
+		links = append(links, s.link(l))
+	}
+	slices.SortFunc(links, func(a, b *storage.Link) int { return cmp.Compare(a.ToURL, b.ToURL) })
+	return links[:min(limit, len(links))], nil
+}
+
+func (s *Store) GetInboundLinks(ctx context.Context, pageID int64, limit int) ([]*storage.Link, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.pages[pageID]; !ok {
+		return nil, fmt.Errorf("страница #%d: %w", pageID, storage.ErrNotFound)
+	}
+	links := []*storage.Link{}
+	for _, from := range s.inbound(pageID) {
+		links = append(links, s.link(from))
+	}
+	slices.SortFunc(links, func(a, b *storage.Link) int { return cmp.Compare(a.FromURL, b.FromURL) })
+	return links[:min(limit, len(links))], nil
+}
+
+// inbound возвращает ссылки на страницу pageID, упорядоченные по ID
+// ссылающейся страницы и порядку ссылок на ней.
+func (s *Store) inbound(pageID int64) []storage.Link {
+	var links []storage.Link
+	for _, u := range s.pageURLs(pageID) {
+		if s.resolve(u) != pageID {
+			continue
+		}
+		for from, i := range s.linksTo[u] {
+			links = append(links, s.links[from][i])
+		}
+	}
+	slices.SortFunc(links, func(a, b storage.Link) int {
+		return cmp.Or(cmp.Compare(a.FromPageID, b.FromPageID), cmp.Compare(a.ToURL, b.ToURL))
+	})
+	return links
+}
+
+func (s *Store) GetLinkGraph(ctx context.Context) (*storage.LinkGraph, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	graph := &storage.LinkGraph{Nodes: make([]int64, 0, len(s.pages))}
+	for id := range s.pages {
+		graph.Nodes = append(graph.Nodes, id)
+	}
+	slices.Sort(graph.Nodes)
+
+	for from, outbound := range s.links {
+		for _, l := range outbound {
+			if to := s.resolve(l.ToURL); to != 0 && to != from && !l.NoFollow {
+				graph.Edges = append(graph.Edges, storage.Edge{From: from, To: to})
+			}
+		}
+	}
+	return graph, nil
+}
+
+func (s *Store) UpdateAuthorityScores(ctx context.Context, scores map[int64]float64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, rec := range s.pages {
+		rec.Authority = scores[id]
+	}
+	return nil
+}
+
+// UpdateTermStats ничего не делает: встроенный индекс обновляет
+// статистику термов при индексации, а слова для автодополнения считает
+// GetSuggestionSources.
+func (s *Store) UpdateTermStats(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,136 @@
+// Package memory - встроенное хранилище без внешней базы данных. Страницы,
+// граф ссылок и очередь краулера хранятся в памяти процесса, поиск
+// выполняется по собственному инвертированному индексу с ранжированием
+// BM25. Состояние можно сохранять в файл снимка и загружать при запуске.
+package memory
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"cis-engine/internal/storage"
+	"cis-engine/internal/urlnorm"
+)
+
+type Store struct {
+	mu      sync.RWMutex
+	path    string
+	weights storage.RankWeights
+
+	nextPageID     int64
+	nextFrontierID int64
+	pages          map[int64]*pageRecord
+	byURL          map[string]int64
+	aliases        map[string]aliasRecord
+	// pageAliases - альтернативные адреса каждой страницы, обратный к aliases.
+	pageAliases map[int64]map[string]bool
+	// links - исходящие ссылки страниц; ToPageID определяется при чтении.
+	links map[int64][]storage.Link
+	// linksTo - для каждого адреса номера ссылок на него в links страниц.
+	linksTo  map[string]map[int64]int
+	frontier map[int64]*frontierRecord
+	queue    map[string]int64
+
+	index *invertedIndex
+	// pending - сохраненные, но еще не проиндексированные страницы.
+	pending  map[int64]bool
+	watchers map[chan struct{}]bool
+
+	// queryLog упорядочен по времени добавления записей.
+	queryLog []storage.QueryLogEntry
+}
+
+var _ storage.Storer = (*Store)(nil)
+
+// normalizer выполняет только синтаксическую нормализацию, как и в postgres.
+var normalizer urlnorm.Options
+
+// New создает пустое хранилище без снимка.
+func New() *Store {
+	return &Store{
+		weights: storage.DefaultRankWeights(),
+		pages:   make(map[int64]*pageRecord),
+		byURL:   make(map[string]int64),
+		aliases: make(map[string]aliasRecord),
+		links:   make(map[int64][]storage.Link),
+		linksTo: make(map[string]map[int64]int),
+
+		pageAliases: make(map[int64]map[string]bool),
+		frontier:    make(map[int64]*frontierRecord),
+		queue:       make(map[string]int64),
+		index:       newInvertedIndex(),
+		pending:     make(map[int64]bool),
+		watchers:    make(map[chan struct{}]bool),
+	}
+}
+
+// Open создает хранилище, связанное с файлом снимка path: состояние
+// загружается из файла, если он существует, и сохраняется в него при Close.
+func Open(path string) (*Store, error) {
+	s := New()
+	s.path = path
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// SetRankWeights задает веса, с которыми ранжирует SearchPages.
+func (s *Store) SetRankWeights(w storage.RankWeights) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.weights = w
+}
+
+// Close сохраняет снимок, если хранилище открыто через Open.
+func (s *Store) Close() {
+	if err := s.Save(); err != nil {
+		log.Printf("Ошибка сохранения снимка %s: %v", s.path, err)
+	}
+}
+
+func (s *Store) WatchPages(ctx context.Context) (<-chan struct{}, error) {
+	events := make(chan struct{}, 1)
+	s.mu.Lock()
+	s.watchers[events] = true
+	s.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		s.mu.Lock()
+		delete(s.watchers, events)
+		s.mu.Unlock()
+		close(events)
+	}()
+	return events, nil
+}
+
+// notify сообщает подписчикам WatchPages о новых страницах. Вызывается
+// под блокировкой s.mu.
+func (s *Store) notify() {
+	for events := range s.watchers {
+		select {
+		case events <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (s *Store) GetMetrics(ctx context.Context) (*storage.Metrics, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	m := &storage.Metrics{
+		PagesCount:    int64(len(s.pages)),
+		FrontierCount: int64(len(s.frontier)),
+	}
+	now := time.Now()
+	for _, f := range s.frontier {
+		if !f.NextFetchAt.After(now) {
+			m.FrontierDue++
+		}
+	}
+	return m, nil
+}
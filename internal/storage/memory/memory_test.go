@@ -0,0 +1,494 @@
+package memory
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"cis-engine/internal/storage"
+
+	"github.com/stretchr/testify/require"
+)
+
+func storeAndIndex(t *testing.T, s *Store, pages ...*storage.Page) {
+	ctx := context.Background()
+	for _, p := range pages {
+		_, err := s.StorePage(ctx, p)
+		require.NoError(t, err)
+	}
+	_, err := s.IndexPages(ctx, len(pages))
+	require.NoError(t, err)
+}
+
+func TestSearchWorkflow(t *testing.T) {
+	s := New()
+	ctx := context.Background()
+
+	storeAndIndex(t, s,
+		&storage.Page{URL: "https://golang.org", Lang: "en", Title: "Go Language", Body: "Go is an open source programming language that makes it easy to build simple, reliable, and efficient software."},
+		&storage.Page{URL: "https://vuejs.org/", Lang: "en", Title: "Vue.js", Body: "Vue.js is a progressive, incrementally-adoptable JavaScript framework for building UI on the web."},
+		&storage.Page{URL: "https://gobyexample.com", Lang: "en", Title: "Go by Example", Body: "A hands-on introduction to Go using annotated example programs. A great resource for learning Go."},
+		&storage.Page{URL: "https://example.ru", Lang: "ru", Title: "Поисковые системы", Body: "Поисковая система находит страницы по запросу пользователя."},
+	)
+
+	search := func(text string) []*storage.Page {
+		result, err := s.SearchPages(ctx, &storage.SearchQuery{Text: text, Snippet: storage.DefaultSnippetOptions(), Limit: 20})
+		require.NoError(t, err)
+		require.Equal(t, int64(len(result.Pages)), result.Total)
+		return result.Pages
+	}
+
+	t.Run("Поиск по уникальному слову 'framework'", func(t *testing.T) {
+		results := search("framework")
+		require.Len(t, results, 1)
+		require.Equal(t, "https://vuejs.org/", results[0].URL)
+		require.Contains(t, results[0].Snippet, "<mark>framework</mark>")
+	})
+
+	t.Run("Поиск по общему слову 'go'", func(t *testing.T) {
+		results := search("go")
+		require.Len(t, results, 2)
+		require.Greater(t, results[0].Score, results[1].Score)
+	})
+
+	t.Run("Стемминг", func(t *testing.T) {
+		require.Len(t, search("programs"), 2)
+		require.Len(t, search("поисковый запрос"), 1)
+	})
+
+	t.Run("Фразы, ИЛИ и исключение", func(t *testing.T) {
+		require.Len(t, search(`"open source"`), 1)
+		require.Empty(t, search(`"source open"`))
+		require.Len(t, search("framework or annotated"), 2)
+		require.Len(t, search("go -example"), 1)
+	})
+
+	t.Run("Фильтр по языку", func(t *testing.T) {
+		result, err := s.SearchPages(ctx, &storage.SearchQuery{Text: "go", Lang: "ru", Limit: 20})
+		require.NoError(t, err)
+		require.Empty(t, result.Pages)
+	})
+
+	t.Run("Постраничная выдача", func(t *testing.T) {
+		query := &storage.SearchQuery{Text: "go", Snippet: storage.DefaultSnippetOptions(), Limit: 1}
+		first, err := s.SearchPages(ctx, query)
+		require.NoError(t, err)
+		require.Len(t, first.Pages, 1)
+		require.Equal(t, int64(2), first.Total)
+
+		last := first.Pages[0]
+		query.After = &storage.SearchCursor{Score: last.Score, ID: last.ID}
+		second, err := s.SearchPages(ctx, query)
+		require.NoError(t, err)
+		require.Len(t, second.Pages, 1)
+		require.NotEqual(t, last.URL, second.Pages[0].URL)
+
+		query.After, query.Offset = nil, 5
+		empty, err := s.SearchPages(ctx, query)
+		require.NoError(t, err)
+		require.Empty(t, empty.Pages)
+		require.Equal(t, int64(2), empty.Total)
+	})
+}
+
+func TestSearchFilter(t *testing.T) {
+	s := New()
+	storeAndIndex(t, s,
+		&storage.Page{URL: "https://go.dev/blog/gc", Title: "Garbage collector", ContentType: "text/html; charset=utf-8"},
+		&storage.Page{URL: "https://pkg.go.dev/runtime", Title: "Package runtime collector", ContentType: "text/html"},
+		&storage.Page{URL: "https://example.com/blog/gc.txt", Title: "Collector notes", ContentType: "text/plain"},
+	)
+
+	search := func(f storage.SearchFilter) int {
+		result, err := s.SearchPages(context.Background(), &storage.SearchQuery{Text: "collector", Filter: f, Limit: 10})
+		require.NoError(t, err)
+		return len(result.Pages)
+	}
+
+	require.Equal(t, 3, search(storage.SearchFilter{}))
+	require.Equal(t, 2, search(storage.SearchFilter{Site: "go.dev"}))
+	require.Equal(t, 1, search(storage.SearchFilter{Site: "go.dev", PathPrefix: "/blog/"}))
+	require.Equal(t, 2, search(storage.SearchFilter{ContentType: "text/html"}))
+	require.Equal(t, 3, search(storage.SearchFilter{CrawledAfter: time.Now().Add(-time.Hour)}))
+	require.Equal(t, 0, search(storage.SearchFilter{CrawledBefore: time.Now().Add(-time.Hour)}))
+}
+
+func TestWeightedRanking(t *testing.T) {
+	s := New()
+	storeAndIndex(t, s,
+		&storage.Page{URL: "https://example.com/body", Title: "Notes", Body: "Somewhere in the footer a gopher is mentioned."},
+		&storage.Page{URL: "https://example.com/heading", Title: "Notes", Headings: []storage.Heading{{Level: 2, Text: "Gopher"}}},
+		&storage.Page{URL: "https://example.com/title", Title: "Gopher"},
+	)
+
+	result, err := s.SearchPages(context.Background(), &storage.SearchQuery{Text: "gopher", Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, result.Pages, 3)
+	require.Equal(t, "https://example.com/title", result.Pages[0].URL)
+	require.Equal(t, "https://example.com/heading", result.Pages[1].URL)
+	require.Equal(t, "https://example.com/body", result.Pages[2].URL)
+}
+
+func TestReindexOnUpdate(t *testing.T) {
+	s := New()
+	ctx := context.Background()
+	storeAndIndex(t, s, &storage.Page{URL: "https://example.com/", Title: "Old title"})
+	storeAndIndex(t, s, &storage.Page{URL: "https://example.com/", Title: "New title"})
+
+	result, err := s.SearchPages(ctx, &storage.SearchQuery{Text: "old", Limit: 10})
+	require.NoError(t, err)
+	require.Empty(t, result.Pages)
+
+	result, err = s.SearchPages(ctx, &storage.SearchQuery{Text: "new", Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, result.Pages, 1)
+
+	t.Run("Неизменное тело не переиндексируется", func(t *testing.T) {
+		page := &storage.Page{URL: "https://example.com/same", Title: "Same", ContentHash: "abc"}
+		storeAndIndex(t, s, page)
+		_, err := s.StorePage(ctx, &storage.Page{URL: "https://example.com/same", Title: "Same", ContentHash: "abc"})
+		require.NoError(t, err)
+		n, err := s.IndexPages(ctx, 10)
+		require.NoError(t, err)
+		require.Zero(t, n)
+
+		_, err = s.StorePage(ctx, &storage.Page{URL: "https://example.com/same", Title: "Changed", ContentHash: "def"})
+		require.NoError(t, err)
+		n, err = s.IndexPages(ctx, 10)
+		require.NoError(t, err)
+		require.Equal(t, 1, n)
+	})
+}
+
+func TestMainText(t *testing.T) {
+	s := New()
+	ctx := context.Background()
+	storeAndIndex(t, s, &storage.Page{
+		URL:     "https://example.com/post",
+		Title:   "Post",
+		Body:    "Home Login Post Gophers are friendly",
+		Content: "Gophers are friendly",
+	})
+
+	result, err := s.SearchPages(ctx, &storage.SearchQuery{Text: "login", Limit: 10})
+	require.NoError(t, err)
+	require.Empty(t, result.Pages, "служебные блоки не индексируются")
+
+	result, err = s.SearchPages(ctx, &storage.SearchQuery{Text: "gophers", Snippet: storage.DefaultSnippetOptions(), Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, result.Pages, 1)
+	require.NotContains(t, result.Pages[0].Snippet, "Login")
+}
+
+func TestOversizedBody(t *testing.T) {
+	s := New()
+	ctx := context.Background()
+	storeAndIndex(t, s, &storage.Page{
+		URL:   "https://example.com/big",
+		Title: "Big",
+		Body:  strings.Repeat("gopher ", fieldGap+10) + "tail",
+	})
+
+	result, err := s.SearchPages(ctx, &storage.SearchQuery{Text: "gopher", Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, result.Pages, 1)
+
+	result, err = s.SearchPages(ctx, &storage.SearchQuery{Text: "tail", Limit: 10})
+	require.NoError(t, err)
+	require.Empty(t, result.Pages, "термы сверх лимита позиций поля не индексируются")
+}
+
+func TestDuplicates(t *testing.T) {
+	s := New()
+	ctx := context.Background()
+
+	storeAndIndex(t, s,
+		&storage.Page{URL: "https://example.com/docs", Title: "Go docs", SimHash: 0b1011},
+		&storage.Page{URL: "https://mirror.example/docs", Title: "Go docs mirror", SimHash: 0b1010},
+		&storage.Page{URL: "https://example.com/print/docs", Title: "Go docs", SimHash: 0b1001},
+		&storage.Page{URL: "https://example.com/blog", Title: "Go blog"},
+	)
+	fingerprints, err := s.GetFingerprints(ctx)
+	require.NoError(t, err)
+	require.Len(t, fingerprints, 3)
+	canonical := fingerprints[0].ID
+	require.NoError(t, s.UpdateDuplicates(ctx, map[int64]int64{fingerprints[1].ID: canonical, fingerprints[2].ID: canonical}))
+
+	result, err := s.SearchPages(ctx, &storage.SearchQuery{Text: "go", Limit: 10})
+	require.NoError(t, err)
+	require.Equal(t, int64(2), result.Total)
+	require.Len(t, result.Pages, 2)
+	for _, p := range result.Pages {
+		if p.ID == canonical {
+			require.Equal(t, 2, p.Duplicates)
+		}
+	}
+
+	t.Run("Каноническая страница не найдена", func(t *testing.T) {
+		result, err := s.SearchPages(ctx, &storage.SearchQuery{Text: "mirror", Limit: 10})
+		require.NoError(t, err)
+		require.Len(t, result.Pages, 1)
+		require.Equal(t, "https://mirror.example/docs", result.Pages[0].URL)
+	})
+
+	t.Run("Без схлопывания", func(t *testing.T) {
+		result, err := s.SearchPages(ctx, &storage.SearchQuery{Text: "go", Limit: 10, ShowDuplicates: true})
+		require.NoError(t, err)
+		require.Equal(t, int64(4), result.Total)
+	})
+
+	t.Run("Изменившаяся страница выходит из группы", func(t *testing.T) {
+		storeAndIndex(t, s, &storage.Page{URL: "https://mirror.example/docs", Title: "Go docs mirror", SimHash: 0xff00})
+		result, err := s.SearchPages(ctx, &storage.SearchQuery{Text: "go", Limit: 10})
+		require.NoError(t, err)
+		require.Equal(t, int64(3), result.Total)
+	})
+}
+
+func TestLinks(t *testing.T) {
+	s := New()
+	ctx := context.Background()
+
+	homeID, err := s.StorePage(ctx, &storage.Page{
+		URL:   "https://example.com/",
+		Title: "Home",
+		Links: []storage.Link{
+			{ToURL: "https://example.com/about", AnchorText: "About us"},
+			{ToURL: "https://ads.example.net/", Rel: "nofollow", NoFollow: true},
+		},
+	})
+	require.NoError(t, err)
+	aboutID, err := s.StorePage(ctx, &storage.Page{URL: "https://example.com/about", Title: "About"})
+	require.NoError(t, err)
+
+	outbound, err := s.GetOutboundLinks(ctx, homeID, 10)
+	require.NoError(t, err)
+	require.Len(t, outbound, 2)
+
+	inbound, err := s.GetInboundLinks(ctx, aboutID, 10)
+	require.NoError(t, err)
+	require.Len(t, inbound, 1)
+	require.Equal(t, homeID, inbound[0].FromPageID)
+	require.Equal(t, "About us", inbound[0].AnchorText)
+
+	_, err = s.GetInboundLinks(ctx, 9999, 10)
+	require.ErrorIs(t, err, storage.ErrNotFound)
+
+	graph, err := s.GetLinkGraph(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []int64{homeID, aboutID}, graph.Nodes)
+	require.Equal(t, []storage.Edge{{From: homeID, To: aboutID}}, graph.Edges)
+
+	_, err = s.IndexPages(ctx, 10)
+	require.NoError(t, err)
+	result, err := s.SearchPages(ctx, &storage.SearchQuery{Text: "us", Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, result.Pages, 1)
+	require.Equal(t, aboutID, result.Pages[0].ID, "текст входящей ссылки должен индексироваться")
+
+	t.Run("Замена ссылок и альтернативные адреса", func(t *testing.T) {
+		_, err := s.StorePage(ctx, &storage.Page{
+			URL:   "https://example.com/",
+			Title: "Home",
+			Links: []storage.Link{{ToURL: "https://example.com/about-us", AnchorText: "Team"}},
+		})
+		require.NoError(t, err)
+		inbound, err := s.GetInboundLinks(ctx, aboutID, 10)
+		require.NoError(t, err)
+		require.Empty(t, inbound, "прежние ссылки страницы удаляются")
+
+		_, err = s.StorePage(ctx, &storage.Page{
+			URL:     "https://example.com/about",
+			Title:   "About",
+			Aliases: []storage.URLAlias{{URL: "https://example.com/about-us", Kind: storage.AliasRedirect}},
+		})
+		require.NoError(t, err)
+		inbound, err = s.GetInboundLinks(ctx, aboutID, 10)
+		require.NoError(t, err)
+		require.Len(t, inbound, 1, "ссылка на альтернативный адрес ведет на страницу")
+		require.Equal(t, "Team", inbound[0].AnchorText)
+		require.Equal(t, []string{"https://example.com/about", "https://example.com/about-us"}, s.pageURLs(aboutID))
+
+		s.mu.Lock()
+		s.deletePage(homeID)
+		s.mu.Unlock()
+		inbound, err = s.GetInboundLinks(ctx, aboutID, 10)
+		require.NoError(t, err)
+		require.Empty(t, inbound, "ссылки удаленной страницы удаляются")
+		require.Empty(t, s.linksTo)
+	})
+}
+
+func TestFrontierWorkflow(t *testing.T) {
+	s := New()
+	ctx := context.Background()
+
+	err := s.EnqueueURLs(ctx, []*storage.FrontierURL{
+		{URL: "https://example.com/low", Priority: 0},
+		{URL: "https://example.com/high", Priority: 10},
+		{URL: "https://example.com/later", NextFetchAt: time.Now().Add(time.Hour)},
+	})
+	require.NoError(t, err)
+
+	leased, err := s.LeaseURLs(ctx, "worker-1", 10, 10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, leased, 2)
+	require.Equal(t, "https://example.com/high", leased[0].URL)
+
+	again, err := s.LeaseURLs(ctx, "worker-2", 10, 10, time.Minute)
+	require.NoError(t, err)
+	require.Empty(t, again)
+
+	require.NoError(t, s.FailURL(ctx, leased[1].ID, time.Now().Add(-time.Second)))
+	retried, err := s.LeaseURLs(ctx, "worker-2", 10, 10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, retried, 1)
+	require.Equal(t, 1, retried[0].Attempts)
+
+	require.NoError(t, s.CompleteURL(ctx, leased[0].ID, time.Now().Add(time.Hour), nil))
+	err = s.EnqueueURLs(ctx, []*storage.FrontierURL{
+		{URL: "https://example.com/high", NextFetchAt: time.Now().Add(-time.Second), Force: true},
+	})
+	require.NoError(t, err)
+	due, err := s.LeaseURLs(ctx, "worker-3", 10, 10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+	require.Equal(t, "https://example.com/high", due[0].URL)
+}
+
+func TestFrontierSitemapHints(t *testing.T) {
+	s := New()
+	ctx := context.Background()
+
+	lastMod := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	err := s.EnqueueURLs(ctx, []*storage.FrontierURL{
+		{URL: "https://example.com/sitemap.xml", Sitemap: true},
+		{URL: "https://example.com/page", LastModified: lastMod, RevisitInterval: 7 * 24 * time.Hour},
+	})
+	require.NoError(t, err)
+	leased, err := s.LeaseURLs(ctx, "worker-1", 10, 10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, leased, 2)
+	for _, u := range leased {
+		if u.URL == "https://example.com/page" {
+			require.True(t, u.LastModified.Equal(lastMod))
+			require.Equal(t, 7*24*time.Hour, u.RevisitInterval)
+		} else {
+			require.True(t, u.Sitemap)
+		}
+		require.NoError(t, s.CompleteURL(ctx, u.ID, time.Now().Add(time.Hour), nil))
+	}
+
+	// Та же дата изменения не переносит загрузку, более поздняя - переносит.
+	page := &storage.FrontierURL{URL: "https://example.com/page", LastModified: lastMod}
+	require.NoError(t, s.EnqueueURLs(ctx, []*storage.FrontierURL{page}))
+	due, err := s.LeaseURLs(ctx, "worker-2", 10, 10, time.Minute)
+	require.NoError(t, err)
+	require.Empty(t, due)
+
+	page.LastModified = lastMod.Add(24 * time.Hour)
+	require.NoError(t, s.EnqueueURLs(ctx, []*storage.FrontierURL{page}))
+	due, err = s.LeaseURLs(ctx, "worker-2", 10, 10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+	require.Equal(t, "https://example.com/page", due[0].URL)
+	require.Equal(t, 7*24*time.Hour, due[0].RevisitInterval, "интервал без нового значения сохраняется")
+}
+
+func TestSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.gob")
+	ctx := context.Background()
+
+	s, err := Open(path)
+	require.NoError(t, err)
+	storeAndIndex(t, s, &storage.Page{URL: "https://golang.org", Title: "Go Language"})
+	_, err = s.StorePage(ctx, &storage.Page{URL: "https://example.com", Title: "Example"})
+	require.NoError(t, err)
+	require.NoError(t, s.EnqueueURLs(ctx, []*storage.FrontierURL{{URL: "https://example.com/next"}}))
+	s.Close()
+
+	restored, err := Open(path)
+	require.NoError(t, err)
+
+	result, err := restored.SearchPages(ctx, &storage.SearchQuery{Text: "language", Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, result.Pages, 1)
+
+	n, err := restored.IndexPages(ctx, 10)
+	require.NoError(t, err)
+	require.Equal(t, 1, n, "непроиндексированная страница должна остаться в очереди")
+
+	metrics, err := restored.GetMetrics(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), metrics.PagesCount)
+	require.Equal(t, int64(1), metrics.FrontierCount)
+}
+
+func TestQueryLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.gob")
+	ctx := context.Background()
+	s, err := Open(path)
+	require.NoError(t, err)
+
+	now := time.Now().Truncate(time.Second)
+	entries := []*storage.QueryLogEntry{
+		{Query: "Go", Normalized: "go", Results: 10, Latency: 4 * time.Millisecond, ClientID: "a", CreatedAt: now.Add(-time.Hour)},
+		{Query: "go", Normalized: "go", Results: 20, Latency: 30 * time.Millisecond, ClientID: "b", CreatedAt: now.Add(-30 * time.Minute)},
+		{Query: "gorutine", Normalized: "gorutine", Latency: 200 * time.Millisecond, ClientID: "a", CreatedAt: now.Add(-10 * time.Minute)},
+		{Query: "old", Normalized: "old", Latency: time.Millisecond, CreatedAt: now.Add(-48 * time.Hour)},
+	}
+	require.NoError(t, s.LogQueries(ctx, entries))
+
+	from, to := now.Add(-24*time.Hour), now
+	top, err := s.TopQueries(ctx, from, to, 10, false)
+	require.NoError(t, err)
+	require.Len(t, top, 2)
+	require.Equal(t, "go", top[0].Query)
+	require.Equal(t, int64(2), top[0].Count)
+	require.Equal(t, int64(2), top[0].Clients)
+	require.Equal(t, 15.0, top[0].AvgResults)
+	require.True(t, top[0].LastSeen.Equal(now.Add(-30*time.Minute)))
+
+	zero, err := s.TopQueries(ctx, from, to, 10, true)
+	require.NoError(t, err)
+	require.Len(t, zero, 1)
+	require.Equal(t, "gorutine", zero[0].Query)
+
+	stats, err := s.QueryLatency(ctx, from, to, []time.Duration{10 * time.Millisecond, 100 * time.Millisecond})
+	require.NoError(t, err)
+	require.Equal(t, int64(3), stats.Count)
+	require.Equal(t, 30*time.Millisecond, stats.P50)
+	require.Equal(t, 200*time.Millisecond, stats.P99)
+	require.Equal(t, []storage.LatencyBucket{
+		{UpperBound: 10 * time.Millisecond, Count: 1},
+		{UpperBound: 100 * time.Millisecond, Count: 1},
+		{Count: 1},
+	}, stats.Buckets)
+
+	deleted, err := s.DeleteQueryLog(ctx, from)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), deleted)
+	s.Close()
+
+	restored, err := Open(path)
+	require.NoError(t, err)
+	top, err = restored.TopQueries(ctx, from, to, 1, false)
+	require.NoError(t, err)
+	require.Equal(t, "go", top[0].Query, "журнал должен сохраняться в снимке")
+}
+
+func TestGetSuggestionSources(t *testing.T) {
+	s := New()
+	storeAndIndex(t, s,
+		&storage.Page{URL: "https://example.com/1", Title: "Goroutines", Body: "Goroutines and channels in Go 2024."},
+		&storage.Page{URL: "https://example.com/2", Title: "Channels", Body: "Buffered channels."},
+	)
+	require.NoError(t, s.UpdateAuthorityScores(context.Background(), map[int64]float64{2: 1}))
+
+	src, err := s.GetSuggestionSources(context.Background(), 10)
+	require.NoError(t, err)
+	require.Equal(t, []storage.WeightedText{{Text: "Channels", Weight: 1}, {Text: "Goroutines", Weight: 0}}, src.Titles)
+	require.Equal(t, []storage.WeightedText{{Text: "channels", Weight: 2}}, src.Terms)
+}
@@ -0,0 +1,147 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"cis-engine/internal/storage"
+)
+
+type pageRecord struct {
+	// Page хранится без Aliases, Links, Snippet и Score.
+	Page      storage.Page
+	Authority float64
+	Indexed   bool
+}
+
+type aliasRecord struct {
+	PageID int64
+	Kind   string
+}
+
+func (s *Store) StorePage(ctx context.Context, page *storage.Page) (int64, error) {
+	pageURL, err := normalizer.Normalize(page.URL)
+	if err != nil {
+		return 0, fmt.Errorf("некорректный URL страницы %s: %w", page.URL, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := *page
+	stored.URL = pageURL
+	stored.Aliases, stored.Links = nil, nil
+	stored.Snippet, stored.Score = "", 0
+	stored.CrawledAt = time.Now()
+
+	id, ok := s.byURL[pageURL]
+	if ok {
+		rec := s.pages[id]
+		stored.ID = id
+		// Изменившийся текст группируется заново при следующем пересчете.
+		stored.DuplicateOf = 0
+		if stored.SimHash == rec.Page.SimHash {
+			stored.DuplicateOf = rec.Page.DuplicateOf
+		}
+		if rec.Indexed && stored.ContentHash != "" && stored.ContentHash == rec.Page.ContentHash && stored.Lang == rec.Page.Lang {
+			// Тело не изменилось: индекс страницы остается прежним.
+			rec.Page = stored
+		} else {
+			s.index.remove(id)
+			rec.Page, rec.Indexed = stored, false
+			s.pending[id] = true
+		}
+	} else {
+		s.nextPageID++
+		id = s.nextPageID
+		stored.ID = id
+		stored.DuplicateOf = 0
+		s.pages[id] = &pageRecord{Page: stored}
+		s.byURL[pageURL] = id
+		s.pending[id] = true
+	}
+
+	// Копии страницы, сохраненные ранее под ее альтернативными адресами,
+	// удаляются.
+	for _, alias := range page.Aliases {
+		aliasURL, err := normalizer.Normalize(alias.URL)
+		if err != nil || aliasURL == pageURL {
+			continue
+		}
+		if oldID, ok := s.byURL[aliasURL]; ok {
+			s.deletePage(oldID)
+		}
+		s.setAlias(aliasURL, aliasRecord{PageID: id, Kind: alias.Kind})
+	}
+
+	s.storeLinks(id, page.Links)
+	s.notify()
+	return id, nil
+}
+
+// deletePage удаляет страницу вместе с ее исходящими ссылками и
+// альтернативными адресами.
+func (s *Store) deletePage(id int64) {
+	rec, ok := s.pages[id]
+	if !ok {
+		return
+	}
+	s.index.remove(id)
+	delete(s.pages, id)
+	delete(s.byURL, rec.Page.URL)
+	s.deleteLinks(id)
+	delete(s.pending, id)
+	for u := range s.pageAliases[id] {
+		delete(s.aliases, u)
+	}
+	delete(s.pageAliases, id)
+	for _, other := range s.pages {
+		if other.Page.DuplicateOf == id {
+			other.Page.DuplicateOf = 0
+		}
+	}
+}
+
+// resolve возвращает ID страницы, сохраненной под адресом u или
+// указавшей его альтернативным адресом; 0, если такой страницы нет.
+func (s *Store) resolve(u string) int64 {
+	if id, ok := s.byURL[u]; ok {
+		return id
+	}
+	return s.aliases[u].PageID
+}
+
+// setAlias связывает альтернативный адрес со страницей. Вызывается под
+// блокировкой s.mu.
+func (s *Store) setAlias(u string, alias aliasRecord) {
+	if old, ok := s.aliases[u]; ok {
+		delete(s.pageAliases[old.PageID], u)
+	}
+	s.aliases[u] = alias
+	if s.pageAliases[alias.PageID] == nil {
+		s.pageAliases[alias.PageID] = make(map[string]bool)
+	}
+	s.pageAliases[alias.PageID][u] = true
+}
+
+// pageURLs возвращает адрес страницы и все ее альтернативные адреса.
+func (s *Store) pageURLs(id int64) []string {
+	urls := []string{s.pages[id].Page.URL}
+	urls = append(urls, slices.Sorted(maps.Keys(s.pageAliases[id]))...)
+	return urls
+}
+
+func (s *Store) GetPage(ctx context.Context, id int64) (*storage.Page, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rec, ok := s.pages[id]
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+	page := rec.Page
+	return &page, nil
+}
@@ -0,0 +1,123 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"math"
+	"slices"
+	"sort"
+	"time"
+
+	"cis-engine/internal/storage"
+)
+
+func (s *Store) LogQueries(ctx context.Context, entries []*storage.QueryLogEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range entries {
+		s.queryLog = append(s.queryLog, *e)
+	}
+	return nil
+}
+
+func (s *Store) DeleteQueryLog(ctx context.Context, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	kept := s.queryLog[:0]
+	for _, e := range s.queryLog {
+		if !e.CreatedAt.Before(before) {
+			kept = append(kept, e)
+		}
+	}
+	deleted := int64(len(s.queryLog) - len(kept))
+	clear(s.queryLog[len(kept):])
+	s.queryLog = kept
+	return deleted, nil
+}
+
+// queriesIn возвращает записи журнала за [from, to).
+func (s *Store) queriesIn(from, to time.Time) []storage.QueryLogEntry {
+	var found []storage.QueryLogEntry
+	for _, e := range s.queryLog {
+		if !e.CreatedAt.Before(from) && e.CreatedAt.Before(to) {
+			found = append(found, e)
+		}
+	}
+	return found
+}
+
+func (s *Store) TopQueries(ctx context.Context, from, to time.Time, limit int, zeroResults bool) ([]*storage.QueryCount, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	counts := make(map[string]*storage.QueryCount)
+	clients := make(map[string]map[string]bool)
+	for _, e := range s.queriesIn(from, to) {
+		if zeroResults && e.Results != 0 {
+			continue
+		}
+		qc, ok := counts[e.Normalized]
+		if !ok {
+			qc = &storage.QueryCount{Query: e.Normalized}
+			counts[e.Normalized] = qc
+			clients[e.Normalized] = make(map[string]bool)
+		}
+		qc.Count++
+		qc.AvgResults += float64(e.Results)
+		if e.CreatedAt.After(qc.LastSeen) {
+			qc.LastSeen = e.CreatedAt
+		}
+		clients[e.Normalized][e.ClientID] = true
+	}
+
+	top := make([]*storage.QueryCount, 0, len(counts))
+	for q, qc := range counts {
+		qc.AvgResults /= float64(qc.Count)
+		qc.Clients = int64(len(clients[q]))
+		top = append(top, qc)
+	}
+	slices.SortFunc(top, func(a, b *storage.QueryCount) int {
+		return cmp.Or(cmp.Compare(b.Count, a.Count), cmp.Compare(a.Query, b.Query))
+	})
+	if len(top) > limit {
+		top = top[:limit]
+	}
+	return top, nil
+}
+
+func (s *Store) QueryLatency(ctx context.Context, from, to time.Time, bounds []time.Duration) (*storage.LatencyStats, error) {
+	s.mu.RLock()
+	entries := s.queriesIn(from, to)
+	s.mu.RUnlock()
+
+	stats := &storage.LatencyStats{
+		Count:   int64(len(entries)),
+		Buckets: make([]storage.LatencyBucket, len(bounds)+1),
+	}
+	for i, b := range bounds {
+		stats.Buckets[i].UpperBound = b
+	}
+	latencies := make([]time.Duration, len(entries))
+	for i, e := range entries {
+		latencies[i] = e.Latency
+		// Номер корзины - число границ, не превышающих время ответа, как
+		// у width_bucket в postgres.
+		n := sort.Search(len(bounds), func(j int) bool { return bounds[j] > e.Latency })
+		stats.Buckets[n].Count++
+	}
+	slices.Sort(latencies)
+	stats.P50 = percentile(latencies, 0.5)
+	stats.P90 = percentile(latencies, 0.9)
+	stats.P99 = percentile(latencies, 0.99)
+	return stats, nil
+}
+
+// percentile возвращает наименьшее значение sorted, не меньшее доли p
+// значений (percentile_disc в postgres); 0 для пустого списка.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(math.Ceil(p*float64(len(sorted)))) - 1
+	return sorted[max(i, 0)]
+}
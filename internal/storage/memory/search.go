@@ -0,0 +1,394 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"math"
+	"net/url"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"cis-engine/internal/analysis"
+	"cis-engine/internal/storage"
+)
+
+// phrase - последовательность термов, которые должны идти в документе
+// подряд; одиночное слово - фраза из одного терма.
+type phrase []string
+
+// clause - условие запроса: документ должен содержать хотя бы одну из
+// фраз, а при negated - ни одной.
+type clause struct {
+	phrases []phrase
+	negated bool
+}
+
+// parseQuery разбирает запрос так же, как websearch_to_tsquery: слова
+// объединяются по И, "or" - ИЛИ между соседними словами, текст в кавычках -
+// фраза, "-" перед словом исключает его.
+func parseQuery(text string) []clause {
+	var clauses []clause
+	var negated, or bool
+	add := func(terms []string) {
+		defer func() { negated, or = false, false }()
+		if len(terms) == 0 {
+			return
+		}
+		if last := len(clauses) - 1; or && last >= 0 && !clauses[last].negated && !negated {
+			clauses[last].phrases = append(clauses[last].phrases, terms)
+			return
+		}
+		clauses = append(clauses, clause{phrases: []phrase{terms}, negated: negated})
+	}
+
+	for text != "" {
+		r, size := utf8.DecodeRuneInString(text)
+		switch {
+		case unicode.IsSpace(r):
+			text = text[size:]
+		case r == '"':
+			quoted, rest, _ := strings.Cut(text[1:], `"`)
+			add(analysis.Terms(quoted))
+			text = rest
+		case r == '-':
+			negated = true
+			text = text[1:]
+		default:
+			end := strings.IndexFunc(text, func(r rune) bool { return unicode.IsSpace(r) || r == '"' })
+			if end < 0 {
+				end = len(text)
+			}
+			word := text[:end]
+			text = text[end:]
+			if strings.EqualFold(word, "or") && !negated {
+				or = true
+				continue
+			}
+			add(analysis.Terms(word))
+		}
+	}
+	return clauses
+}
+
+// match возвращает число вхождений фразы в каждое поле документов, где она
+// встречается.
+func (idx *invertedIndex) match(p phrase) map[int64]*[numFields]int {
+	hits := make(map[int64]*[numFields]int)
+	for id, positions := range idx.postings[p[0]] {
+		var tf [numFields]int
+		found := false
+	next:
+		for _, pos := range positions {
+			for k, term := range p[1:] {
+				if _, ok := slices.BinarySearch(idx.postings[term][id], pos+k+1); !ok {
+					continue next
+				}
+			}
+			tf[pos/fieldGap]++
+			found = true
+		}
+		if found {
+			hits[id] = &tf
+		}
+	}
+	return hits
+}
+
+func (s *Store) SearchPages(ctx context.Context, query *storage.SearchQuery) (*storage.SearchResult, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	clauses := parseQuery(query.Text)
+	scores := s.score(clauses, query)
+
+	hits := make([]hit, 0, len(scores))
+	for id, score := range scores {
+		hits = append(hits, hit{id, score})
+	}
+	slices.SortFunc(hits, func(a, b hit) int {
+		return cmp.Or(cmp.Compare(b.score, a.score), cmp.Compare(a.id, b.id))
+	})
+	duplicates := make(map[int64]int)
+	if !query.ShowDuplicates {
+		hits, duplicates = s.collapse(hits)
+	}
+
+	result := &storage.SearchResult{Pages: []*storage.Page{}, Total: int64(len(hits))}
+	if query.After != nil {
+		after := *query.After
+		start, _ := slices.BinarySearchFunc(hits, after, func(h hit, c storage.SearchCursor) int {
+			return cmp.Or(cmp.Compare(c.Score, h.score), cmp.Compare(h.id, c.ID+1))
+		})
+		hits = hits[start:]
+	} else {
+		hits = hits[min(query.Offset, len(hits)):]
+	}
+	hits = hits[:min(query.Limit, len(hits))]
+
+	terms := positiveTerms(clauses)
+	for _, h := range hits {
+		rec := s.pages[h.id]
+		result.Pages = append(result.Pages, &storage.Page{
+			ID:         h.id,
+			URL:        rec.Page.URL,
+			Title:      rec.Page.Title,
+			Lang:       rec.Page.Lang,
+			Snippet:    headline(rec.Page.MainText(), terms, query.Snippet),
+			Score:      h.score,
+			Duplicates: duplicates[h.id],
+		})
+	}
+	return result, nil
+}
+
+// hit - найденная страница и ее оценка.
+type hit struct {
+	id    int64
+	score float64
+}
+
+// collapse оставляет из каждой группы почти дубликатов в отсортированных
+// результатах одну страницу: каноническую, если она найдена, иначе самую
+// релевантную. Возвращает также число скрытых дубликатов каждой
+// оставшейся страницы.
+func (s *Store) collapse(hits []hit) ([]hit, map[int64]int) {
+	cluster := func(id int64) int64 {
+		if canonical := s.pages[id].Page.DuplicateOf; canonical != 0 {
+			return canonical
+		}
+		return id
+	}
+	matched := make(map[int64]bool, len(hits))
+	for _, h := range hits {
+		matched[h.id] = true
+	}
+
+	kept := make(map[int64]int64)
+	duplicates := make(map[int64]int)
+	for _, h := range hits {
+		c := cluster(h.id)
+		if _, ok := kept[c]; !ok && (c == h.id || !matched[c]) {
+			kept[c] = h.id
+		}
+	}
+	collapsed := hits[:0]
+	for _, h := range hits {
+		c := cluster(h.id)
+		if kept[c] == h.id {
+			collapsed = append(collapsed, h)
+		} else {
+			duplicates[kept[c]]++
+		}
+	}
+	return collapsed, duplicates
+}
+
+// score отбирает документы, удовлетворяющие запросу, и вычисляет их оценку:
+// BM25 по полям с весами s.weights, приведенный к [0, 1), плюс
+// авторитетность страницы. Встроенный индекс всегда ранжирует по BM25,
+// поэтому SearchQuery.Ranker не учитывается.
+func (s *Store) score(clauses []clause, query *storage.SearchQuery) map[int64]float64 {
+	idx := s.index
+	weights := s.fieldWeights()
+	k1, b := s.weights.BM25K1, s.weights.BM25B
+	n := float64(len(idx.docs))
+	if n == 0 {
+		return nil
+	}
+	var avgLen float64
+	for f, total := range idx.totals {
+		avgLen += weights[f] * float64(total)
+	}
+	avgLen = max(avgLen/n, 1)
+
+	// Документ должен удовлетворять всем обычным условиям; оценки фраз
+	// складываются. Исключающие условия применяются после.
+	var bm25 map[int64]float64
+	for _, c := range clauses {
+		if c.negated {
+			continue
+		}
+		matched := make(map[int64]float64)
+		for _, p := range c.phrases {
+			hits := idx.match(p)
+			df := float64(len(hits))
+			idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+			for id, tf := range hits {
+				var wtf, docLen float64
+				for f := range numFields {
+					wtf += weights[f] * float64(tf[f])
+					docLen += weights[f] * float64(idx.docs[id].lengths[f])
+				}
+				matched[id] += idf * wtf * (k1 + 1) / (wtf + k1*(1-b+b*docLen/avgLen))
+			}
+		}
+		if bm25 == nil {
+			bm25 = matched
+			continue
+		}
+		for id := range bm25 {
+			if m, ok := matched[id]; ok {
+				bm25[id] += m
+			} else {
+				delete(bm25, id)
+			}
+		}
+	}
+	for _, c := range clauses {
+		if !c.negated {
+			continue
+		}
+		for _, p := range c.phrases {
+			for id := range idx.match(p) {
+				delete(bm25, id)
+			}
+		}
+	}
+
+	scores := make(map[int64]float64, len(bm25))
+	for id, score := range bm25 {
+		rec := s.pages[id]
+		if !matchesFilter(&rec.Page, query.Lang, &query.Filter) {
+			continue
+		}
+		scores[id] = s.weights.Text*score/(score+1) + s.weights.Authority*rec.Authority
+	}
+	return scores
+}
+
+// matchesFilter проверяет, что страница на языке lang (если он задан) и
+// удовлетворяет фильтру f.
+func matchesFilter(page *storage.Page, lang string, f *storage.SearchFilter) bool {
+	if lang != "" && page.Lang != lang {
+		return false
+	}
+	if f.Site != "" || f.PathPrefix != "" {
+		u, err := url.Parse(page.URL)
+		if err != nil {
+			return false
+		}
+		host := u.Hostname()
+		if f.Site != "" && host != f.Site && !strings.HasSuffix(host, "."+f.Site) {
+			return false
+		}
+		path := u.EscapedPath()
+		if path == "" {
+			path = "/"
+		}
+		if !strings.HasPrefix(path, f.PathPrefix) {
+			return false
+		}
+	}
+	if !f.CrawledAfter.IsZero() && page.CrawledAt.Before(f.CrawledAfter) {
+		return false
+	}
+	if !f.CrawledBefore.IsZero() && !page.CrawledAt.Before(f.CrawledBefore) {
+		return false
+	}
+	if f.ContentType != "" {
+		mediaType, _, _ := strings.Cut(page.ContentType, ";")
+		if strings.ToLower(strings.TrimSpace(mediaType)) != f.ContentType {
+			return false
+		}
+	}
+	return true
+}
+
+// fieldWeights возвращает веса полей, отнесенные к наибольшему из них.
+func (s *Store) fieldWeights() [numFields]float64 {
+	w := [numFields]float64{s.weights.Title, s.weights.Headings, s.weights.Anchors, s.weights.Body}
+	top := slices.Max(w[:])
+	if top == 0 {
+		return [numFields]float64{1, 1, 1, 1}
+	}
+	for f := range w {
+		w[f] /= top
+	}
+	return w
+}
+
+// positiveTerms возвращает термы запроса, которые выделяются в сниппете.
+func positiveTerms(clauses []clause) map[string]bool {
+	terms := make(map[string]bool)
+	for _, c := range clauses {
+		if c.negated {
+			continue
+		}
+		for _, p := range c.phrases {
+			for _, term := range p {
+				terms[term] = true
+			}
+		}
+	}
+	return terms
+}
+
+// headline строит сниппет по правилам ts_headline: до MaxFragments
+// фрагментов по MaxWords слов вокруг слов запроса, при MaxFragments = 0 -
+// один фрагмент с наибольшим числом совпадений.
+func headline(text string, terms map[string]bool, o storage.SnippetOptions) string {
+	tokens := analysis.Tokenize(text)
+	if len(tokens) == 0 || o.MaxWords <= 0 {
+		return ""
+	}
+	var matches []int
+	matched := make([]bool, len(tokens))
+	for i, t := range tokens {
+		if terms[analysis.Term(t.Text)] {
+			matches = append(matches, i)
+			matched[i] = true
+		}
+	}
+
+	type fragment struct{ start, end int }
+	var fragments []fragment
+	switch {
+	case len(matches) == 0:
+		fragments = []fragment{{0, min(o.MaxWords, len(tokens))}}
+	case o.MaxFragments == 0:
+		best, bestCount := 0, -1
+		for _, m := range matches {
+			start := max(0, min(m, len(tokens)-o.MaxWords))
+			count := 0
+			for _, other := range matches {
+				if other >= start && other < start+o.MaxWords {
+					count++
+				}
+			}
+			if count > bestCount {
+				best, bestCount = start, count
+			}
+		}
+		fragments = []fragment{{best, min(best+o.MaxWords, len(tokens))}}
+	default:
+		end := 0
+		for _, m := range matches {
+			if m < end || len(fragments) == o.MaxFragments {
+				continue
+			}
+			start := max(end, m-o.MaxWords/2)
+			end = min(start+o.MaxWords, len(tokens))
+			fragments = append(fragments, fragment{start, end})
+		}
+	}
+
+	var b strings.Builder
+	for i, f := range fragments {
+		if i > 0 {
+			b.WriteString(o.FragmentDelimiter)
+		}
+		for j := f.start; j < f.end; j++ {
+			if j > f.start {
+				b.WriteString(text[tokens[j-1].End:tokens[j].Start])
+			}
+			if matched[j] {
+				b.WriteString(o.StartSel + tokens[j].Text + o.StopSel)
+			} else {
+				b.WriteString(tokens[j].Text)
+			}
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,107 @@
+package memory
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"cis-engine/internal/storage"
+)
+
+// snapshot - сохраняемое состояние хранилища. Индекс в снимок не входит и
+// перестраивается при загрузке.
+type snapshot struct {
+	NextPageID     int64
+	NextFrontierID int64
+	Pages          []*pageRecord
+	Aliases        map[string]aliasRecord
+	Links          map[int64][]storage.Link
+	Frontier       []*frontierRecord
+	QueryLog       []storage.QueryLogEntry
+}
+
+// Save записывает снимок в файл, заданный при Open. Файл заменяется
+// целиком, поэтому прерванная запись не портит предыдущий снимок.
+func (s *Store) Save() error {
+	if s.path == "" {
+		return nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	snap := snapshot{
+		NextPageID:     s.nextPageID,
+		NextFrontierID: s.nextFrontierID,
+		Aliases:        s.aliases,
+		Links:          s.links,
+		QueryLog:       s.queryLog,
+	}
+	for _, rec := range s.pages {
+		snap.Pages = append(snap.Pages, rec)
+	}
+	for _, rec := range s.frontier {
+		snap.Frontier = append(snap.Frontier, rec)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return fmt.Errorf("ошибка создания снимка: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if err := gob.NewEncoder(tmp).Encode(&snap); err != nil {
+		tmp.Close()
+		return fmt.Errorf("ошибка записи снимка: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("ошибка записи снимка: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("ошибка сохранения снимка: %w", err)
+	}
+	return nil
+}
+
+// load загружает снимок, если файл существует.
+func (s *Store) load() error {
+	f, err := os.Open(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка открытия снимка: %w", err)
+	}
+	defer f.Close()
+
+	var snap snapshot
+	if err := gob.NewDecoder(f).Decode(&snap); err != nil {
+		return fmt.Errorf("ошибка чтения снимка %s: %w", s.path, err)
+	}
+
+	s.nextPageID, s.nextFrontierID = snap.NextPageID, snap.NextFrontierID
+	for u, alias := range snap.Aliases {
+		s.setAlias(u, alias)
+	}
+	for id, links := range snap.Links {
+		s.storeLinks(id, links)
+	}
+	s.queryLog = snap.QueryLog
+	for _, rec := range snap.Pages {
+		s.pages[rec.Page.ID] = rec
+		s.byURL[rec.Page.URL] = rec.Page.ID
+	}
+	for _, rec := range snap.Frontier {
+		s.frontier[rec.ID] = rec
+		s.queue[rec.URL] = rec.ID
+	}
+	for id, rec := range s.pages {
+		if rec.Indexed {
+			s.indexPage(id)
+		} else {
+			s.pending[id] = true
+		}
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"cis-engine/internal/analysis"
+	"cis-engine/internal/storage"
+)
+
+// Слова для автодополнения: не короче minWordLength букв и встречаются
+// хотя бы на minWordDocs страницах, как и в postgres.
+const (
+	minWordDocs   = 2
+	minWordLength = 3
+)
+
+func (s *Store) GetSuggestionSources(ctx context.Context, limit int) (*storage.SuggestionSources, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	src := &storage.SuggestionSources{}
+	var titled []*pageRecord
+	docs := make(map[string]int)
+	for _, rec := range s.pages {
+		if !rec.Indexed {
+			continue
+		}
+		if rec.Page.Title != "" {
+			titled = append(titled, rec)
+		}
+		words := make(map[string]bool)
+		for _, t := range analysis.Tokenize(rec.Page.Title + " " + rec.Page.MainText()) {
+			word := strings.ToLower(t.Text)
+			if utf8.RuneCountInString(word) >= minWordLength && strings.ContainsFunc(word, unicode.IsLetter) {
+				words[word] = true
+			}
+		}
+		for word := range words {
+			docs[word]++
+		}
+	}
+
+	slices.SortFunc(titled, func(a, b *pageRecord) int {
+		return cmp.Or(cmp.Compare(b.Authority, a.Authority), cmp.Compare(a.Page.ID, b.Page.ID))
+	})
+	for _, rec := range titled[:min(limit, len(titled))] {
+		src.Titles = append(src.Titles, storage.WeightedText{Text: rec.Page.Title, Weight: rec.Authority})
+	}
+
+	for word, n := range docs {
+		if n >= minWordDocs {
+			src.Terms = append(src.Terms, storage.WeightedText{Text: word, Weight: float64(n)})
+		}
+	}
+	slices.SortFunc(src.Terms, func(a, b storage.WeightedText) int {
+		return cmp.Or(cmp.Compare(b.Weight, a.Weight), cmp.Compare(a.Text, b.Text))
+	})
+	src.Terms = src.Terms[:min(limit, len(src.Terms))]
+	return src, nil
+}
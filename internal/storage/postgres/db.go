@@ -2,8 +2,10 @@ package postgres
 
 import (
 	"cis-engine/internal/storage"
+	"cis-engine/internal/urlnorm"
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/jackc/pgx/v5"
@@ -11,11 +13,14 @@ import (
 )
 
 type DB struct {
-	pool *pgxpool.Pool
+	pool    *pgxpool.Pool
+	weights storage.RankWeights
 }
 
 var _ storage.Storer = (*DB)(nil)
 
+// New подключается к базе данных и применяет к ней схему, обновляя
+// таблицы, созданные прежними версиями.
 func New(ctx context.Context, connString string) (*DB, error) {
 	pool, err := pgxpool.New(ctx, connString)
 	if err != nil {
@@ -25,95 +30,512 @@ func New(ctx context.Context, connString string) (*DB, error) {
 		pool.Close()
 		return nil, fmt.Errorf("не удалось подключиться к базе данных: %w", err)
 	}
-	return &DB{pool: pool}, nil
+	db := &DB{pool: pool, weights: storage.DefaultRankWeights()}
+	if err := db.migrate(ctx); err != nil {
+		pool.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// SetRankWeights задает веса, с которыми ранжирует SearchPages. Вызывается
+// до начала обработки запросов.
+func (db *DB) SetRankWeights(w storage.RankWeights) {
+	db.weights = w
 }
 
 func (db *DB) Close() {
 	db.pool.Close()
 }
 
+// normalizer выполняет только синтаксическую нормализацию, чтобы не
+// переопределять правила, с которыми URL нормализовал краулер.
+var normalizer urlnorm.Options
+
 func (db *DB) StorePage(ctx context.Context, page *storage.Page) (int64, error) {
+	pageURL, err := normalizer.Normalize(page.URL)
+	if err != nil {
+		return 0, fmt.Errorf("некорректный URL страницы %s: %w", page.URL, err)
+	}
+
 	query := `
-		INSERT INTO pages (url, html_content, title, last_crawled_at)
-		VALUES ($1, $2, $3, $4)
+		INSERT INTO pages (
+			url, title, body_text, description, lang, headings, final_url, status_code,
+			content_type, content_length, fetch_duration_ms, headers, last_crawled_at, ts_config, content_hash, simhash, main_text
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14::text::regconfig, $15, $16, $17)
 		ON CONFLICT (url) DO UPDATE
-		SET html_content = EXCLUDED.html_content,
-			title = EXCLUDED.title,
-		    last_crawled_at = EXCLUDED.last_crawled_at,
-			content_tsvector = NULL
+		SET title = EXCLUDED.title,
+			body_text = EXCLUDED.body_text,
+			main_text = EXCLUDED.main_text,
+			description = EXCLUDED.description,
+			lang = EXCLUDED.lang,
+			headings = EXCLUDED.headings,
+			final_url = EXCLUDED.final_url,
+			status_code = EXCLUDED.status_code,
+			content_type = EXCLUDED.content_type,
+			content_length = EXCLUDED.content_length,
+			fetch_duration_ms = EXCLUDED.fetch_duration_ms,
+			headers = EXCLUDED.headers,
+			ts_config = EXCLUDED.ts_config,
+			last_crawled_at = EXCLUDED.last_crawled_at,
+			-- Неизменное тело не требует переиндексации.
+			content_tsvector = CASE
+				WHEN EXCLUDED.content_hash <> '' AND pages.content_hash = EXCLUDED.content_hash
+					AND pages.ts_config = EXCLUDED.ts_config THEN pages.content_tsvector
+				ELSE NULL END,
+			content_hash = EXCLUDED.content_hash,
+			-- Изменившийся текст группируется заново при следующем пересчете.
+			duplicate_of = CASE WHEN pages.simhash = EXCLUDED.simhash THEN pages.duplicate_of ELSE NULL END,
+			simhash = EXCLUDED.simhash
 		RETURNING id
 	`
 	var pageID int64
-	err := db.pool.QueryRow(ctx, query, page.URL, page.Body, page.Title, time.Now()).Scan(&pageID)
+	err = pgx.BeginFunc(ctx, db.pool, func(tx pgx.Tx) error {
+		err := tx.QueryRow(ctx, query,
+			pageURL, page.Title, page.Body, page.Description, page.Lang, page.Headings,
+			nullIfEmpty(page.FinalURL), nullIfZero(page.StatusCode), page.ContentType, page.ContentLength,
+			page.FetchDuration.Milliseconds(), page.Header, time.Now(), tsConfig(page.Lang), page.ContentHash,
+			int64(page.SimHash), page.Content,
+		).Scan(&pageID)
+		if err != nil {
+			return err
+		}
+		if err := storeRaw(ctx, tx, pageID, page); err != nil {
+			return err
+		}
+		if err := storeAliases(ctx, tx, pageID, pageURL, page.Aliases); err != nil {
+			return err
+		}
+		if err := storeLinks(ctx, tx, pageID, pageURL, page); err != nil {
+			return err
+		}
+		// Уведомление доставляется слушателям после фиксации транзакции.
+		_, err = tx.Exec(ctx, `SELECT pg_notify($1, '')`, pagesChannel)
+		return err
+	})
 	if err != nil {
 		return 0, fmt.Errorf("ошибка при сохранении страницы %s: %w", page.URL, err)
 	}
 	return pageID, nil
 }
 
-func (db *DB) GetNextPageToIndex(ctx context.Context) (*storage.Page, error) {
-	query := `SELECT id, url, title, html_content FROM pages WHERE content_tsvector IS NULL LIMIT 1`
-	var p storage.Page
-	err := db.pool.QueryRow(ctx, query).Scan(&p.ID, &p.URL, &p.Title, &p.Body)
-	if err != nil {
-		if err == pgx.ErrNoRows {
-			return nil, nil
+func storeRaw(ctx context.Context, tx pgx.Tx, pageID int64, page *storage.Page) error {
+	if page.RawBody == nil {
+		_, err := tx.Exec(ctx, `DELETE FROM page_raw WHERE page_id = $1`, pageID)
+		return err
+	}
+	_, err := tx.Exec(ctx, `
+		INSERT INTO page_raw (page_id, encoding, body)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (page_id) DO UPDATE
+		SET encoding = EXCLUDED.encoding, body = EXCLUDED.body
+	`, pageID, page.RawEncoding, page.RawBody)
+	return err
+}
+
+func nullIfEmpty(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func nullIfZero(n int) *int {
+	if n == 0 {
+		return nil
+	}
+	return &n
+}
+
+// storeAliases запоминает альтернативные адреса страницы и удаляет копии,
+// сохраненные ранее под этими адресами.
+func storeAliases(ctx context.Context, tx pgx.Tx, pageID int64, pageURL string, aliases []storage.URLAlias) error {
+	for _, alias := range aliases {
+		aliasURL, err := normalizer.Normalize(alias.URL)
+		if err != nil || aliasURL == pageURL {
+			continue
+		}
+		if _, err := tx.Exec(ctx, `DELETE FROM pages WHERE url = $1`, aliasURL); err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, `
+			INSERT INTO url_aliases (url, page_id, kind, updated_at)
+			VALUES ($1, $2, $3, NOW())
+			ON CONFLICT (url) DO UPDATE
+			SET page_id = EXCLUDED.page_id, kind = EXCLUDED.kind, updated_at = EXCLUDED.updated_at
+		`, aliasURL, pageID, alias.Kind)
+		if err != nil {
+			return err
 		}
-		return nil, fmt.Errorf("ошибка при получении страницы для индексации: %w", err)
 	}
-	return &p, nil
+	return nil
 }
 
-func (db *DB) UpdatePageVector(ctx context.Context, page *storage.Page) error {
+// IndexPages строит взвешенный tsvector страниц: A - заголовок, B -
+// заголовки h1–h3 и meta description, C - слова URL и тексты входящих
+// ссылок, D - текст страницы.
+func (db *DB) IndexPages(ctx context.Context, limit int) (int, error) {
 	query := `
-		UPDATE pages
-		SET content_tsvector = to_tsvector('russian', coalesce(title, '') || ' ' || coalesce(html_content, ''))
-		WHERE id = $1
+		WITH batch AS (
+			SELECT id
+			FROM pages
+			WHERE content_tsvector IS NULL
+			ORDER BY id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		),
+		vectors AS (
+			SELECT p.id,
+				setweight(to_tsvector(p.ts_config, coalesce(p.title, '')), 'A') ||
+				setweight(to_tsvector(p.ts_config, p.description || ' ' || coalesce((
+					SELECT string_agg(h->>'text', ' ')
+					FROM jsonb_array_elements(CASE WHEN jsonb_typeof(p.headings) = 'array' THEN p.headings END) AS h
+				), '')), 'B') ||
+				setweight(to_tsvector(p.ts_config, regexp_replace(p.url, '^[a-z]+://|[^[:alnum:]]+', ' ', 'g') || ' ' || coalesce((
+					SELECT string_agg(anchor_text, ' ')
+					FROM (
+						SELECT anchor_text FROM links
+						WHERE to_page_id = p.id AND from_page_id <> p.id AND anchor_text <> ''
+						LIMIT $2
+					) AS anchors
+				), '')), 'C') ||
+				setweight(to_tsvector(p.ts_config, ` + pageTextSQL + `), 'D') AS vector
+			FROM pages p
+			JOIN batch ON p.id = batch.id
+		)
+		UPDATE pages p
+		SET content_tsvector = v.vector,
+			doc_length = (SELECT coalesce(sum(cardinality(positions)), 0) FROM unnest(v.vector))
+		FROM vectors v
+		WHERE p.id = v.id
 	`
-	_, err := db.pool.Exec(ctx, query, page.ID)
+	tag, err := db.pool.Exec(ctx, query, limit, maxIndexedAnchors)
 	if err != nil {
-		return fmt.Errorf("ошибка при обновлении tsvector для страницы %d: %w", page.ID, err)
+		return 0, fmt.Errorf("ошибка при индексации страниц: %w", err)
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+// pageTextSQL - текст страницы p, по которому она индексируется: основной,
+// а если он не выделен - весь.
+const pageTextSQL = `coalesce(nullif(p.main_text, ''), p.body_text, '')`
+
+// maxIndexedAnchors ограничивает число текстов входящих ссылок в индексе страницы.
+const maxIndexedAnchors = 200
+
+// UpdateTermStats пересчитывает по всем проиндексированным страницам число
+// страниц с каждой лексемой и среднюю длину страницы для BM25.
+func (db *DB) UpdateTermStats(ctx context.Context) error {
+	err := pgx.BeginFunc(ctx, db.pool, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `DELETE FROM term_stats`)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, `
+			INSERT INTO term_stats (ts_config, lexeme, doc_count)
+			SELECT c.ts_config, s.word, s.ndoc
+			FROM (SELECT DISTINCT ts_config FROM pages WHERE content_tsvector IS NOT NULL) AS c,
+				LATERAL ts_stat(format(
+					'SELECT content_tsvector FROM pages WHERE content_tsvector IS NOT NULL AND ts_config = %L::regconfig',
+					c.ts_config
+				)) AS s
+		`)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(ctx, `DELETE FROM corpus_stats`)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, `
+			INSERT INTO corpus_stats (ts_config, doc_count, avg_length)
+			SELECT ts_config, COUNT(*), avg(doc_length)
+			FROM pages
+			WHERE content_tsvector IS NOT NULL
+			GROUP BY ts_config
+		`)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(ctx, `DELETE FROM word_stats`)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, `
+			INSERT INTO word_stats (word, doc_count)
+			SELECT word, ndoc
+			FROM ts_stat($$
+				SELECT to_tsvector('simple', coalesce(p.title, '') || ' ' || `+pageTextSQL+`)
+				FROM pages p
+				WHERE content_tsvector IS NOT NULL
+			$$)
+			WHERE ndoc >= $1 AND char_length(word) >= $2 AND word !~ '^[0-9]+$'
+		`, minWordDocs, minWordLength)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("ошибка при пересчете статистики термов: %w", err)
 	}
 	return nil
 }
 
-func (db *DB) SearchPages(ctx context.Context, query string) ([]*storage.Page, error) {
+// Слова для автодополнения: не короче minWordLength букв и встречаются
+// хотя бы на minWordDocs страницах.
+const (
+	minWordDocs   = 2
+	minWordLength = 3
+)
+
+func (db *DB) GetSuggestionSources(ctx context.Context, limit int) (*storage.SuggestionSources, error) {
+	src := &storage.SuggestionSources{}
+	rows, err := db.pool.Query(ctx, `
+		SELECT title, authority
+		FROM pages
+		WHERE content_tsvector IS NOT NULL AND coalesce(title, '') <> ''
+		ORDER BY authority DESC, id
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении заголовков для подсказок: %w", err)
+	}
+	src.Titles, err = pgx.CollectRows(rows, pgx.RowToStructByPos[storage.WeightedText])
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении заголовков для подсказок: %w", err)
+	}
+
+	rows, err = db.pool.Query(ctx, `
+		SELECT word, doc_count::float8
+		FROM word_stats
+		ORDER BY doc_count DESC, word
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении слов для подсказок: %w", err)
+	}
+	src.Terms, err = pgx.CollectRows(rows, pgx.RowToStructByPos[storage.WeightedText])
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении слов для подсказок: %w", err)
+	}
+	return src, nil
+}
+
+func (db *DB) SearchPages(ctx context.Context, query *storage.SearchQuery) (*storage.SearchResult, error) {
+	// Запрос разбирается в каждой конфигурации и сопоставляется со
+	// страницами на соответствующем языке. ts_headline дорогой, поэтому
+	// сниппеты строятся только для отобранных страниц.
+	filter, filterArgs := filterSQL(query.Lang, &query.Filter, 15)
 	sql := `
+		WITH q AS (
+			SELECT
+				cfg::regconfig AS cfg,
+				websearch_to_tsquery(cfg::regconfig, $1) AS query,
+				ARRAY(SELECT lexeme FROM unnest(to_tsvector(cfg::regconfig, $1))) AS lexemes,
+				coalesce(cs.doc_count, 0) AS doc_count,
+				cs.avg_length
+			FROM unnest($9::text[]) AS cfg
+			LEFT JOIN corpus_stats cs ON cs.ts_config = cfg::regconfig
+		),
+		matched AS (
+			SELECT p.id, q.query, $2 * (CASE WHEN $11::text = 'bm25' THEN (
+				-- BM25 приводится к [0, 1) так же, как ts_rank_cd с флагом 32.
+				-- Частота лексемы в каждом поле умножается на вес поля,
+				-- отнесенный к наибольшему из весов.
+				SELECT coalesce(score / (score + 1), 0) FROM (
+					SELECT sum(
+						ln(1 + (greatest(q.doc_count, df) - df + 0.5) / (df + 0.5)) *
+						tf * ($12::float8 + 1) /
+						(tf + $12::float8 * (1 - $13::float8 + $13::float8 * p.doc_length / greatest(coalesce(q.avg_length, p.doc_length), 1)))
+					) AS score
+					FROM (
+						SELECT
+							coalesce(ts.doc_count, 1)::float8 AS df,
+							(SELECT sum(($10::float4[])[4 - (ascii(w) - ascii('A'))]) FROM unnest(v.weights) AS w)::float8 /
+								nullif((SELECT max(x) FROM unnest($10::float4[]) AS x), 0) AS tf
+						FROM unnest(p.content_tsvector) AS v
+						LEFT JOIN term_stats ts ON ts.ts_config = q.cfg AND ts.lexeme = v.lexeme
+						WHERE v.lexeme = ANY(q.lexemes)
+					) AS terms
+				) AS bm25
+			) ELSE ts_rank_cd($10::float4[], p.content_tsvector, q.query, 32) END) + $3 * p.authority AS rank,
+				CASE WHEN $14 THEN p.id ELSE coalesce(p.duplicate_of, p.id) END AS cluster,
+				p.duplicate_of IS NULL AS canonical
+			FROM pages p
+			JOIN q ON p.ts_config = q.cfg
+			WHERE p.content_tsvector @@ q.query` + filter + `
+		),
+		-- Из группы почти дубликатов остается каноническая страница, а если
+		-- она не найдена - самая релевантная.
+		collapsed AS (
+			SELECT DISTINCT ON (cluster) id, query, rank, count(*) OVER (PARTITION BY cluster) - 1 AS duplicates
+			FROM matched
+			ORDER BY cluster, canonical DESC, rank DESC, id
+		),
+		hits AS (
+			SELECT id, query, rank, duplicates
+			FROM collapsed
+			WHERE $7::float8 IS NULL OR rank < $7 OR (rank = $7 AND id > $8)
+			ORDER BY rank DESC, id
+			LIMIT $5 OFFSET $6
+		)
 		SELECT
-			id,
-			url,
-			title,
-			ts_rank(content_tsvector, websearch_to_tsquery('russian', $1)) as rank
-		FROM pages
-		WHERE content_tsvector @@ websearch_to_tsquery('russian', $1)
-		ORDER BY rank DESC
-		LIMIT 20
+			p.id,
+			p.url,
+			p.title,
+			p.lang,
+			ts_headline(p.ts_config, ` + pageTextSQL + `, hits.query, $4),
+			hits.rank,
+			hits.duplicates,
+			(SELECT COUNT(*) FROM collapsed)
+		FROM hits
+		JOIN pages p ON p.id = hits.id
+		ORDER BY hits.rank DESC, hits.id
 	`
-	rows, err := db.pool.Query(ctx, sql, query)
+	configs := searchConfigs(query.Lang)
+	offset := query.Offset
+	var afterScore *float64
+	var afterID int64
+	if query.After != nil {
+		offset, afterScore, afterID = 0, &query.After.Score, query.After.ID
+	}
+
+	args := []any{
+		query.Text, db.weights.Text, db.weights.Authority, headlineOptions(query.Snippet),
+		query.Limit, offset, afterScore, afterID, configs,
+		[]float64{db.weights.Body, db.weights.Anchors, db.weights.Headings, db.weights.Title},
+		query.Ranker, db.weights.BM25K1, db.weights.BM25B, query.ShowDuplicates,
+	}
+	rows, err := db.pool.Query(ctx, sql, append(args, filterArgs...)...)
 	if err != nil {
 		return nil, fmt.Errorf("ошибка при выполнении полнотекстового поиска: %w", err)
 	}
 	defer rows.Close()
 
-	var pages []*storage.Page
+	result := &storage.SearchResult{}
 	for rows.Next() {
 		var p storage.Page
-		var rank float32
-		if err := rows.Scan(&p.ID, &p.URL, &p.Title, &rank); err != nil {
+		if err := rows.Scan(&p.ID, &p.URL, &p.Title, &p.Lang, &p.Snippet, &p.Score, &p.Duplicates, &result.Total); err != nil {
 			return nil, fmt.Errorf("ошибка при сканировании результата поиска: %w", err)
 		}
-		pages = append(pages, &p)
+		result.Pages = append(result.Pages, &p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при выполнении полнотекстового поиска: %w", err)
+	}
+
+	// Запрос за пределами выдачи не возвращает строк, и общее число
+	// найденных страниц приходится считать отдельно.
+	if len(result.Pages) == 0 && (offset > 0 || query.After != nil) {
+		filter, filterArgs := filterSQL(query.Lang, &query.Filter, 4)
+		err := db.pool.QueryRow(ctx, `
+			SELECT COUNT(DISTINCT CASE WHEN $3 THEN p.id ELSE coalesce(p.duplicate_of, p.id) END)
+			FROM pages p
+			JOIN unnest($2::text[]) AS cfg ON p.ts_config = cfg::regconfig
+			WHERE p.content_tsvector @@ websearch_to_tsquery(cfg::regconfig, $1)`+filter,
+			append([]any{query.Text, configs, query.ShowDuplicates}, filterArgs...)...,
+		).Scan(&result.Total)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка при подсчете результатов поиска: %w", err)
+		}
+	}
+
+	return result, nil
+}
+
+// Хост и путь страницы выделяются из URL. Адреса в pages нормализованы,
+// поэтому схема и хост записаны в нижнем регистре.
+const (
+	pageHostSQL = `substring(p.url from '^[a-z]+://([^/?#:]+)')`
+	pagePathSQL = `coalesce(substring(p.url from '^[a-z]+://[^/?#]*(/[^?#]*)'), '/')`
+)
+
+// filterSQL возвращает условия на язык и SearchFilter для страниц p и их
+// параметры, нумерация которых начинается с n.
+func filterSQL(lang string, f *storage.SearchFilter, n int) (string, []any) {
+	var sql strings.Builder
+	var args []any
+	add := func(cond string, arg any) {
+		fmt.Fprintf(&sql, "\n\t\t\t\tAND "+cond, n+len(args))
+		args = append(args, arg)
 	}
 
-	return pages, rows.Err()
+	if lang != "" {
+		add("p.lang = $%d", lang)
+	}
+	if f.Site != "" {
+		add("("+pageHostSQL+" = $%[1]d::text OR right("+pageHostSQL+", length($%[1]d) + 1) = '.' || $%[1]d)", f.Site)
+	}
+	if f.PathPrefix != "" {
+		add("starts_with("+pagePathSQL+", $%d)", f.PathPrefix)
+	}
+	if !f.CrawledAfter.IsZero() {
+		add("p.last_crawled_at >= $%d", f.CrawledAfter)
+	}
+	if !f.CrawledBefore.IsZero() {
+		add("p.last_crawled_at < $%d", f.CrawledBefore)
+	}
+	if f.ContentType != "" {
+		add("lower(trim(split_part(p.content_type, ';', 1))) = $%d", f.ContentType)
+	}
+	return sql.String(), args
+}
+
+// headlineOptions переводит параметры сниппета в строку опций ts_headline.
+func headlineOptions(o storage.SnippetOptions) string {
+	return fmt.Sprintf(`MaxFragments=%d, MaxWords=%d, MinWords=%d, StartSel="%s", StopSel="%s", FragmentDelimiter="%s"`,
+		o.MaxFragments, o.MaxWords, o.MinWords, o.StartSel, o.StopSel, o.FragmentDelimiter)
+}
+
+func (db *DB) GetPage(ctx context.Context, id int64) (*storage.Page, error) {
+	query := `
+		SELECT
+			p.id, p.url, coalesce(p.title, ''), coalesce(p.body_text, ''), p.main_text, p.description, p.lang,
+			p.headings, coalesce(p.final_url, ''), coalesce(p.status_code, 0), p.content_type,
+			p.content_length, p.fetch_duration_ms, p.headers, p.last_crawled_at,
+			p.content_hash, p.simhash, coalesce(p.duplicate_of, 0),
+			coalesce(r.encoding, ''), r.body
+		FROM pages p
+		LEFT JOIN page_raw r ON r.page_id = p.id
+		WHERE p.id = $1
+	`
+	var p storage.Page
+	var durationMs int64
+	var crawledAt *time.Time
+	var simhash int64
+	err := db.pool.QueryRow(ctx, query, id).Scan(
+		&p.ID, &p.URL, &p.Title, &p.Body, &p.Content, &p.Description, &p.Lang,
+		&p.Headings, &p.FinalURL, &p.StatusCode, &p.ContentType,
+		&p.ContentLength, &durationMs, &p.Header, &crawledAt,
+		&p.ContentHash, &simhash, &p.DuplicateOf,
+		&p.RawEncoding, &p.RawBody,
+	)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, storage.ErrNotFound
+		}
+		return nil, fmt.Errorf("ошибка при получении страницы %d: %w", id, err)
+	}
+	p.FetchDuration = time.Duration(durationMs) * time.Millisecond
+	p.SimHash = uint64(simhash)
+	if crawledAt != nil {
+		p.CrawledAt = *crawledAt
+	}
+	return &p, nil
 }
 
 func (db *DB) GetMetrics(ctx context.Context) (*storage.Metrics, error) {
-	var count int64
-	query := "SELECT COUNT(*) FROM pages"
-	err := db.pool.QueryRow(ctx, query).Scan(&count)
+	var m storage.Metrics
+	query := `
+		SELECT
+			(SELECT COUNT(*) FROM pages),
+			(SELECT COUNT(*) FROM frontier),
+			(SELECT COUNT(*) FROM frontier WHERE next_fetch_at <= NOW())
+	`
+	err := db.pool.QueryRow(ctx, query).Scan(&m.PagesCount, &m.FrontierCount, &m.FrontierDue)
 	if err != nil {
-		return nil, fmt.Errorf("ошибка при получении количества страниц: %w", err)
+		return nil, fmt.Errorf("ошибка при получении метрик: %w", err)
 	}
-	return &storage.Metrics{PagesCount: count}, nil
+	return &m, nil
 }
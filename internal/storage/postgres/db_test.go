@@ -5,6 +5,7 @@ package postgres
 import (
 	"context"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -49,6 +50,16 @@ func setupTestDB(t *testing.T) *DB {
 	return db
 }
 
+func storeAndIndex(t *testing.T, db *DB, pages ...*storage.Page) {
+	ctx := context.Background()
+	for _, p := range pages {
+		_, err := db.StorePage(ctx, p)
+		require.NoError(t, err)
+	}
+	_, err := db.IndexPages(ctx, len(pages))
+	require.NoError(t, err)
+}
+
 func TestStorePage(t *testing.T) {
 	db := setupTestDB(t)
 	ctx := context.Background()
@@ -65,40 +76,130 @@ func TestStorePage(t *testing.T) {
 	require.Greater(t, id, int64(0))
 }
 
+func TestGetPage(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+
+	page := &storage.Page{
+		URL:           "https://example.com/",
+		Title:         "Example",
+		Body:          "Menu Example text",
+		Content:       "Example text",
+		Description:   "Example description",
+		Lang:          "en",
+		Headings:      []storage.Heading{{Level: 1, Text: "Example"}},
+		FinalURL:      "https://example.com/",
+		StatusCode:    200,
+		ContentType:   "text/html; charset=utf-8",
+		FetchDuration: 150 * time.Millisecond,
+		Header:        map[string][]string{"Content-Type": {"text/html; charset=utf-8"}},
+	}
+	require.NoError(t, page.SetRawBody([]byte("<html><title>Example</title></html>"), true))
+
+	id, err := db.StorePage(ctx, page)
+	require.NoError(t, err)
+
+	got, err := db.GetPage(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, page.Content, got.Content)
+	require.Equal(t, page.Description, got.Description)
+	require.Equal(t, page.Headings, got.Headings)
+	require.Equal(t, page.StatusCode, got.StatusCode)
+	require.Equal(t, page.FetchDuration, got.FetchDuration)
+	require.Equal(t, page.Header, got.Header)
+	raw, err := got.Raw()
+	require.NoError(t, err)
+	require.Equal(t, "<html><title>Example</title></html>", string(raw))
+
+	_, err = db.GetPage(ctx, 9999)
+	require.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func TestStorePageAliases(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+
+	oldID, err := db.StorePage(ctx, &storage.Page{URL: "http://Example.com:80/old", Title: "Old copy"})
+	require.NoError(t, err)
+
+	newID, err := db.StorePage(ctx, &storage.Page{
+		URL:     "https://example.com/new",
+		Title:   "New",
+		Aliases: []storage.URLAlias{{URL: "http://example.com/old", Kind: storage.AliasRedirect}},
+	})
+	require.NoError(t, err)
+	require.NotEqual(t, oldID, newID)
+
+	metrics, err := db.GetMetrics(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), metrics.PagesCount, "копия под старым адресом должна быть удалена")
+}
+
+func TestLinkGraph(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+
+	homeID, err := db.StorePage(ctx, &storage.Page{
+		URL:   "https://example.com/",
+		Title: "Home",
+		Links: []storage.Link{
+			{ToURL: "https://example.com/about", AnchorText: "About us"},
+			{ToURL: "https://ads.example.net/", Rel: "nofollow", NoFollow: true},
+		},
+	})
+	require.NoError(t, err)
+
+	aboutID, err := db.StorePage(ctx, &storage.Page{URL: "https://example.com/about", Title: "About"})
+	require.NoError(t, err)
+
+	outbound, err := db.GetOutboundLinks(ctx, homeID, 10)
+	require.NoError(t, err)
+	require.Len(t, outbound, 2)
+
+	inbound, err := db.GetInboundLinks(ctx, aboutID, 10)
+	require.NoError(t, err)
+	require.Len(t, inbound, 1)
+	require.Equal(t, homeID, inbound[0].FromPageID)
+	require.Equal(t, "About us", inbound[0].AnchorText)
+
+	_, err = db.GetInboundLinks(ctx, 9999, 10)
+	require.ErrorIs(t, err, storage.ErrNotFound)
+}
+
 func TestIndexingAndSearchWorkflow(t *testing.T) {
 	db := setupTestDB(t)
 	ctx := context.Background()
 
 	pagesToStore := []*storage.Page{
-		{URL: "https://golang.org", Title: "Go Language", Body: "Go is an open source programming language that makes it easy to build simple, reliable, and efficient software."},
-		{URL: "https://vuejs.org", Title: "Vue.js", Body: "Vue.js is a progressive, incrementally-adoptable JavaScript framework for building UI on the web."},
-		{URL: "https://gobyexample.com", Title: "Go by Example", Body: "A hands-on introduction to Go using annotated example programs. A great resource for learning Go."},
+		{URL: "https://golang.org", Lang: "en", Title: "Go Language", Body: "Go is an open source programming language that makes it easy to build simple, reliable, and efficient software."},
+		{URL: "https://vuejs.org", Lang: "en", Title: "Vue.js", Body: "Vue.js is a progressive, incrementally-adoptable JavaScript framework for building UI on the web."},
+		{URL: "https://gobyexample.com", Lang: "en", Title: "Go by Example", Body: "A hands-on introduction to Go using annotated example programs. A great resource for learning Go."},
 	}
 	for _, p := range pagesToStore {
 		_, err := db.StorePage(ctx, p)
 		require.NoError(t, err)
 	}
 
-	for {
-		page, err := db.GetNextPageToIndex(ctx)
-		require.NoError(t, err)
-		if page == nil {
-			break
-		}
-		err = db.UpdatePageVector(ctx, page)
+	indexed, err := db.IndexPages(ctx, 10)
+	require.NoError(t, err)
+	require.Equal(t, len(pagesToStore), indexed)
+
+	search := func(text string) []*storage.Page {
+		result, err := db.SearchPages(ctx, &storage.SearchQuery{Text: text, Snippet: storage.DefaultSnippetOptions(), Limit: 20})
 		require.NoError(t, err)
+		require.Equal(t, int64(len(result.Pages)), result.Total)
+		return result.Pages
 	}
 
 	t.Run("Поиск по уникальному слову 'framework'", func(t *testing.T) {
-		results, err := db.SearchPages(ctx, "framework")
-		require.NoError(t, err)
+		results := search("framework")
 		require.Len(t, results, 1)
 		require.Equal(t, "https://vuejs.org", results[0].URL)
+		require.Contains(t, results[0].Snippet, "<mark>framework</mark>")
 	})
 
 	t.Run("Поиск по общему слову 'go'", func(t *testing.T) {
-		results, err := db.SearchPages(ctx, "go")
-		require.NoError(t, err)
+		results := search("go")
 		require.Len(t, results, 2)
 		foundURLs := []string{results[0].URL, results[1].URL}
 		require.Contains(t, foundURLs, "https://golang.org")
@@ -106,8 +207,460 @@ func TestIndexingAndSearchWorkflow(t *testing.T) {
 	})
 
 	t.Run("Поиск по несуществующему слову", func(t *testing.T) {
-		results, err := db.SearchPages(ctx, "nonexistentword")
-		require.NoError(t, err)
+		results := search("nonexistentword")
 		require.Len(t, results, 0)
 	})
+
+	t.Run("Стемминг по языку страницы", func(t *testing.T) {
+		results := search("programs")
+		require.Len(t, results, 2)
+		require.Equal(t, "en", results[0].Lang)
+
+		result, err := db.SearchPages(ctx, &storage.SearchQuery{Text: "go", Lang: "ru", Snippet: storage.DefaultSnippetOptions(), Limit: 20})
+		require.NoError(t, err)
+		require.Empty(t, result.Pages)
+	})
+
+	t.Run("Фильтры", func(t *testing.T) {
+		result, err := db.SearchPages(ctx, &storage.SearchQuery{
+			Text:    "go",
+			Filter:  storage.SearchFilter{Site: "gobyexample.com", CrawledAfter: time.Now().Add(-time.Hour)},
+			Snippet: storage.DefaultSnippetOptions(),
+			Limit:   20,
+		})
+		require.NoError(t, err)
+		require.Len(t, result.Pages, 1)
+		require.Equal(t, "https://gobyexample.com/", result.Pages[0].URL)
+
+		result, err = db.SearchPages(ctx, &storage.SearchQuery{
+			Text:    "go",
+			Filter:  storage.SearchFilter{PathPrefix: "/doc/"},
+			Snippet: storage.DefaultSnippetOptions(),
+			Limit:   20,
+		})
+		require.NoError(t, err)
+		require.Empty(t, result.Pages)
+	})
+
+	t.Run("Постраничная выдача", func(t *testing.T) {
+		query := &storage.SearchQuery{Text: "go", Snippet: storage.DefaultSnippetOptions(), Limit: 1}
+		first, err := db.SearchPages(ctx, query)
+		require.NoError(t, err)
+		require.Len(t, first.Pages, 1)
+		require.Equal(t, int64(2), first.Total)
+
+		last := first.Pages[0]
+		query.After = &storage.SearchCursor{Score: last.Score, ID: last.ID}
+		second, err := db.SearchPages(ctx, query)
+		require.NoError(t, err)
+		require.Len(t, second.Pages, 1)
+		require.NotEqual(t, last.URL, second.Pages[0].URL)
+
+		query.After, query.Offset = nil, 5
+		empty, err := db.SearchPages(ctx, query)
+		require.NoError(t, err)
+		require.Empty(t, empty.Pages)
+		require.Equal(t, int64(2), empty.Total)
+	})
+}
+
+func TestIndexPagesBatches(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	events, err := db.WatchPages(watchCtx)
+	require.NoError(t, err)
+
+	for _, u := range []string{"https://example.com/1", "https://example.com/2", "https://example.com/3"} {
+		_, err := db.StorePage(ctx, &storage.Page{URL: u, Title: "Page"})
+		require.NoError(t, err)
+	}
+
+	select {
+	case <-events:
+	case <-time.After(5 * time.Second):
+		t.Fatal("не получено уведомление о сохранении страницы")
+	}
+
+	n, err := db.IndexPages(ctx, 2)
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+	n, err = db.IndexPages(ctx, 2)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	n, err = db.IndexPages(ctx, 2)
+	require.NoError(t, err)
+	require.Equal(t, 0, n)
+
+	cancel()
+	for range events {
+	}
+}
+
+func TestStorePageUnchanged(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+
+	page := &storage.Page{URL: "https://example.com/", Title: "Same", ContentHash: "abc"}
+	_, err := db.StorePage(ctx, page)
+	require.NoError(t, err)
+	n, err := db.IndexPages(ctx, 10)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+
+	_, err = db.StorePage(ctx, page)
+	require.NoError(t, err)
+	n, err = db.IndexPages(ctx, 10)
+	require.NoError(t, err)
+	require.Zero(t, n, "неизменное тело не переиндексируется")
+
+	page.ContentHash = "def"
+	_, err = db.StorePage(ctx, page)
+	require.NoError(t, err)
+	n, err = db.IndexPages(ctx, 10)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+}
+
+func TestMainText(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+	storeAndIndex(t, db, &storage.Page{
+		URL:     "https://example.com/post",
+		Title:   "Post",
+		Body:    "Home Login Post Gophers are friendly",
+		Content: "Gophers are friendly",
+	})
+
+	result, err := db.SearchPages(ctx, &storage.SearchQuery{Text: "login", Limit: 10})
+	require.NoError(t, err)
+	require.Empty(t, result.Pages, "служебные блоки не индексируются")
+
+	result, err = db.SearchPages(ctx, &storage.SearchQuery{Text: "gophers", Snippet: storage.DefaultSnippetOptions(), Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, result.Pages, 1)
+	require.NotContains(t, result.Pages[0].Snippet, "Login")
+}
+
+func TestDuplicates(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+
+	storeAndIndex(t, db,
+		&storage.Page{URL: "https://example.com/docs", Title: "Go docs", SimHash: 0b1011},
+		&storage.Page{URL: "https://mirror.example/docs", Title: "Go docs mirror", SimHash: 0b1010},
+		&storage.Page{URL: "https://example.com/print/docs", Title: "Go docs", SimHash: 0b1001},
+		&storage.Page{URL: "https://example.com/blog", Title: "Go blog"},
+	)
+	fingerprints, err := db.GetFingerprints(ctx)
+	require.NoError(t, err)
+	require.Len(t, fingerprints, 3)
+	canonical := fingerprints[0].ID
+	require.NoError(t, db.UpdateDuplicates(ctx, map[int64]int64{fingerprints[1].ID: canonical, fingerprints[2].ID: canonical}))
+
+	result, err := db.SearchPages(ctx, &storage.SearchQuery{Text: "go", Limit: 10})
+	require.NoError(t, err)
+	require.Equal(t, int64(2), result.Total)
+	require.Len(t, result.Pages, 2)
+	for _, p := range result.Pages {
+		if p.ID == canonical {
+			require.Equal(t, 2, p.Duplicates)
+		}
+	}
+
+	t.Run("Каноническая страница не найдена", func(t *testing.T) {
+		result, err := db.SearchPages(ctx, &storage.SearchQuery{Text: "mirror", Limit: 10})
+		require.NoError(t, err)
+		require.Len(t, result.Pages, 1)
+		require.Equal(t, "https://mirror.example/docs", result.Pages[0].URL)
+	})
+
+	t.Run("Без схлопывания", func(t *testing.T) {
+		result, err := db.SearchPages(ctx, &storage.SearchQuery{Text: "go", Limit: 10, ShowDuplicates: true})
+		require.NoError(t, err)
+		require.Equal(t, int64(4), result.Total)
+	})
+
+	t.Run("Изменившаяся страница выходит из группы", func(t *testing.T) {
+		storeAndIndex(t, db, &storage.Page{URL: "https://mirror.example/docs", Title: "Go docs mirror", SimHash: 0xff00})
+		result, err := db.SearchPages(ctx, &storage.SearchQuery{Text: "go", Limit: 10})
+		require.NoError(t, err)
+		require.Equal(t, int64(3), result.Total)
+	})
+}
+
+func TestWeightedRanking(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+
+	pagesToStore := []*storage.Page{
+		{URL: "https://example.com/body", Lang: "en", Title: "Notes", Body: "Somewhere in the footer a gopher is mentioned."},
+		{URL: "https://example.com/heading", Lang: "en", Title: "Notes", Headings: []storage.Heading{{Level: 2, Text: "Gopher"}}},
+		{URL: "https://example.com/title", Lang: "en", Title: "Gopher"},
+	}
+	for _, p := range pagesToStore {
+		_, err := db.StorePage(ctx, p)
+		require.NoError(t, err)
+	}
+	_, err := db.IndexPages(ctx, 10)
+	require.NoError(t, err)
+
+	result, err := db.SearchPages(ctx, &storage.SearchQuery{Text: "gopher", Snippet: storage.DefaultSnippetOptions(), Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, result.Pages, 3)
+	require.Equal(t, "https://example.com/title", result.Pages[0].URL)
+	require.Equal(t, "https://example.com/heading", result.Pages[1].URL)
+	require.Equal(t, "https://example.com/body", result.Pages[2].URL)
+}
+
+func TestBM25Ranking(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+
+	long := "Gopher notes. " + strings.Repeat("Unrelated filler text about other things. ", 50) + "Gopher gopher gopher."
+	pagesToStore := []*storage.Page{
+		{URL: "https://example.com/long", Lang: "en", Title: "Notes", Body: long},
+		{URL: "https://example.com/short", Lang: "en", Title: "Notes", Body: "A short gopher note."},
+	}
+	for _, p := range pagesToStore {
+		_, err := db.StorePage(ctx, p)
+		require.NoError(t, err)
+	}
+	_, err := db.IndexPages(ctx, 10)
+	require.NoError(t, err)
+	require.NoError(t, db.UpdateTermStats(ctx))
+
+	search := func(ranker string) []*storage.Page {
+		result, err := db.SearchPages(ctx, &storage.SearchQuery{Text: "gopher", Ranker: ranker, Snippet: storage.DefaultSnippetOptions(), Limit: 10})
+		require.NoError(t, err)
+		require.Len(t, result.Pages, 2)
+		return result.Pages
+	}
+
+	require.Equal(t, "https://example.com/long", search(storage.RankerTSRank)[0].URL)
+	results := search(storage.RankerBM25)
+	require.Equal(t, "https://example.com/short", results[0].URL, "BM25 должен учитывать длину страницы")
+	require.Less(t, results[0].Score, 1.0)
+}
+
+func TestSuggestionSources(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+
+	for _, p := range []*storage.Page{
+		{URL: "https://example.com/1", Title: "Goroutines", Body: "Goroutines and channels in Go 2024."},
+		{URL: "https://example.com/2", Title: "Channels", Body: "Buffered channels."},
+	} {
+		_, err := db.StorePage(ctx, p)
+		require.NoError(t, err)
+	}
+	_, err := db.IndexPages(ctx, 10)
+	require.NoError(t, err)
+	require.NoError(t, db.UpdateTermStats(ctx))
+
+	src, err := db.GetSuggestionSources(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, src.Titles, 2)
+	require.Equal(t, []storage.WeightedText{{Text: "channels", Weight: 2}}, src.Terms)
+}
+
+func TestQueryLog(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+
+	now := time.Now().Truncate(time.Second)
+	entries := []*storage.QueryLogEntry{
+		{Query: "Go", Normalized: "go", Results: 10, Latency: 4 * time.Millisecond, ClientID: "a", CreatedAt: now.Add(-time.Hour)},
+		{Query: "go", Normalized: "go", Results: 20, Latency: 30 * time.Millisecond, ClientID: "b", CreatedAt: now.Add(-30 * time.Minute)},
+		{Query: "gorutine", Normalized: "gorutine", Latency: 200 * time.Millisecond, ClientID: "a", CreatedAt: now.Add(-10 * time.Minute)},
+		{Query: "old", Normalized: "old", Latency: time.Millisecond, CreatedAt: now.Add(-48 * time.Hour)},
+	}
+	require.NoError(t, db.LogQueries(ctx, entries))
+
+	from, to := now.Add(-24*time.Hour), now
+	top, err := db.TopQueries(ctx, from, to, 10, false)
+	require.NoError(t, err)
+	require.Len(t, top, 2)
+	require.Equal(t, "go", top[0].Query)
+	require.Equal(t, int64(2), top[0].Count)
+	require.Equal(t, int64(2), top[0].Clients)
+	require.Equal(t, 15.0, top[0].AvgResults)
+	require.True(t, top[0].LastSeen.Equal(now.Add(-30*time.Minute)))
+
+	zero, err := db.TopQueries(ctx, from, to, 10, true)
+	require.NoError(t, err)
+	require.Len(t, zero, 1)
+	require.Equal(t, "gorutine", zero[0].Query)
+
+	stats, err := db.QueryLatency(ctx, from, to, []time.Duration{10 * time.Millisecond, 100 * time.Millisecond})
+	require.NoError(t, err)
+	require.Equal(t, int64(3), stats.Count)
+	require.Equal(t, 30*time.Millisecond, stats.P50)
+	require.Equal(t, 200*time.Millisecond, stats.P99)
+	require.Equal(t, []storage.LatencyBucket{
+		{UpperBound: 10 * time.Millisecond, Count: 1},
+		{UpperBound: 100 * time.Millisecond, Count: 1},
+		{Count: 1},
+	}, stats.Buckets)
+
+	deleted, err := db.DeleteQueryLog(ctx, from)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), deleted)
+}
+
+func TestFrontierWorkflow(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+
+	err := db.EnqueueURLs(ctx, []*storage.FrontierURL{
+		{URL: "https://example.com/low", Priority: 0},
+		{URL: "https://example.com/high", Priority: 10},
+		{URL: "https://example.com/later", NextFetchAt: time.Now().Add(time.Hour)},
+	})
+	require.NoError(t, err)
+
+	leased, err := db.LeaseURLs(ctx, "worker-1", 10, 10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, leased, 2)
+	require.Equal(t, "https://example.com/high", leased[0].URL)
+
+	t.Run("Арендованные URL не выдаются повторно", func(t *testing.T) {
+		again, err := db.LeaseURLs(ctx, "worker-2", 10, 10, time.Minute)
+		require.NoError(t, err)
+		require.Len(t, again, 0)
+	})
+
+	t.Run("Неудачная попытка увеличивает счетчик", func(t *testing.T) {
+		require.NoError(t, db.FailURL(ctx, leased[1].ID, time.Now().Add(-time.Second)))
+		retried, err := db.LeaseURLs(ctx, "worker-2", 10, 10, time.Minute)
+		require.NoError(t, err)
+		require.Len(t, retried, 1)
+		require.Equal(t, 1, retried[0].Attempts)
+	})
+
+	t.Run("Принудительное добавление переносит загрузку", func(t *testing.T) {
+		require.NoError(t, db.CompleteURL(ctx, leased[0].ID, time.Now().Add(time.Hour), nil))
+		err := db.EnqueueURLs(ctx, []*storage.FrontierURL{
+			{URL: "https://example.com/high", NextFetchAt: time.Now().Add(-time.Second), Force: true},
+		})
+		require.NoError(t, err)
+
+		due, err := db.LeaseURLs(ctx, "worker-3", 10, 10, time.Minute)
+		require.NoError(t, err)
+		require.Len(t, due, 1)
+		require.Equal(t, "https://example.com/high", due[0].URL)
+	})
+}
+
+func TestFrontierSitemapHints(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+
+	lastMod := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	err := db.EnqueueURLs(ctx, []*storage.FrontierURL{
+		{URL: "https://example.com/sitemap.xml", Sitemap: true},
+		{URL: "https://example.com/page", LastModified: lastMod, RevisitInterval: 7 * 24 * time.Hour},
+	})
+	require.NoError(t, err)
+	leased, err := db.LeaseURLs(ctx, "worker-1", 10, 10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, leased, 2)
+	for _, u := range leased {
+		if u.URL == "https://example.com/page" {
+			require.True(t, u.LastModified.Equal(lastMod))
+			require.Equal(t, 7*24*time.Hour, u.RevisitInterval)
+		} else {
+			require.True(t, u.Sitemap)
+		}
+		require.NoError(t, db.CompleteURL(ctx, u.ID, time.Now().Add(time.Hour), nil))
+	}
+
+	// Та же дата изменения не переносит загрузку, более поздняя - переносит.
+	page := &storage.FrontierURL{URL: "https://example.com/page", LastModified: lastMod}
+	require.NoError(t, db.EnqueueURLs(ctx, []*storage.FrontierURL{page}))
+	due, err := db.LeaseURLs(ctx, "worker-2", 10, 10, time.Minute)
+	require.NoError(t, err)
+	require.Empty(t, due)
+
+	page.LastModified = lastMod.Add(24 * time.Hour)
+	require.NoError(t, db.EnqueueURLs(ctx, []*storage.FrontierURL{page}))
+	due, err = db.LeaseURLs(ctx, "worker-2", 10, 10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+	require.Equal(t, "https://example.com/page", due[0].URL)
+	require.Equal(t, 7*24*time.Hour, due[0].RevisitInterval, "интервал без нового значения сохраняется")
+}
+
+func TestLeaseURLsPerHost(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+
+	err := db.EnqueueURLs(ctx, []*storage.FrontierURL{
+		{URL: "https://a.example/1", Priority: 5},
+		{URL: "https://a.example/2", Priority: 5},
+		{URL: "https://a.example/3", Priority: 5},
+		{URL: "https://b.example/1"},
+	})
+	require.NoError(t, err)
+
+	leased, err := db.LeaseURLs(ctx, "worker-1", 3, 1, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, leased, 2)
+
+	hosts := []string{leased[0].URL, leased[1].URL}
+	require.Contains(t, hosts, "https://b.example/1")
+}
+
+// legacySchema - первоначальная схема, которую создавал init.sql.
+const legacySchema = `
+CREATE TABLE pages (
+    id BIGSERIAL PRIMARY KEY,
+    url TEXT NOT NULL UNIQUE,
+    title TEXT,
+    html_content TEXT,
+    content_tsvector tsvector,
+    last_crawled_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE TABLE links (
+    from_page_id BIGINT NOT NULL REFERENCES pages(id) ON DELETE CASCADE,
+    to_page_id BIGINT NOT NULL REFERENCES pages(id) ON DELETE CASCADE,
+    PRIMARY KEY (from_page_id, to_page_id)
+);
+INSERT INTO pages (url, title, html_content) VALUES
+    ('https://example.com/', 'Home', 'old home'),
+    ('https://example.com/about', 'About', 'old about');
+INSERT INTO links VALUES (1, 2);
+`
+
+func TestMigrateLegacySchema(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+
+	_, err := db.pool.Exec(ctx, `DROP SCHEMA public CASCADE; CREATE SCHEMA public;`+legacySchema)
+	require.NoError(t, err)
+	require.NoError(t, db.migrate(ctx))
+	require.NoError(t, db.migrate(ctx), "повторное обновление ничего не меняет")
+
+	links, err := db.GetOutboundLinks(ctx, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, links, 1)
+	require.Equal(t, "https://example.com/about", links[0].ToURL)
+	require.Equal(t, int64(2), links[0].ToPageID)
+
+	_, err = db.StorePage(ctx, &storage.Page{
+		URL:   "https://example.com/",
+		Title: "Home",
+		Body:  "Migrated home page",
+		Links: []storage.Link{{ToURL: "https://example.com/contact", AnchorText: "Contact"}},
+	})
+	require.NoError(t, err)
+
+	links, err = db.GetOutboundLinks(ctx, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, links, 1)
+	require.Equal(t, "https://example.com/contact", links[0].ToURL)
+	require.Zero(t, links[0].ToPageID, "ссылка на еще не загруженную страницу")
 }
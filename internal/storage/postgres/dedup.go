@@ -0,0 +1,61 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"cis-engine/internal/storage"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func (db *DB) GetFingerprints(ctx context.Context) ([]*storage.PageFingerprint, error) {
+	rows, err := db.pool.Query(ctx, `SELECT id, url, simhash, authority FROM pages WHERE simhash <> 0 ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении отпечатков страниц: %w", err)
+	}
+	fingerprints, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*storage.PageFingerprint, error) {
+		var fp storage.PageFingerprint
+		var simhash int64
+		err := row.Scan(&fp.ID, &fp.URL, &simhash, &fp.Authority)
+		fp.SimHash = uint64(simhash)
+		return &fp, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении отпечатков страниц: %w", err)
+	}
+	return fingerprints, nil
+}
+
+func (db *DB) UpdateDuplicates(ctx context.Context, duplicates map[int64]int64) error {
+	err := pgx.BeginFunc(ctx, db.pool, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `CREATE TEMP TABLE page_duplicates (page_id BIGINT PRIMARY KEY, canonical_id BIGINT) ON COMMIT DROP`)
+		if err != nil {
+			return err
+		}
+
+		rows := make([][]any, 0, len(duplicates))
+		for id, canonical := range duplicates {
+			rows = append(rows, []any{id, canonical})
+		}
+		_, err = tx.CopyFrom(ctx, pgx.Identifier{"page_duplicates"}, []string{"page_id", "canonical_id"}, pgx.CopyFromRows(rows))
+		if err != nil {
+			return err
+		}
+
+		// Каноническая страница могла быть удалена после чтения отпечатков.
+		_, err = tx.Exec(ctx, `
+			UPDATE pages p
+			SET duplicate_of = c.id
+			FROM pages p2
+			LEFT JOIN page_duplicates d ON d.page_id = p2.id
+			LEFT JOIN pages c ON c.id = d.canonical_id
+			WHERE p.id = p2.id AND p.duplicate_of IS DISTINCT FROM c.id
+		`)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("ошибка при сохранении групп почти дубликатов: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package postgres
+
+import (
+	"cis-engine/internal/storage"
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func (db *DB) EnqueueURLs(ctx context.Context, urls []*storage.FrontierURL) error {
+	if len(urls) == 0 {
+		return nil
+	}
+
+	query := `
+		INSERT INTO frontier (url, priority, depth, next_fetch_at, scope, sitemap, last_modified, revisit_secs)
+		VALUES ($1, $2, $3, COALESCE($4, NOW()), $6, $7, $8, $9)
+		ON CONFLICT (url) DO UPDATE
+		SET scope = CASE WHEN $5 THEN COALESCE(EXCLUDED.scope, frontier.scope) ELSE frontier.scope END,
+			priority = GREATEST(frontier.priority, EXCLUDED.priority),
+			depth = LEAST(frontier.depth, EXCLUDED.depth),
+			next_fetch_at = CASE
+				WHEN $5 THEN LEAST(frontier.next_fetch_at, EXCLUDED.next_fetch_at)
+				WHEN EXCLUDED.last_modified > frontier.last_modified THEN LEAST(frontier.next_fetch_at, NOW())
+				ELSE frontier.next_fetch_at END,
+			sitemap = frontier.sitemap OR EXCLUDED.sitemap,
+			last_modified = GREATEST(frontier.last_modified, EXCLUDED.last_modified),
+			revisit_secs = CASE WHEN frontier.revisit_secs = 0 THEN EXCLUDED.revisit_secs ELSE frontier.revisit_secs END
+	`
+	batch := &pgx.Batch{}
+	for _, u := range urls {
+		var nextFetchAt *time.Time
+		if !u.NextFetchAt.IsZero() {
+			nextFetchAt = &u.NextFetchAt
+		}
+		normalized, err := normalizer.Normalize(u.URL)
+		if err != nil {
+			return fmt.Errorf("некорректный URL %s: %w", u.URL, err)
+		}
+		var lastModified *time.Time
+		if !u.LastModified.IsZero() {
+			lastModified = &u.LastModified
+		}
+		batch.Queue(query, normalized, u.Priority, u.Depth, nextFetchAt, u.Force, u.Scope,
+			u.Sitemap, lastModified, int(u.RevisitInterval/time.Second))
+	}
+
+	if err := db.pool.SendBatch(ctx, batch).Close(); err != nil {
+		return fmt.Errorf("ошибка при добавлении URL в очередь: %w", err)
+	}
+	return nil
+}
+
+func (db *DB) LeaseURLs(ctx context.Context, owner string, limit, perHost int, lease time.Duration) ([]*storage.FrontierURL, error) {
+	// Записи нумеруются внутри каждого хоста, поэтому сортировка по номеру
+	// выдает URL разных хостов по кругу. Условия выдачи повторяются на уровне
+	// FOR UPDATE: после блокировки Postgres перепроверяет только их, а запись
+	// могла быть арендована другим краулером после снимка подзапроса.
+	query := `
+		UPDATE frontier
+		SET lease_owner = $1,
+			lease_expires_at = NOW() + make_interval(secs => $4)
+		WHERE id IN (
+			SELECT id FROM frontier
+			WHERE id IN (
+				SELECT id FROM (
+					SELECT id, priority, next_fetch_at,
+						ROW_NUMBER() OVER (PARTITION BY host ORDER BY priority DESC, next_fetch_at) AS host_rank
+					FROM frontier
+					WHERE next_fetch_at <= NOW()
+						AND (lease_expires_at IS NULL OR lease_expires_at < NOW())
+				) ranked
+				WHERE host_rank <= $3
+				ORDER BY host_rank, priority DESC, next_fetch_at
+				LIMIT $2
+			)
+				AND next_fetch_at <= NOW()
+				AND (lease_expires_at IS NULL OR lease_expires_at < NOW())
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, url, priority, depth, next_fetch_at, attempts, scope, sitemap, last_modified, revisit_secs,
+			etag, http_last_modified, content_hash
+	`
+	rows, err := db.pool.Query(ctx, query, owner, limit, perHost, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении URL из очереди: %w", err)
+	}
+	defer rows.Close()
+
+	var urls []*storage.FrontierURL
+	for rows.Next() {
+		var u storage.FrontierURL
+		var lastModified *time.Time
+		var revisitSecs int
+		if err := rows.Scan(&u.ID, &u.URL, &u.Priority, &u.Depth, &u.NextFetchAt, &u.Attempts, &u.Scope,
+			&u.Sitemap, &lastModified, &revisitSecs, &u.ETag, &u.HTTPLastModified, &u.ContentHash); err != nil {
+			return nil, fmt.Errorf("ошибка при сканировании записи очереди: %w", err)
+		}
+		if lastModified != nil {
+			u.LastModified = *lastModified
+		}
+		u.RevisitInterval = time.Duration(revisitSecs) * time.Second
+		urls = append(urls, &u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при получении URL из очереди: %w", err)
+	}
+
+	sort.SliceStable(urls, func(i, j int) bool {
+		return urls[i].Priority > urls[j].Priority
+	})
+	return urls, nil
+}
+
+func (db *DB) CompleteURL(ctx context.Context, id int64, nextFetchAt time.Time, fetch *storage.FetchState) error {
+	query := `
+		UPDATE frontier
+		SET attempts = 0,
+			next_fetch_at = $2,
+			lease_owner = NULL,
+			lease_expires_at = NULL,
+			etag = CASE WHEN $3 THEN $4 ELSE etag END,
+			http_last_modified = CASE WHEN $3 THEN $5 ELSE http_last_modified END,
+			content_hash = CASE WHEN $3 THEN $6 ELSE content_hash END,
+			revisit_secs = CASE WHEN $3 THEN $7 ELSE revisit_secs END
+		WHERE id = $1
+	`
+	var state storage.FetchState
+	if fetch != nil {
+		state = *fetch
+	}
+	if _, err := db.pool.Exec(ctx, query, id, nextFetchAt, fetch != nil,
+		state.ETag, state.HTTPLastModified, state.ContentHash, int(state.RevisitInterval/time.Second)); err != nil {
+		return fmt.Errorf("ошибка при завершении обработки URL #%d: %w", id, err)
+	}
+	return nil
+}
+
+func (db *DB) FailURL(ctx context.Context, id int64, retryAt time.Time) error {
+	query := `
+		UPDATE frontier
+		SET attempts = attempts + 1,
+			next_fetch_at = $2,
+			lease_owner = NULL,
+			lease_expires_at = NULL
+		WHERE id = $1
+	`
+	if _, err := db.pool.Exec(ctx, query, id, retryAt); err != nil {
+		return fmt.Errorf("ошибка при переносе URL #%d: %w", id, err)
+	}
+	return nil
+}
+
+func (db *DB) ReleaseURLs(ctx context.Context, ids []int64) error {
+	query := `
+		UPDATE frontier
+		SET lease_owner = NULL,
+			lease_expires_at = NULL
+		WHERE id = ANY($1)
+	`
+	if _, err := db.pool.Exec(ctx, query, ids); err != nil {
+		return fmt.Errorf("ошибка при возврате URL в очередь: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package postgres
+
+import (
+	"maps"
+	"slices"
+)
+
+// tsConfigs сопоставляет коды языков конфигурациям текстового поиска
+// PostgreSQL. Для остальных языков используется simple - без стемминга.
+var tsConfigs = map[string]string{
+	"en": "english",
+	"ru": "russian",
+	"de": "german",
+	"fr": "french",
+	"es": "spanish",
+	"it": "italian",
+	"pt": "portuguese",
+	"nl": "dutch",
+}
+
+const defaultTSConfig = "simple"
+
+func tsConfig(lang string) string {
+	if cfg, ok := tsConfigs[lang]; ok {
+		return cfg
+	}
+	return defaultTSConfig
+}
+
+// searchConfigs возвращает конфигурации, по которым разбирается запрос:
+// для заданного языка - одну, иначе все.
+func searchConfigs(lang string) []string {
+	if lang != "" {
+		return []string{tsConfig(lang)}
+	}
+	return append(slices.Sorted(maps.Values(tsConfigs)), defaultTSConfig)
+}
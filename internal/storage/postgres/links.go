@@ -0,0 +1,160 @@
+package postgres
+
+import (
+	"cis-engine/internal/storage"
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// storeLinks заменяет исходящие ссылки страницы и привязывает к ней
+// ранее сохраненные входящие ссылки на ее адрес и псевдонимы.
+func storeLinks(ctx context.Context, tx pgx.Tx, pageID int64, pageURL string, page *storage.Page) error {
+	if _, err := tx.Exec(ctx, `DELETE FROM links WHERE from_page_id = $1`, pageID); err != nil {
+		return err
+	}
+
+	batch := &pgx.Batch{}
+	seen := make(map[string]bool, len(page.Links))
+	for _, link := range page.Links {
+		toURL, err := normalizer.Normalize(link.ToURL)
+		if err != nil || seen[toURL] {
+			continue
+		}
+		seen[toURL] = true
+		batch.Queue(`
+			INSERT INTO links (from_page_id, to_url, to_page_id, anchor_text, rel, nofollow)
+			VALUES ($1, $2, (SELECT id FROM pages WHERE url = $2), $3, $4, $5)
+		`, pageID, toURL, link.AnchorText, link.Rel, link.NoFollow)
+	}
+	if batch.Len() > 0 {
+		if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+			return err
+		}
+	}
+
+	urls := []string{pageURL}
+	for _, alias := range page.Aliases {
+		if aliasURL, err := normalizer.Normalize(alias.URL); err == nil {
+			urls = append(urls, aliasURL)
+		}
+	}
+	_, err := tx.Exec(ctx, `
+		UPDATE links SET to_page_id = $1
+		WHERE to_url = ANY($2) AND to_page_id IS DISTINCT FROM $1
+	`, pageID, urls)
+	return err
+}
+
+func (db *DB) GetOutboundLinks(ctx context.Context, pageID int64, limit int) ([]*storage.Link, error) {
+	query := `
+		SELECT l.from_page_id, p.url, COALESCE(l.to_page_id, 0), l.to_url, l.anchor_text, l.rel, l.nofollow
+		FROM links l
+		JOIN pages p ON p.id = l.from_page_id
+		WHERE l.from_page_id = $1
+		ORDER BY l.to_url
+		LIMIT $2
+	`
+	return db.queryLinks(ctx, pageID, query, limit)
+}
+
+func (db *DB) GetInboundLinks(ctx context.Context, pageID int64, limit int) ([]*storage.Link, error) {
+	query := `
+		SELECT l.from_page_id, p.url, COALESCE(l.to_page_id, 0), l.to_url, l.anchor_text, l.rel, l.nofollow
+		FROM links l
+		JOIN pages p ON p.id = l.from_page_id
+		WHERE l.to_page_id = $1
+		ORDER BY p.url
+		LIMIT $2
+	`
+	return db.queryLinks(ctx, pageID, query, limit)
+}
+
+func (db *DB) queryLinks(ctx context.Context, pageID int64, query string, limit int) ([]*storage.Link, error) {
+	var exists bool
+	if err := db.pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM pages WHERE id = $1)`, pageID).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("ошибка при проверке страницы #%d: %w", pageID, err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("страница #%d: %w", pageID, storage.ErrNotFound)
+	}
+
+	rows, err := db.pool.Query(ctx, query, pageID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении ссылок страницы #%d: %w", pageID, err)
+	}
+	defer rows.Close()
+
+	links := []*storage.Link{}
+	for rows.Next() {
+		var l storage.Link
+		if err := rows.Scan(&l.FromPageID, &l.FromURL, &l.ToPageID, &l.ToURL, &l.AnchorText, &l.Rel, &l.NoFollow); err != nil {
+			return nil, fmt.Errorf("ошибка при сканировании ссылки: %w", err)
+		}
+		links = append(links, &l)
+	}
+	return links, rows.Err()
+}
+
+func (db *DB) GetLinkGraph(ctx context.Context) (*storage.LinkGraph, error) {
+	graph := &storage.LinkGraph{}
+
+	rows, err := db.pool.Query(ctx, `SELECT id FROM pages ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении страниц графа: %w", err)
+	}
+	graph.Nodes, err = pgx.CollectRows(rows, pgx.RowTo[int64])
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении страниц графа: %w", err)
+	}
+
+	rows, err = db.pool.Query(ctx, `
+		SELECT from_page_id, to_page_id
+		FROM links
+		WHERE to_page_id IS NOT NULL AND NOT nofollow AND from_page_id <> to_page_id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении ребер графа: %w", err)
+	}
+	graph.Edges, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (storage.Edge, error) {
+		var e storage.Edge
+		err := row.Scan(&e.From, &e.To)
+		return e, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении ребер графа: %w", err)
+	}
+	return graph, nil
+}
+
+func (db *DB) UpdateAuthorityScores(ctx context.Context, scores map[int64]float64) error {
+	err := pgx.BeginFunc(ctx, db.pool, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `CREATE TEMP TABLE authority_scores (page_id BIGINT PRIMARY KEY, score DOUBLE PRECISION) ON COMMIT DROP`)
+		if err != nil {
+			return err
+		}
+
+		rows := make([][]any, 0, len(scores))
+		for id, score := range scores {
+			rows = append(rows, []any{id, score})
+		}
+		_, err = tx.CopyFrom(ctx, pgx.Identifier{"authority_scores"}, []string{"page_id", "score"}, pgx.CopyFromRows(rows))
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(ctx, `
+			UPDATE pages p
+			SET authority = COALESCE(s.score, 0)
+			FROM pages p2
+			LEFT JOIN authority_scores s ON s.page_id = p2.id
+			WHERE p.id = p2.id AND p.authority IS DISTINCT FROM COALESCE(s.score, 0)
+		`)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("ошибка при сохранении оценок авторитетности: %w", err)
+	}
+	return nil
+}
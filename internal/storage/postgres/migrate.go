@@ -0,0 +1,90 @@
+package postgres
+
+import (
+	"cis-engine/internal/storage"
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// migrateLockID - ключ advisory-блокировки, под которой сервисы,
+// запущенные одновременно, по очереди обновляют схему.
+const migrateLockID = 7241001
+
+// upgradeSQL приводит таблицы pages и links первоначальной схемы к
+// текущей. Таблицы, созданные текущей схемой, не меняются, поэтому запрос
+// можно выполнять при каждом запуске.
+const upgradeSQL = `
+DO $$
+BEGIN
+	IF EXISTS (
+		SELECT 1 FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = 'pages' AND column_name = 'html_content'
+	) THEN
+		ALTER TABLE pages RENAME COLUMN html_content TO body_text;
+		-- Страницы индексируются заново текущим индексатором.
+		UPDATE pages SET content_tsvector = NULL;
+	END IF;
+
+	IF EXISTS (
+		SELECT 1 FROM information_schema.tables
+		WHERE table_schema = current_schema() AND table_name = 'links'
+	) AND NOT EXISTS (
+		SELECT 1 FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = 'links' AND column_name = 'to_url'
+	) THEN
+		ALTER TABLE links ADD COLUMN to_url TEXT;
+		UPDATE links SET to_url = pages.url FROM pages WHERE pages.id = links.to_page_id;
+		ALTER TABLE links
+			DROP CONSTRAINT links_pkey,
+			DROP CONSTRAINT links_to_page_id_fkey,
+			ALTER COLUMN to_url SET NOT NULL,
+			ALTER COLUMN to_page_id DROP NOT NULL,
+			ADD CONSTRAINT links_to_page_id_fkey FOREIGN KEY (to_page_id) REFERENCES pages(id) ON DELETE SET NULL,
+			ADD PRIMARY KEY (from_page_id, to_url);
+	END IF;
+END $$;
+
+ALTER TABLE IF EXISTS pages
+	ADD COLUMN IF NOT EXISTS main_text TEXT NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS description TEXT NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS lang TEXT NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS headings JSONB,
+	ADD COLUMN IF NOT EXISTS final_url TEXT,
+	ADD COLUMN IF NOT EXISTS status_code INT,
+	ADD COLUMN IF NOT EXISTS content_type TEXT NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS content_length BIGINT NOT NULL DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS fetch_duration_ms BIGINT NOT NULL DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS headers JSONB,
+	ADD COLUMN IF NOT EXISTS content_hash TEXT NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS ts_config regconfig NOT NULL DEFAULT 'simple',
+	ADD COLUMN IF NOT EXISTS doc_length INT NOT NULL DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS simhash BIGINT NOT NULL DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS duplicate_of BIGINT REFERENCES pages(id) ON DELETE SET NULL,
+	ADD COLUMN IF NOT EXISTS authority DOUBLE PRECISION NOT NULL DEFAULT 0;
+
+ALTER TABLE IF EXISTS links
+	ADD COLUMN IF NOT EXISTS anchor_text TEXT NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS rel TEXT NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS nofollow BOOLEAN NOT NULL DEFAULT FALSE;
+`
+
+// migrate обновляет таблицы, созданные прежней версией схемы, и создает
+// недостающие объекты из storage.Schema.
+func (db *DB) migrate(ctx context.Context) error {
+	err := pgx.BeginFunc(ctx, db.pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, migrateLockID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, upgradeSQL); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx, storage.Schema)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("ошибка обновления схемы базы данных: %w", err)
+	}
+	return nil
+}
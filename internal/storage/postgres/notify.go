@@ -0,0 +1,43 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"log"
+)
+
+// pagesChannel - канал LISTEN/NOTIFY, в который StorePage сообщает о
+// сохранении страницы.
+const pagesChannel = "pages_stored"
+
+func (db *DB) WatchPages(ctx context.Context) (<-chan struct{}, error) {
+	pooled, err := db.pool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении соединения для LISTEN: %w", err)
+	}
+	// Соединение с активным LISTEN нельзя возвращать в пул.
+	conn := pooled.Hijack()
+	if _, err := conn.Exec(ctx, "LISTEN "+pagesChannel); err != nil {
+		conn.Close(context.Background())
+		return nil, fmt.Errorf("ошибка при подписке на уведомления: %w", err)
+	}
+
+	events := make(chan struct{}, 1)
+	go func() {
+		defer close(events)
+		defer conn.Close(context.Background())
+		for {
+			if _, err := conn.WaitForNotification(ctx); err != nil {
+				if ctx.Err() == nil {
+					log.Printf("Ошибка ожидания уведомлений о страницах: %v", err)
+				}
+				return
+			}
+			select {
+			case events <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	return events, nil
+}
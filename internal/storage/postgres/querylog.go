@@ -0,0 +1,100 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cis-engine/internal/storage"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func (db *DB) LogQueries(ctx context.Context, entries []*storage.QueryLogEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	rows := make([][]any, 0, len(entries))
+	for _, e := range entries {
+		rows = append(rows, []any{e.Query, e.Normalized, e.Results, e.Latency.Microseconds(), e.ClientID, e.CreatedAt})
+	}
+	_, err := db.pool.CopyFrom(ctx, pgx.Identifier{"query_log"},
+		[]string{"query", "normalized", "results", "latency_us", "client_id", "created_at"}, pgx.CopyFromRows(rows))
+	if err != nil {
+		return fmt.Errorf("ошибка при записи журнала запросов: %w", err)
+	}
+	return nil
+}
+
+func (db *DB) DeleteQueryLog(ctx context.Context, before time.Time) (int64, error) {
+	tag, err := db.pool.Exec(ctx, `DELETE FROM query_log WHERE created_at < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка при очистке журнала запросов: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+func (db *DB) TopQueries(ctx context.Context, from, to time.Time, limit int, zeroResults bool) ([]*storage.QueryCount, error) {
+	rows, err := db.pool.Query(ctx, `
+		SELECT normalized, count(*), count(DISTINCT client_id), avg(results)::float8, max(created_at)
+		FROM query_log
+		WHERE created_at >= $1 AND created_at < $2 AND (NOT $3 OR results = 0)
+		GROUP BY normalized
+		ORDER BY count(*) DESC, normalized
+		LIMIT $4
+	`, from, to, zeroResults, limit)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении частых запросов: %w", err)
+	}
+	queries, err := pgx.CollectRows(rows, pgx.RowToAddrOfStructByPos[storage.QueryCount])
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении частых запросов: %w", err)
+	}
+	return queries, nil
+}
+
+func (db *DB) QueryLatency(ctx context.Context, from, to time.Time, bounds []time.Duration) (*storage.LatencyStats, error) {
+	stats := &storage.LatencyStats{Buckets: make([]storage.LatencyBucket, len(bounds)+1)}
+	var p50, p90, p99 int64
+	err := db.pool.QueryRow(ctx, `
+		SELECT count(*),
+			coalesce(percentile_disc(0.5) WITHIN GROUP (ORDER BY latency_us), 0),
+			coalesce(percentile_disc(0.9) WITHIN GROUP (ORDER BY latency_us), 0),
+			coalesce(percentile_disc(0.99) WITHIN GROUP (ORDER BY latency_us), 0)
+		FROM query_log
+		WHERE created_at >= $1 AND created_at < $2
+	`, from, to).Scan(&stats.Count, &p50, &p90, &p99)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении времени ответа: %w", err)
+	}
+	stats.P50 = time.Duration(p50) * time.Microsecond
+	stats.P90 = time.Duration(p90) * time.Microsecond
+	stats.P99 = time.Duration(p99) * time.Microsecond
+
+	thresholds := make([]int64, len(bounds))
+	for i, b := range bounds {
+		stats.Buckets[i].UpperBound = b
+		thresholds[i] = b.Microseconds()
+	}
+	// width_bucket возвращает число границ, не превышающих значение, то
+	// есть номер корзины.
+	rows, err := db.pool.Query(ctx, `
+		SELECT width_bucket(latency_us, $3::bigint[]), count(*)
+		FROM query_log
+		WHERE created_at >= $1 AND created_at < $2
+		GROUP BY 1
+	`, from, to, thresholds)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении времени ответа: %w", err)
+	}
+	var bucket int
+	var count int64
+	_, err = pgx.ForEachRow(rows, []any{&bucket, &count}, func() error {
+		stats.Buckets[bucket].Count = count
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении времени ответа: %w", err)
+	}
+	return stats, nil
+}
@@ -0,0 +1,9 @@
+package storage
+
+import _ "embed"
+
+// Schema - схема базы данных PostgreSQL. Все объекты создаются с IF NOT
+// EXISTS, поэтому схему можно применять к уже созданной базе.
+//
+//go:embed schema.sql
+var Schema string
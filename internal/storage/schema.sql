@@ -6,8 +6,32 @@ CREATE TABLE IF NOT EXISTS pages (
     id BIGSERIAL PRIMARY KEY,
     url TEXT NOT NULL UNIQUE,
     title TEXT,
-    html_content TEXT,
+    -- Текст, извлеченный из HTML; исходная разметка хранится в page_raw
+    body_text TEXT,
+    -- Основной текст без навигации и служебных блоков; индексируется вместо
+    -- body_text, если не пустой
+    main_text TEXT NOT NULL DEFAULT '',
+    description TEXT NOT NULL DEFAULT '',
+    lang TEXT NOT NULL DEFAULT '',
+    headings JSONB,
+    final_url TEXT,
+    status_code INT,
+    content_type TEXT NOT NULL DEFAULT '',
+    content_length BIGINT NOT NULL DEFAULT 0,
+    fetch_duration_ms BIGINT NOT NULL DEFAULT 0,
+    headers JSONB,
+    -- SHA-256 исходного тела; при неизменном теле индекс не сбрасывается
+    content_hash TEXT NOT NULL DEFAULT '',
+    -- Конфигурация текстового поиска, соответствующая языку страницы
+    ts_config regconfig NOT NULL DEFAULT 'simple',
     content_tsvector tsvector,
+    -- Число слов в content_tsvector для BM25
+    doc_length INT NOT NULL DEFAULT 0,
+    authority DOUBLE PRECISION NOT NULL DEFAULT 0,
+    -- SimHash текста (0 - не вычислен) и каноническая страница группы почти
+    -- дубликатов, пересчитывается сервисом ranker
+    simhash BIGINT NOT NULL DEFAULT 0,
+    duplicate_of BIGINT REFERENCES pages(id) ON DELETE SET NULL,
     last_crawled_at TIMESTAMPTZ,
     created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
 );
@@ -15,10 +39,107 @@ CREATE TABLE IF NOT EXISTS pages (
 -- Индекс для ускорения поиска по URL
 CREATE INDEX IF NOT EXISTS idx_pages_url ON pages(url);
 CREATE INDEX IF NOT EXISTS idx_pages_tsvector ON pages USING GIN (content_tsvector);
+-- Очередь индексации: страницы, сохраненные после последней индексации
+CREATE INDEX IF NOT EXISTS idx_pages_unindexed ON pages(id) WHERE content_tsvector IS NULL;
 
--- Таблица для хранения графа ссылок (остается без изменений)
+-- Статистика для BM25, пересчитывается сервисом ranker: число
+-- проиндексированных страниц, содержащих лексему, и средняя длина страницы
+-- для каждой конфигурации текстового поиска
+CREATE TABLE IF NOT EXISTS term_stats (
+    ts_config regconfig NOT NULL,
+    lexeme TEXT NOT NULL,
+    doc_count INT NOT NULL,
+    PRIMARY KEY (ts_config, lexeme)
+);
+
+CREATE TABLE IF NOT EXISTS corpus_stats (
+    ts_config regconfig PRIMARY KEY,
+    doc_count BIGINT NOT NULL,
+    avg_length DOUBLE PRECISION NOT NULL
+);
+
+-- Частые слова заголовков и текстов страниц без стемминга для
+-- автодополнения запросов, пересчитываются вместе с term_stats
+CREATE TABLE IF NOT EXISTS word_stats (
+    word TEXT PRIMARY KEY,
+    doc_count INT NOT NULL
+);
+
+-- Исходное тело ответа. Хранится отдельно, чтобы не увеличивать строки
+-- pages, которые читаются при поиске.
+CREATE TABLE IF NOT EXISTS page_raw (
+    page_id BIGINT PRIMARY KEY REFERENCES pages(id) ON DELETE CASCADE,
+    encoding TEXT NOT NULL DEFAULT '',
+    body BYTEA NOT NULL
+);
+
+-- Альтернативные адреса страниц: исходные URL перенаправлений и
+-- адреса, указавшие на страницу через rel="canonical"
+CREATE TABLE IF NOT EXISTS url_aliases (
+    url TEXT PRIMARY KEY,
+    page_id BIGINT NOT NULL REFERENCES pages(id) ON DELETE CASCADE,
+    kind TEXT NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_url_aliases_page ON url_aliases(page_id);
+
+-- Граф ссылок. Ссылка сохраняется сразу, а to_page_id заполняется,
+-- когда целевая страница будет загружена.
 CREATE TABLE IF NOT EXISTS links (
     from_page_id BIGINT NOT NULL REFERENCES pages(id) ON DELETE CASCADE,
-    to_page_id BIGINT NOT NULL REFERENCES pages(id) ON DELETE CASCADE,
-    PRIMARY KEY (from_page_id, to_page_id)
-);
\ No newline at end of file
+    to_url TEXT NOT NULL,
+    to_page_id BIGINT REFERENCES pages(id) ON DELETE SET NULL,
+    anchor_text TEXT NOT NULL DEFAULT '',
+    rel TEXT NOT NULL DEFAULT '',
+    nofollow BOOLEAN NOT NULL DEFAULT FALSE,
+    PRIMARY KEY (from_page_id, to_url)
+);
+
+CREATE INDEX IF NOT EXISTS idx_links_to_page ON links(to_page_id);
+CREATE INDEX IF NOT EXISTS idx_links_to_url ON links(to_url);
+
+-- Очередь URL для краулера (frontier). Краулер арендует записи (lease),
+-- поэтому несколько экземпляров могут работать с одной очередью, а после
+-- перезапуска обход продолжается с места остановки.
+CREATE TABLE IF NOT EXISTS frontier (
+    id BIGSERIAL PRIMARY KEY,
+    url TEXT NOT NULL UNIQUE,
+    host TEXT GENERATED ALWAYS AS (substring(url from '^[A-Za-z][A-Za-z0-9+.-]*://([^/?#]+)')) STORED,
+    priority INT NOT NULL DEFAULT 0,
+    depth INT NOT NULL DEFAULT 0,
+    next_fetch_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    attempts INT NOT NULL DEFAULT 0,
+    scope JSONB,
+    -- sitemap - запись является файлом sitemap, а не страницей.
+    sitemap BOOLEAN NOT NULL DEFAULT FALSE,
+    -- last_modified - время изменения из sitemap; его увеличение переносит
+    -- загрузку на текущий момент.
+    last_modified TIMESTAMPTZ,
+    -- revisit_secs - интервал повторного обхода; 0 - интервал краулера.
+    -- Краулер сокращает его, когда страница меняется, и увеличивает, когда нет.
+    revisit_secs INT NOT NULL DEFAULT 0,
+    -- Валидаторы и хеш тела последнего ответа для условных запросов.
+    etag TEXT NOT NULL DEFAULT '',
+    http_last_modified TEXT NOT NULL DEFAULT '',
+    content_hash TEXT NOT NULL DEFAULT '',
+    lease_owner TEXT,
+    lease_expires_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_frontier_due ON frontier(next_fetch_at, priority DESC);
+
+-- Журнал поисковых запросов для аналитики. Старые записи удаляет API
+-- сервер по сроку хранения.
+CREATE TABLE IF NOT EXISTS query_log (
+    id BIGSERIAL PRIMARY KEY,
+    query TEXT NOT NULL,
+    normalized TEXT NOT NULL,
+    results BIGINT NOT NULL,
+    latency_us BIGINT NOT NULL,
+    client_id TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_query_log_created_at ON query_log(created_at);
@@ -1,27 +1,505 @@
 package storage
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
 	"time"
 )
 
 type Page struct {
+	ID    int64
+	URL   string
+	Title string
+	// Body - весь видимый текст, извлеченный из HTML.
+	Body string
+	// Content - основной текст страницы без навигации, колонтитулов и
+	// других служебных блоков; пустой, если выделить его не удалось.
+	Content string
+	// Description - содержимое <meta name="description">.
+	Description string
+	// Lang - код языка страницы ISO 639-1; пустой, если язык не определен.
+	Lang string
+	// Headings - заголовки h1–h3 в порядке следования в документе.
+	Headings  []Heading
+	CrawledAt time.Time
+	// Snippet и Score - фрагменты текста с выделенными словами запроса и
+	// оценка релевантности; заполняются только в результатах SearchPages.
+	Snippet string
+	Score   float64
+	// Duplicates - число почти дубликатов страницы, скрытых из выдачи;
+	// заполняется только в результатах SearchPages.
+	Duplicates int
+
+	// SimHash - отпечаток текста для поиска почти дубликатов; 0 - не
+	// вычислен. DuplicateOf - ID канонической страницы, если страница
+	// признана ее почти дубликатом.
+	SimHash     uint64
+	DuplicateOf int64
+
+	// FinalURL - адрес ответа после перенаправлений, до учета rel="canonical".
+	FinalURL      string
+	StatusCode    int
+	ContentType   string
+	ContentLength int64
+	FetchDuration time.Duration
+	Header        http.Header
+	// RawBody - исходное тело ответа в кодировке RawEncoding. Загружается
+	// только через GetPage.
+	RawBody     []byte
+	RawEncoding string
+	// ContentHash - SHA-256 исходного тела ответа. Если он совпадает с
+	// сохраненным, StorePage не сбрасывает поисковый индекс страницы.
+	ContentHash string
+
+	// Aliases - другие адреса этой страницы: исходный URL до перенаправления
+	// или адрес, указавший на эту страницу через rel="canonical".
+	Aliases []URLAlias
+	// Links - исходящие ссылки страницы.
+	Links []Link
+}
+
+type Heading struct {
+	Level int    `json:"level"`
+	Text  string `json:"text"`
+}
+
+// MainText возвращает основной текст страницы, а если он не выделен -
+// весь ее текст. По нему страница индексируется.
+func (p *Page) MainText() string {
+	if p.Content != "" {
+		return p.Content
+	}
+	return p.Body
+}
+
+// Кодировки Page.RawBody.
+const (
+	EncodingIdentity = ""
+	EncodingGzip     = "gzip"
+)
+
+// SetRawBody сохраняет исходное тело ответа, при compress - сжатым gzip.
+func (p *Page) SetRawBody(body []byte, compress bool) error {
+	p.ContentLength = int64(len(body))
+	if !compress {
+		p.RawBody, p.RawEncoding = body, EncodingIdentity
+		return nil
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(body); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	p.RawBody, p.RawEncoding = buf.Bytes(), EncodingGzip
+	return nil
+}
+
+// Raw возвращает исходное тело ответа в распакованном виде.
+func (p *Page) Raw() ([]byte, error) {
+	switch p.RawEncoding {
+	case EncodingIdentity:
+		return p.RawBody, nil
+	case EncodingGzip:
+		zr, err := gzip.NewReader(bytes.NewReader(p.RawBody))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		return io.ReadAll(zr)
+	default:
+		return nil, fmt.Errorf("неизвестная кодировка тела страницы: %q", p.RawEncoding)
+	}
+}
+
+// Link - ребро графа ссылок. ToPageID равен 0, пока целевая страница не загружена.
+type Link struct {
+	FromPageID int64  `json:"from_page_id"`
+	FromURL    string `json:"from_url,omitempty"`
+	ToPageID   int64  `json:"to_page_id,omitempty"`
+	ToURL      string `json:"to_url"`
+	AnchorText string `json:"anchor_text,omitempty"`
+	Rel        string `json:"rel,omitempty"`
+	NoFollow   bool   `json:"nofollow"`
+}
+
+// Виды URLAlias.Kind.
+const (
+	AliasRedirect  = "redirect"
+	AliasCanonical = "canonical"
+)
+
+type URLAlias struct {
+	URL  string
+	Kind string
+}
+
+// FrontierURL - запись в очереди краулера (frontier).
+type FrontierURL struct {
+	ID          int64
+	URL         string
+	Priority    int
+	Depth       int
+	NextFetchAt time.Time
+	Attempts    int
+	Scope       *CrawlScope
+	// Force переносит следующую загрузку уже известного URL на NextFetchAt,
+	// даже если он был запланирован на более позднее время.
+	Force bool
+	// Sitemap - URL указывает на файл sitemap, а не на страницу.
+	Sitemap bool
+	// LastModified - время изменения страницы из sitemap. Если оно позже
+	// сохраненного, загрузка уже известного URL переносится на текущий момент.
+	LastModified time.Time
+	// RevisitInterval - интервал повторного обхода; 0 - интервал по
+	// умолчанию краулера. При добавлении задает только начальный интервал:
+	// дальше его подбирает краулер через CompleteURL.
+	RevisitInterval time.Duration
+	// ETag, HTTPLastModified и ContentHash - сведения о последней загрузке
+	// для условного запроса и проверки изменений.
+	ETag             string
+	HTTPLastModified string
+	ContentHash      string
+}
+
+// FetchState - результат загрузки URL, сохраняемый в очереди.
+type FetchState struct {
+	// ETag и HTTPLastModified - значения заголовков ETag и Last-Modified
+	// ответа.
+	ETag             string
+	HTTPLastModified string
+	ContentHash      string
+	// RevisitInterval - интервал повторного обхода, подобранный по частоте
+	// изменений страницы.
+	RevisitInterval time.Duration
+}
+
+var ErrNotFound = errors.New("не найдено")
+
+// LinkGraph - граф ссылок между загруженными страницами без nofollow-ссылок.
+type LinkGraph struct {
+	Nodes []int64
+	Edges []Edge
+}
+
+type Edge struct {
+	From int64
+	To   int64
+}
+
+// PageFingerprint - отпечаток текста страницы для группировки почти
+// дубликатов.
+type PageFingerprint struct {
 	ID        int64
 	URL       string
-	Title     string
-	Body      string
-	CrawledAt time.Time
+	SimHash   uint64
+	Authority float64
+}
+
+// RankWeights задает вклад текстовой релевантности и авторитетности
+// страницы (PageRank) в итоговую оценку результата поиска, а также веса
+// полей страницы при вычислении текстовой релевантности.
+type RankWeights struct {
+	Text      float64
+	Authority float64
+
+	// Веса полей от 0 до 1: Title - заголовок страницы, Headings - заголовки
+	// h1–h3 и meta description, Anchors - слова URL и тексты входящих ссылок,
+	// Body - текст страницы.
+	Title    float64
+	Headings float64
+	Anchors  float64
+	Body     float64
+
+	// BM25K1 и BM25B - параметры ранжирования RankerBM25: насыщение частоты
+	// терма и степень нормализации по длине страницы.
+	BM25K1 float64
+	BM25B  float64
+}
+
+func DefaultRankWeights() RankWeights {
+	return RankWeights{Text: 1, Authority: 0.1, Title: 1, Headings: 0.4, Anchors: 0.2, Body: 0.1, BM25K1: 1.2, BM25B: 0.75}
+}
+
+func (w *RankWeights) Validate() error {
+	for _, v := range []float64{w.Title, w.Headings, w.Anchors, w.Body} {
+		if v < 0 || v > 1 {
+			return errors.New("веса полей должны быть от 0 до 1")
+		}
+	}
+	if w.BM25K1 < 0 || w.BM25B < 0 || w.BM25B > 1 {
+		return errors.New("параметр k1 BM25 должен быть неотрицательным, b - от 0 до 1")
+	}
+	return nil
+}
+
+// Способы ранжирования SearchQuery.Ranker.
+const (
+	// RankerTSRank - ts_rank_cd PostgreSQL, учитывающий близость слов запроса.
+	RankerTSRank = "ts_rank"
+	// RankerBM25 учитывает редкость слов в коллекции и длину страницы.
+	RankerBM25 = "bm25"
+)
+
+// SearchQuery - параметры поиска SearchPages. Результаты упорядочены по
+// убыванию оценки, при равной оценке - по возрастанию ID.
+type SearchQuery struct {
+	Text string
+	// Lang ограничивает поиск страницами на этом языке и задает разбор
+	// запроса; пустой - поиск по всем языкам.
+	Lang string
+	// Ranker - способ ранжирования; пустой - RankerTSRank.
+	Ranker  string
+	Filter  SearchFilter
+	Snippet SnippetOptions
+	Limit   int
+	Offset  int
+	// After - продолжение выдачи после указанного результата; при нем
+	// Offset не учитывается.
+	After *SearchCursor
+	// ShowDuplicates отключает схлопывание почти дубликатов: по умолчанию
+	// из каждой группы в выдаче остается одна страница, каноническая, если
+	// она найдена.
+	ShowDuplicates bool
+}
+
+// SearchFilter ограничивает выдачу SearchPages; пустые поля не учитываются.
+type SearchFilter struct {
+	// Site - хост страницы; поддомены тоже подходят.
+	Site string
+	// PathPrefix - начало пути URL, например "/blog/".
+	PathPrefix string
+	// CrawledAfter и CrawledBefore - границы времени последней загрузки
+	// страницы: нижняя включительно, верхняя - нет.
+	CrawledAfter  time.Time
+	CrawledBefore time.Time
+	// ContentType - тип содержимого без параметров, например "text/html".
+	ContentType string
+}
+
+// SearchCursor - позиция последнего результата предыдущей страницы выдачи.
+type SearchCursor struct {
+	Score float64 `json:"s"`
+	ID    int64   `json:"id"`
+}
+
+// SearchResult - страница выдачи. Total - число всех найденных страниц.
+type SearchResult struct {
+	Pages []*Page
+	Total int64
+}
+
+// SnippetOptions задает построение сниппетов: число фрагментов, их длину в
+// словах и маркеры, которыми выделяются найденные слова.
+type SnippetOptions struct {
+	MaxFragments int
+	MaxWords     int
+	MinWords     int
+	StartSel     string
+	StopSel      string
+	// FragmentDelimiter разделяет фрагменты в сниппете.
+	FragmentDelimiter string
+}
+
+func DefaultSnippetOptions() SnippetOptions {
+	return SnippetOptions{
+		MaxFragments:      2,
+		MaxWords:          25,
+		MinWords:          10,
+		StartSel:          "<mark>",
+		StopSel:           "</mark>",
+		FragmentDelimiter: " … ",
+	}
+}
+
+func (o *SnippetOptions) Validate() error {
+	if o.MaxFragments < 0 || o.MaxFragments > 10 {
+		return errors.New("число фрагментов сниппета должно быть от 0 до 10")
+	}
+	if o.MinWords <= 0 || o.MaxWords <= o.MinWords || o.MaxWords > 100 {
+		return errors.New("длина фрагмента сниппета должна быть от 2 до 100 слов")
+	}
+	for _, v := range []string{o.StartSel, o.StopSel, o.FragmentDelimiter} {
+		if strings.ContainsAny(v, "\"\x00") || len(v) > 32 {
+			return errors.New("маркеры сниппета не могут содержать кавычки и быть длиннее 32 байт")
+		}
+	}
+	return nil
+}
+
+// Режимы CrawlScope.Mode.
+const (
+	ScopeAny    = "any"
+	ScopeHost   = "host"
+	ScopeDomain = "domain"
+	ScopeList   = "list"
+)
+
+// CrawlScope - ограничения обхода. Ссылки наследуют область видимости
+// страницы, на которой найдены.
+type CrawlScope struct {
+	// Mode: "host" - только хост начального URL, "domain" - его
+	// регистрируемый домен, "list" - домены из AllowedDomains, "any" - без ограничений.
+	// Пустой режим в запросе на обход означает "domain".
+	Mode           string   `json:"mode,omitempty"`
+	Seed           string   `json:"seed,omitempty"`
+	AllowedDomains []string `json:"allowed_domains,omitempty"`
+	// MaxDepth - максимальная глубина ссылок от начального URL (0 - без ограничения).
+	MaxDepth        int `json:"max_depth,omitempty"`
+	MaxPagesPerHost int `json:"max_pages_per_host,omitempty"`
+	// Include и Exclude - регулярные выражения для пути и query-строки URL.
+	Include []string `json:"include,omitempty"`
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+func (s *CrawlScope) Validate() error {
+	switch s.Mode {
+	case "", ScopeAny, ScopeHost, ScopeDomain:
+	case ScopeList:
+		if len(s.AllowedDomains) == 0 {
+			return errors.New("для режима list нужен список allowed_domains")
+		}
+	default:
+		return fmt.Errorf("неизвестный режим области обхода: %q", s.Mode)
+	}
+	if s.MaxDepth < 0 || s.MaxPagesPerHost < 0 {
+		return errors.New("max_depth и max_pages_per_host не могут быть отрицательными")
+	}
+	for _, expr := range slices.Concat(s.Include, s.Exclude) {
+		if _, err := regexp.Compile(expr); err != nil {
+			return fmt.Errorf("некорректное регулярное выражение %q: %w", expr, err)
+		}
+	}
+	return nil
 }
 
 type Storer interface {
 	StorePage(ctx context.Context, page *Page) (int64, error)
-	GetNextPageToIndex(ctx context.Context) (*Page, error)
-	UpdatePageVector(ctx context.Context, page *Page) error
-	SearchPages(ctx context.Context, query string) ([]*Page, error)
+	// IndexPages строит поисковый индекс для не более чем limit страниц,
+	// сохраненных после последней индексации, и возвращает их число.
+	// Страницы, которые в этот момент индексирует другой вызов, пропускаются.
+	IndexPages(ctx context.Context, limit int) (int, error)
+	// WatchPages возвращает канал, в который приходит сигнал после
+	// сохранения страниц. Несколько сохранений могут давать один сигнал.
+	// Канал закрывается при отмене ctx или потере соединения.
+	WatchPages(ctx context.Context) (<-chan struct{}, error)
+	SearchPages(ctx context.Context, query *SearchQuery) (*SearchResult, error)
+	// GetPage возвращает страницу со всеми метаданными и исходным телом
+	// ответа; ErrNotFound, если страницы нет.
+	GetPage(ctx context.Context, id int64) (*Page, error)
 	GetMetrics(ctx context.Context) (*Metrics, error)
+
+	// GetOutboundLinks и GetInboundLinks возвращают ErrNotFound, если страницы нет.
+	GetOutboundLinks(ctx context.Context, pageID int64, limit int) ([]*Link, error)
+	GetInboundLinks(ctx context.Context, pageID int64, limit int) ([]*Link, error)
+	GetLinkGraph(ctx context.Context) (*LinkGraph, error)
+	// UpdateAuthorityScores сохраняет оценки авторитетности в диапазоне [0, 1];
+	// страницы, отсутствующие в scores, получают 0.
+	UpdateAuthorityScores(ctx context.Context, scores map[int64]float64) error
+	// GetFingerprints возвращает отпечатки страниц, у которых они вычислены.
+	GetFingerprints(ctx context.Context) ([]*PageFingerprint, error)
+	// UpdateDuplicates сохраняет для почти дубликатов ID их канонических
+	// страниц; страницы, отсутствующие в duplicates, становятся каноническими.
+	UpdateDuplicates(ctx context.Context, duplicates map[int64]int64) error
+	// UpdateTermStats пересчитывает частоты лексем и длины страниц, по
+	// которым ранжирует RankerBM25, и частые слова для автодополнения.
+	UpdateTermStats(ctx context.Context) error
+	// GetSuggestionSources возвращает до limit заголовков проиндексированных
+	// страниц и частых слов для автодополнения запросов.
+	GetSuggestionSources(ctx context.Context, limit int) (*SuggestionSources, error)
+
+	LogQueries(ctx context.Context, entries []*QueryLogEntry) error
+	// DeleteQueryLog удаляет записи журнала запросов, сделанные раньше
+	// before, и возвращает их число.
+	DeleteQueryLog(ctx context.Context, before time.Time) (int64, error)
+	// TopQueries возвращает до limit самых частых запросов за [from, to),
+	// сгруппированных по нормализованной форме; при zeroResults - только
+	// запросы, по которым ничего не найдено.
+	TopQueries(ctx context.Context, from, to time.Time, limit int, zeroResults bool) ([]*QueryCount, error)
+	// QueryLatency возвращает распределение времени ответа за [from, to)
+	// по корзинам с возрастающими границами bounds.
+	QueryLatency(ctx context.Context, from, to time.Time, bounds []time.Duration) (*LatencyStats, error)
+
+	EnqueueURLs(ctx context.Context, urls []*FrontierURL) error
+	// LeaseURLs арендует до limit готовых URL, не больше perHost на один хост.
+	LeaseURLs(ctx context.Context, owner string, limit, perHost int, lease time.Duration) ([]*FrontierURL, error)
+	// CompleteURL снимает аренду и планирует следующую загрузку на
+	// nextFetchAt; fetch, если задан, сохраняется для следующего обхода.
+	CompleteURL(ctx context.Context, id int64, nextFetchAt time.Time, fetch *FetchState) error
+	FailURL(ctx context.Context, id int64, retryAt time.Time) error
+	// ReleaseURLs снимает аренду с URL, не меняя их расписание.
+	ReleaseURLs(ctx context.Context, ids []int64) error
+
 	Close()
 }
 
+// RankTuner - хранилище, ранжирование которого настраивается весами.
+type RankTuner interface {
+	SetRankWeights(w RankWeights)
+}
+
+// SuggestionSources - исходные данные автодополнения. Weight заголовка -
+// авторитетность страницы, слова - число страниц, на которых оно
+// встречается. Списки упорядочены по убыванию веса.
+type SuggestionSources struct {
+	Titles []WeightedText
+	Terms  []WeightedText
+}
+
+type WeightedText struct {
+	Text   string
+	Weight float64
+}
+
+// QueryLogEntry - запись журнала поисковых запросов.
+type QueryLogEntry struct {
+	Query string
+	// Normalized - запрос в нижнем регистре с единичными пробелами; по нему
+	// группируется аналитика.
+	Normalized string
+	Results    int64
+	Latency    time.Duration
+	ClientID   string
+	CreatedAt  time.Time
+}
+
+type QueryCount struct {
+	Query   string `json:"query"`
+	Count   int64  `json:"count"`
+	Clients int64  `json:"clients"`
+	// AvgResults - среднее число найденных страниц.
+	AvgResults float64   `json:"avg_results"`
+	LastSeen   time.Time `json:"last_seen"`
+}
+
+// LatencyStats - распределение времени ответа.
+type LatencyStats struct {
+	Count         int64
+	P50, P90, P99 time.Duration
+	Buckets       []LatencyBucket
+}
+
+// LatencyBucket - число запросов со временем ответа меньше UpperBound и не
+// меньше границы предыдущей корзины. Последняя корзина не ограничена
+// сверху, ее UpperBound равен 0.
+type LatencyBucket struct {
+	UpperBound time.Duration
+	Count      int64
+}
+
 type Metrics struct {
-	PagesCount int64 `json:"pages_count"`
+	PagesCount    int64 `json:"pages_count"`
+	FrontierCount int64 `json:"frontier_count"`
+	FrontierDue   int64 `json:"frontier_due"`
 }
@@ -0,0 +1,223 @@
+// Package suggest дополняет вводимые запросы по префиксному дереву,
+// построенному из заголовков страниц, частых слов и успешных запросов
+// пользователей.
+package suggest
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+
+	"cis-engine/internal/storage"
+)
+
+// MaxLimit - наибольшее число подсказок, которое возвращает Complete.
+// Столько лучших вариантов хранится в каждом узле дерева, поэтому поиск
+// не зависит от размера словаря.
+const MaxLimit = 20
+
+// Веса источников подсказок. Оценка варианта - сумма по источникам
+// weight * ln(1 + n), где n - число запросов, страниц со словом или
+// 1 + 10 * авторитетность страницы для заголовка.
+const (
+	queryWeight = 3
+	titleWeight = 2
+	termWeight  = 1
+)
+
+// maxKeyLength ограничивает длину варианта в дереве; префиксы длиннее
+// не дополняются.
+const maxKeyLength = 80
+
+// maxQueries ограничивает число запоминаемых запросов.
+const maxQueries = 100_000
+
+type Suggestion struct {
+	Text  string  `json:"text"`
+	Score float64 `json:"score"`
+}
+
+type node struct {
+	children map[rune]*node
+	// top - лучшие варианты с этим префиксом по убыванию оценки.
+	top []*Suggestion
+}
+
+// Suggester хранит словарь подсказок. Словарь перестраивается целиком
+// методом Rebuild, запросы накапливаются между перестроениями.
+type Suggester struct {
+	mu sync.RWMutex
+	// phrases - заголовки, запросы и слова; words - только слова для
+	// дополнения последнего слова многословного запроса.
+	phrases *node
+	words   *node
+
+	queriesMu sync.Mutex
+	queries   map[string]int
+}
+
+func New() *Suggester {
+	return &Suggester{
+		phrases: &node{},
+		words:   &node{},
+		queries: make(map[string]int),
+	}
+}
+
+// Normalize приводит текст к ключу дерева: нижний регистр, "ё" заменяется
+// на "е", пробелы схлопываются.
+func Normalize(text string) string {
+	text = strings.ReplaceAll(strings.ToLower(text), "ё", "е")
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// Record запоминает запрос, по которому были найдены страницы.
+func (s *Suggester) Record(query string) {
+	key := Normalize(query)
+	if key == "" {
+		return
+	}
+	s.queriesMu.Lock()
+	defer s.queriesMu.Unlock()
+	if _, ok := s.queries[key]; ok || len(s.queries) < maxQueries {
+		s.queries[key]++
+	}
+}
+
+// Rebuild заменяет словарь подсказок построенным из src и запомненных
+// запросов.
+func (s *Suggester) Rebuild(src *storage.SuggestionSources) {
+	phrases := make(map[string]*Suggestion)
+	words := make(map[string]*Suggestion)
+	add := func(dict map[string]*Suggestion, text string, score float64) {
+		key := Normalize(text)
+		if key == "" {
+			return
+		}
+		if sg, ok := dict[key]; ok {
+			sg.Score += score
+			return
+		}
+		dict[key] = &Suggestion{Text: strings.Join(strings.Fields(text), " "), Score: score}
+	}
+
+	for _, t := range src.Titles {
+		add(phrases, t.Text, titleWeight*math.Log1p(1+10*t.Weight))
+	}
+	s.queriesMu.Lock()
+	for q, n := range s.queries {
+		add(phrases, q, queryWeight*math.Log1p(float64(n)))
+	}
+	s.queriesMu.Unlock()
+	for _, t := range src.Terms {
+		score := termWeight * math.Log1p(t.Weight)
+		add(phrases, t.Text, score)
+		add(words, t.Text, score)
+	}
+
+	phraseTree, wordTree := build(phrases), build(words)
+	s.mu.Lock()
+	s.phrases, s.words = phraseTree, wordTree
+	s.mu.Unlock()
+}
+
+func build(dict map[string]*Suggestion) *node {
+	root := &node{}
+	for key, sg := range dict {
+		n := root
+		n.insert(sg)
+		for i, r := range []rune(key) {
+			if i == maxKeyLength {
+				break
+			}
+			child := n.children[r]
+			if child == nil {
+				if n.children == nil {
+					n.children = make(map[rune]*node)
+				}
+				child = &node{}
+				n.children[r] = child
+			}
+			n = child
+			n.insert(sg)
+		}
+	}
+	return root
+}
+
+// insert добавляет вариант в список лучших, если он туда попадает.
+func (n *node) insert(sg *Suggestion) {
+	i, _ := slices.BinarySearchFunc(n.top, sg, compareSuggestions)
+	if i == MaxLimit {
+		return
+	}
+	n.top = slices.Insert(n.top, i, sg)
+	if len(n.top) > MaxLimit {
+		n.top = n.top[:MaxLimit]
+	}
+}
+
+func compareSuggestions(a, b *Suggestion) int {
+	return cmp.Or(cmp.Compare(b.Score, a.Score), cmp.Compare(a.Text, b.Text))
+}
+
+func (n *node) find(key string) *node {
+	for _, r := range key {
+		if n = n.children[r]; n == nil {
+			return nil
+		}
+	}
+	return n
+}
+
+// Complete возвращает до limit подсказок для начала запроса prefix. Если
+// вариантов, начинающихся с prefix, не хватает, дополняется последнее
+// слово.
+func (s *Suggester) Complete(prefix string, limit int) []Suggestion {
+	key := Normalize(prefix)
+	limit = min(limit, MaxLimit)
+	suggestions := []Suggestion{}
+	if key == "" || limit <= 0 {
+		return suggestions
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	if n := s.phrases.find(key); n != nil {
+		for _, sg := range n.top[:min(limit, len(n.top))] {
+			suggestions = append(suggestions, *sg)
+			seen[Normalize(sg.Text)] = true
+		}
+	}
+
+	head, last, ok := cutLastWord(key)
+	if !ok || len(suggestions) == limit {
+		return suggestions
+	}
+	if n := s.words.find(last); n != nil {
+		for _, sg := range n.top {
+			text := head + " " + sg.Text
+			if seen[Normalize(text)] {
+				continue
+			}
+			suggestions = append(suggestions, Suggestion{Text: text, Score: sg.Score})
+			if len(suggestions) == limit {
+				break
+			}
+		}
+	}
+	return suggestions
+}
+
+// cutLastWord отделяет последнее слово ключа от предыдущих.
+func cutLastWord(key string) (head, last string, ok bool) {
+	i := strings.LastIndexByte(key, ' ')
+	if i < 0 {
+		return "", "", false
+	}
+	return key[:i], key[i+1:], true
+}
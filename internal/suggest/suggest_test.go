@@ -0,0 +1,51 @@
+package suggest
+
+import (
+	"testing"
+
+	"cis-engine/internal/storage"
+
+	"github.com/stretchr/testify/require"
+)
+
+func texts(suggestions []Suggestion) []string {
+	var out []string
+	for _, sg := range suggestions {
+		out = append(out, sg.Text)
+	}
+	return out
+}
+
+func TestComplete(t *testing.T) {
+	s := New()
+	s.Record("golang generics")
+	s.Record("golang generics")
+	s.Record("Ёлка")
+	s.Rebuild(&storage.SuggestionSources{
+		Titles: []storage.WeightedText{
+			{Text: "Go by Example", Weight: 0.1},
+			{Text: "Golang   Weekly", Weight: 0.9},
+		},
+		Terms: []storage.WeightedText{
+			{Text: "goroutine", Weight: 40},
+			{Text: "concurrency", Weight: 12},
+			{Text: "context", Weight: 30},
+		},
+	})
+
+	t.Run("Варианты по убыванию оценки", func(t *testing.T) {
+		require.Equal(t, []string{"Golang Weekly", "goroutine", "golang generics", "Go by Example"}, texts(s.Complete("Go", 10)))
+		require.Equal(t, []string{"Golang Weekly", "goroutine"}, texts(s.Complete("go", 2)))
+	})
+
+	t.Run("Дополнение последнего слова", func(t *testing.T) {
+		require.Equal(t, []string{"go context", "go concurrency"}, texts(s.Complete("go c", 10)))
+		require.Equal(t, "Go by Example", s.Complete("go by", 10)[0].Text)
+	})
+
+	t.Run("Нормализация", func(t *testing.T) {
+		require.Equal(t, []string{"елка"}, texts(s.Complete("ел", 10)))
+		require.Empty(t, s.Complete("   ", 10))
+		require.Empty(t, s.Complete("xyz", 10))
+	})
+}
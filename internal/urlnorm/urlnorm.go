@@ -0,0 +1,254 @@
+// Package urlnorm приводит URL к канонической форме, чтобы одна и та же
+// страница не попадала в очередь и в индекс под разными адресами.
+package urlnorm
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// Политики обработки завершающего слеша в пути.
+const (
+	TrailingSlashKeep  = "keep"
+	TrailingSlashStrip = "strip"
+)
+
+var ErrUnsupportedScheme = errors.New("поддерживаются только схемы http и https")
+
+// Options настраивает нормализацию. Нулевое значение выполняет только
+// синтаксическую нормализацию, не меняющую смысл URL.
+type Options struct {
+	// StripParams - параметры query, которые удаляются. Шаблон с "*" на
+	// конце удаляет все параметры с таким префиксом ("utm_*").
+	StripParams []string
+	// SortQuery сортирует параметры query по имени.
+	SortQuery bool
+	// TrailingSlash - "keep" (по умолчанию) или "strip".
+	TrailingSlash string
+}
+
+// DefaultTrackingParams - распространенные параметры отслеживания.
+var DefaultTrackingParams = []string{
+	"utm_*", "fbclid", "gclid", "yclid", "ysclid", "msclkid", "_openstat", "mc_cid", "mc_eid", "_ga", "ref_src",
+}
+
+// Default - настройки для краулера: сортировка query и удаление параметров отслеживания.
+func Default() Options {
+	return Options{
+		StripParams:   DefaultTrackingParams,
+		SortQuery:     true,
+		TrailingSlash: TrailingSlashKeep,
+	}
+}
+
+// Normalize нормализует URL с настройками по умолчанию.
+func Normalize(raw string) (string, error) {
+	return Default().Normalize(raw)
+}
+
+// Normalize приводит абсолютный http(s) URL к канонической форме.
+func (o Options) Normalize(raw string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return "", err
+	}
+	if err := o.normalizeURL(u); err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+func (o Options) normalizeURL(u *url.URL) error {
+	u.Scheme = strings.ToLower(u.Scheme)
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return ErrUnsupportedScheme
+	}
+	if u.Host == "" {
+		return errors.New("в URL отсутствует хост")
+	}
+
+	host, err := normalizeHost(u.Scheme, u.Host)
+	if err != nil {
+		return err
+	}
+	u.Host = host
+	u.User = nil
+	u.Fragment = ""
+	u.RawFragment = ""
+
+	escaped := removeDotSegments(normalizeEscapes(u.EscapedPath()))
+	if escaped == "" {
+		escaped = "/"
+	}
+	if o.TrailingSlash == TrailingSlashStrip && len(escaped) > 1 {
+		escaped = strings.TrimRight(escaped, "/")
+		if escaped == "" {
+			escaped = "/"
+		}
+	}
+	path, err := url.PathUnescape(escaped)
+	if err != nil {
+		return err
+	}
+	u.Path = path
+	u.RawPath = ""
+	if u.EscapedPath() != escaped {
+		u.RawPath = escaped
+	}
+
+	u.RawQuery = o.normalizeQuery(u.RawQuery)
+	u.ForceQuery = false
+	return nil
+}
+
+func normalizeHost(scheme, hostport string) (string, error) {
+	host, port := hostport, ""
+	if h, p, err := net.SplitHostPort(hostport); err == nil {
+		host, port = h, p
+	}
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+
+	if net.ParseIP(host) == nil {
+		ascii, err := idna.Lookup.ToASCII(host)
+		if err != nil {
+			return "", err
+		}
+		host = ascii
+	} else if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+
+	if (scheme == "http" && port == "80") || (scheme == "https" && port == "443") {
+		port = ""
+	}
+	if port != "" {
+		return host + ":" + port, nil
+	}
+	return host, nil
+}
+
+func (o Options) normalizeQuery(raw string) string {
+	if raw == "" {
+		return ""
+	}
+
+	var params []string
+	for _, param := range strings.Split(raw, "&") {
+		if param == "" {
+			continue
+		}
+		param = normalizeEscapes(param)
+		name, _, _ := strings.Cut(param, "=")
+		if o.stripped(name) {
+			continue
+		}
+		params = append(params, param)
+	}
+
+	if o.SortQuery {
+		sort.SliceStable(params, func(i, j int) bool {
+			ni, _, _ := strings.Cut(params[i], "=")
+			nj, _, _ := strings.Cut(params[j], "=")
+			return ni < nj
+		})
+	}
+	return strings.Join(params, "&")
+}
+
+func (o Options) stripped(name string) bool {
+	if unescaped, err := url.QueryUnescape(name); err == nil {
+		name = unescaped
+	}
+	name = strings.ToLower(name)
+	for _, pattern := range o.StripParams {
+		pattern = strings.ToLower(pattern)
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if name == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeEscapes декодирует незарезервированные символы (RFC 3986,
+// раздел 2.3) и переводит остальные %-последовательности в верхний регистр.
+func normalizeEscapes(s string) string {
+	if !strings.Contains(s, "%") {
+		return s
+	}
+
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] == '%' && i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2]) {
+			c := unhex(s[i+1])<<4 | unhex(s[i+2])
+			if isUnreserved(c) {
+				b.WriteByte(c)
+			} else {
+				b.WriteByte('%')
+				b.WriteString(strings.ToUpper(s[i+1 : i+3]))
+			}
+			i += 2
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// removeDotSegments убирает сегменты "." и ".." из пути (RFC 3986, раздел 5.2.4).
+func removeDotSegments(p string) string {
+	if !strings.Contains(p, ".") {
+		return p
+	}
+
+	segments := strings.Split(p, "/")
+	out := make([]string, 0, len(segments))
+	for i, seg := range segments {
+		last := i == len(segments)-1
+		switch seg {
+		case ".":
+			if last {
+				out = append(out, "")
+			}
+		case "..":
+			if len(out) > 1 {
+				out = out[:len(out)-1]
+			}
+			if last {
+				out = append(out, "")
+			}
+		default:
+			out = append(out, seg)
+		}
+	}
+	return strings.Join(out, "/")
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
+
+func isUnreserved(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		c == '-' || c == '.' || c == '_' || c == '~'
+}